@@ -63,7 +63,57 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&runtimeCtx.CPUProfile, "cpuprofile", "", "cpuprofile file, none if empty")
 	rootCmd.PersistentFlags().StringVar(&runtimeCtx.LogLevelStr, "loglevel", "warn", "loglevel")
 
+	validateCmd.Flags().StringVar(&validateCtx.DocRoot, "docroot", "", "root against which doc references are resolved; defaults to two levels above the provider root")
+	validateCmd.Flags().StringVarP(&validateCtx.OutputFormat, "output", "o", "text", "output format, one of 'text', 'json'")
+
+	describeCmd.Flags().StringVar(&describeCtx.RegistryCfgStr, "registry", "", "registry config, as inline JSON / YAML or a file path; ignored when the provider is a local path")
+	describeCmd.Flags().StringVar(&describeCtx.ProviderVersion, "provider-version", "", "provider version or constraint, eg: '^0.1'; defaults to latest available")
+	describeCmd.Flags().StringVarP(&describeCtx.OutputFormat, "output", "o", "table", "output format, one of 'table', 'json', 'yaml'")
+	describeCmd.Flags().BoolVar(&describeCtx.Extended, "extended", false, "include extended attributes")
+
+	renderCmd.Flags().StringVar(&renderCtx.RegistryCfgStr, "registry", "", "registry config, as inline JSON / YAML or a file path; ignored when the provider is a local path")
+	renderCmd.Flags().StringVar(&renderCtx.ProviderVersion, "provider-version", "", "provider version or constraint, eg: '^0.1'; defaults to latest available")
+	renderCmd.Flags().StringVar(&renderCtx.Body, "body", "", "request body, as a JSON object")
+	renderCmd.Flags().StringVarP(&renderCtx.OutputFormat, "output", "o", "text", "output format, one of 'text', 'json'")
+
+	registryCmd.PersistentFlags().StringVar(&registryCmdCtx.ConfigFile, "config", "", "path to registry config YAML file")
+	registryListCmd.Flags().BoolVar(&registryCmdCtx.Local, "local", false, "list providers held under the local doc root")
+	registryCmd.AddCommand(registryListCmd)
+	registryCmd.AddCommand(registryVersionsCmd)
+	registryCmd.AddCommand(registryPullCmd)
+	registryServeCmd.Flags().StringVar(&registryCmdCtx.ServeAddress, "address", "localhost:8080", "address on which to listen")
+	registryCmd.AddCommand(registryVerifyCmd)
+	registryCmd.AddCommand(registryServeCmd)
+
+	diffCmd.Flags().StringVar(&diffCtx.RegistryCfgStr, "registry", "", "registry config, as inline JSON / YAML or a file path; ignored for local paths")
+	diffCmd.Flags().StringVarP(&diffCtx.OutputFormat, "output", "o", "text", "output format, one of 'text', 'json'")
+	diffCmd.Flags().BoolVar(&diffCtx.FailOnBreaking, "fail-on-breaking", false, "exit non-zero if any breaking change is found")
+
+	packageCmd.Flags().StringVar(&packageCtx.PrivateKeyPath, "key", "", "path to Ed25519 private key used for signing")
+	packageCmd.Flags().StringVar(&packageCtx.PrivateKeyFormat, "key-format", "pem", "private key format")
+
+	convertCmd.Flags().StringVar(&convertCtx.From, "from", "discovery", "source format, one of 'discovery', 'openapi'")
+	convertCmd.Flags().StringVar(&convertCtx.ProviderName, "provider", "", "provider name prefixing resource ids; defaults per source format")
+	convertCmd.Flags().StringVar(&convertCtx.ServiceName, "service", "", "service name following the provider in resource ids; defaults to the doc's title")
+	convertCmd.Flags().StringVar(&convertCtx.OutputFile, "output-file", "", "file to which the service doc is written; defaults to stdout")
+
+	splitCmd.Flags().StringVar(&splitCtx.DocDir, "doc-dir", "", "registry relative directory of the split docs, at which operation refs are rooted")
+	splitCmd.Flags().StringVar(&splitCtx.ResourcesFile, "resources-file", "", "file to which the resources file is written; defaults to 'resources.yaml' in the output directory")
+
+	migrateCmd.Flags().StringVar(&migrateCtx.DocRoot, "docroot", "", "root against which doc references are resolved; defaults to two levels above the provider root")
+	migrateCmd.Flags().BoolVar(&migrateCtx.DryRun, "dry-run", false, "report and validate changes without writing them")
+	migrateCmd.Flags().StringVarP(&migrateCtx.OutputFormat, "output", "o", "text", "output format, one of 'text', 'json'")
+
 	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(describeCmd)
+	rootCmd.AddCommand(renderCmd)
+	rootCmd.AddCommand(registryCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(packageCmd)
+	rootCmd.AddCommand(convertCmd)
+	rootCmd.AddCommand(splitCmd)
+	rootCmd.AddCommand(migrateCmd)
 
 }
 
@@ -0,0 +1,72 @@
+package argparse
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+)
+
+type convertContext struct {
+	From         string
+	ProviderName string
+	ServiceName  string
+	OutputFile   string
+}
+
+var (
+	convertCtx convertContext
+)
+
+// convertCmd represents the convert command
+var convertCmd = &cobra.Command{
+	Use:   "convert <doc-path>",
+	Short: "Convert an API description into a service doc",
+	Long: `Convert an API description into a service doc.
+
+The doc, in the format given by '--from', is converted into an OpenAPI 3
+service doc including 'x-stackQL-resources', written to '--output-file'
+or else to stdout.
+
+Formats:
+  discovery   Google Discovery doc
+  openapi     OpenAPI 3, 3.1 or Swagger 2.0 doc without stackQL extensions,
+              whose resources are inferred from its paths; '--provider' is required
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Help()
+			os.Exit(0)
+		}
+		RunConvertCommand(runtimeCtx, convertCtx, args[0])
+	},
+}
+
+func RunConvertCommand(rtCtx runtimeContext, cCtx convertContext, docPath string) {
+	b, err := os.ReadFile(docPath)
+	printErrorAndExitOneIfError(err)
+	var converted []byte
+	switch cCtx.From {
+	case "discovery":
+		converted, err = openapistackql.ConvertDiscoveryDoc(b, openapistackql.DiscoveryConverterConfig{ProviderName: cCtx.ProviderName})
+	case "openapi":
+		converted, err = openapistackql.GenerateStackQLResources(
+			b,
+			openapistackql.ResourceGeneratorConfig{
+				ProviderName: cCtx.ProviderName,
+				ServiceName:  cCtx.ServiceName,
+			},
+		)
+	default:
+		err = fmt.Errorf("unsupported source format '%s'", cCtx.From)
+	}
+	printErrorAndExitOneIfError(err)
+	if cCtx.OutputFile == "" {
+		fmt.Fprint(os.Stdout, string(converted))
+		return
+	}
+	err = os.WriteFile(cCtx.OutputFile, converted, openapistackql.ConfigFilesMode)
+	printErrorAndExitOneIfError(err)
+}
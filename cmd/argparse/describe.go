@@ -0,0 +1,234 @@
+package argparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+)
+
+const (
+	describeResourcesKey string = "resources"
+	describeMethodsKey   string = "methods"
+	describeColumnsKey   string = "columns"
+	describeErrorKey     string = "error"
+)
+
+type describeContext struct {
+	registryContext
+	OutputFormat string
+	Extended     bool
+}
+
+var (
+	describeCtx describeContext
+)
+
+// describeCmd represents the describe command
+var describeCmd = &cobra.Command{
+	Use:   "describe <provider> [<service> [<resource>]]",
+	Short: "Display the services, resources and methods of a provider",
+	Long: `Display the services, resources and methods of a provider.
+
+The provider is either a local path (provider root directory or 'provider.yaml'),
+or a provider name resolved through the registry given by '--registry'.
+Output includes SQL verbs, required parameters and,
+for SELECT methods, the columns produced by tabulating the response schema.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 || args[0] == "" {
+			cmd.Help()
+			os.Exit(0)
+		}
+		RunDescribeCommand(runtimeCtx, describeCtx, args)
+	},
+}
+
+func RunDescribeCommand(rtCtx runtimeContext, dCtx describeContext, args []string) {
+	reg, prov, err := loadProvider(dCtx.registryContext, args[0])
+	printErrorAndExitOneIfError(err)
+	var serviceFilter, resourceFilter string
+	if len(args) > 1 {
+		serviceFilter = args[1]
+	}
+	if len(args) > 2 {
+		resourceFilter = args[2]
+	}
+	services, err := describeProvider(reg, prov, serviceFilter, resourceFilter, dCtx.Extended)
+	printErrorAndExitOneIfError(err)
+	switch dCtx.OutputFormat {
+	case "json":
+		b, err := json.MarshalIndent(services, "", "  ")
+		printErrorAndExitOneIfError(err)
+		fmt.Fprintln(os.Stdout, string(b))
+	case "yaml":
+		b, err := yaml.Marshal(services)
+		printErrorAndExitOneIfError(err)
+		fmt.Fprint(os.Stdout, string(b))
+	case "table", "":
+		printDescribeTables(os.Stdout, prov.GetName(), services, dCtx.Extended)
+	default:
+		printErrorAndExitOneIfError(fmt.Errorf("unsupported output format '%s'", dCtx.OutputFormat))
+	}
+}
+
+func describeProvider(reg openapistackql.RegistryAPI, prov openapistackql.Provider, serviceFilter, resourceFilter string, extended bool) ([]map[string]interface{}, error) {
+	providerServices := prov.GetProviderServices()
+	if serviceFilter != "" {
+		ps, err := prov.GetProviderService(serviceFilter)
+		if err != nil {
+			return nil, err
+		}
+		providerServices = map[string]openapistackql.ProviderService{serviceFilter: ps}
+	}
+	var rv []map[string]interface{}
+	for _, k := range sortedMapKeys(providerServices) {
+		ps := providerServices[k]
+		svcMap := map[string]interface{}{
+			"id":          ps.GetID(),
+			"name":        ps.GetName(),
+			"title":       ps.GetTitle(),
+			"description": ps.GetDescription(),
+			"version":     ps.GetVersion(),
+			"preferred":   ps.IsPreferred(),
+		}
+		svcMap = projectMap(svcMap, openapistackql.GetServicesHeader(extended))
+		resources, err := openapistackql.LoadProviderServiceResources(reg, ps)
+		if err != nil {
+			if serviceFilter != "" {
+				return nil, err
+			}
+			svcMap[describeErrorKey] = err.Error()
+			rv = append(rv, svcMap)
+			continue
+		}
+		if resourceFilter != "" {
+			rsc, ok := resources[resourceFilter]
+			if !ok {
+				return nil, fmt.Errorf("cannot find resource '%s' in service '%s'", resourceFilter, k)
+			}
+			resources = map[string]openapistackql.Resource{resourceFilter: rsc}
+		}
+		var rscMaps []map[string]interface{}
+		for _, rk := range sortedMapKeys(resources) {
+			rscMaps = append(rscMaps, describeResource(resources[rk], extended))
+		}
+		svcMap[describeResourcesKey] = rscMaps
+		rv = append(rv, svcMap)
+	}
+	return rv, nil
+}
+
+func describeResource(rsc openapistackql.Resource, extended bool) map[string]interface{} {
+	rv := projectMap(rsc.ToMap(extended), openapistackql.GetResourcesHeader(extended))
+	methods, err := rsc.GetMethodsMatched().OrderMethods()
+	if err != nil {
+		rv[describeErrorKey] = err.Error()
+		return rv
+	}
+	var methodMaps []map[string]interface{}
+	for _, m := range methods {
+		mMap := m.ToPresentationMap(extended)
+		if strings.ToLower(m.GetSQLVerb()) == "select" {
+			cols, err := describeSelectColumns(m)
+			if err != nil {
+				mMap[describeErrorKey] = err.Error()
+			} else {
+				mMap[describeColumnsKey] = cols
+			}
+		}
+		methodMaps = append(methodMaps, mMap)
+	}
+	rv[describeMethodsKey] = methodMaps
+	return rv
+}
+
+func describeSelectColumns(m openapistackql.OperationStore) ([]map[string]interface{}, error) {
+	schema, _, err := m.GetSelectSchemaAndObjectPath()
+	if err != nil {
+		return nil, err
+	}
+	var rv []map[string]interface{}
+	for _, col := range schema.Tabulate(false).GetColumns() {
+		colType := ""
+		if s := col.GetSchema(); s != nil {
+			colType = s.GetType()
+		}
+		rv = append(rv, map[string]interface{}{
+			"name": col.GetName(),
+			"type": colType,
+		})
+	}
+	return rv, nil
+}
+
+func printDescribeTables(w io.Writer, providerName string, services []map[string]interface{}, extended bool) {
+	fmt.Fprintf(w, "provider '%s' services:\n", providerName)
+	printTable(w, openapistackql.GetServicesHeader(extended), services)
+	for _, svc := range services {
+		if errStr, ok := svc[describeErrorKey]; ok {
+			fmt.Fprintf(w, "\nservice '%v' unavailable: %v\n", svc["name"], errStr)
+			continue
+		}
+		resources, _ := svc[describeResourcesKey].([]map[string]interface{})
+		fmt.Fprintf(w, "\nservice '%v' resources:\n", svc["name"])
+		printTable(w, openapistackql.GetResourcesHeader(extended), resources)
+		for _, rsc := range resources {
+			methods, _ := rsc[describeMethodsKey].([]map[string]interface{})
+			fmt.Fprintf(w, "\nresource '%v.%v' methods:\n", svc["name"], rsc["name"])
+			printTable(w, openapistackql.NewEmptyOperationStore().GetColumnOrder(extended), methods)
+			for _, m := range methods {
+				if errStr, ok := m[describeErrorKey]; ok {
+					fmt.Fprintf(w, "\nmethod '%v.%v.%v' columns unavailable: %v\n", svc["name"], rsc["name"], m[openapistackql.MethodName], errStr)
+					continue
+				}
+				cols, ok := m[describeColumnsKey].([]map[string]interface{})
+				if !ok {
+					continue
+				}
+				fmt.Fprintf(w, "\nmethod '%v.%v.%v' columns:\n", svc["name"], rsc["name"], m[openapistackql.MethodName])
+				printTable(w, openapistackql.GetDescribeHeader(false), cols)
+			}
+		}
+	}
+}
+
+func printTable(w io.Writer, header []string, rows []map[string]interface{}) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		var cells []string
+		for _, h := range header {
+			cells = append(cells, fmt.Sprintf("%v", row[h]))
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	tw.Flush()
+}
+
+func projectMap(m map[string]interface{}, keys []string) map[string]interface{} {
+	rv := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		if v, ok := m[k]; ok {
+			rv[k] = v
+		}
+	}
+	return rv
+}
+
+func sortedMapKeys[V any](m map[string]V) []string {
+	var rv []string
+	for k := range m {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
@@ -0,0 +1,84 @@
+package argparse
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+
+	"gotest.tools/assert"
+)
+
+func loadOktaTestProviderPath(t *testing.T) string {
+	rv, err := fileutil.GetFilePathFromRepositoryRoot("test/registry/src/okta/v0.1.0")
+	assert.NilError(t, err)
+	return rv
+}
+
+func TestDescribeOkta(t *testing.T) {
+	reg, prov, err := loadProvider(registryContext{}, loadOktaTestProviderPath(t))
+	assert.NilError(t, err)
+	for _, tc := range []struct {
+		name           string
+		serviceFilter  string
+		resourceFilter string
+		extended       bool
+		expected       []string
+		expectedErr    string
+	}{
+		{
+			name: "provider",
+			expected: []string{
+				"provider 'okta' services:\n",
+				"service 'user' unavailable: cannot read local registry file",
+				"resource 'application.apps' methods:\n",
+			},
+		},
+		{
+			name:          "service",
+			serviceFilter: "application",
+			expected: []string{
+				"provider 'okta' services:\nid              name         title\napplication:v1  application  Application API\n",
+				"service 'application' resources:\nname    id\napps    okta.application.apps\ncsrs    okta.application.csrs\n",
+				"resource 'application.apps' methods:\nMethodName  RequiredParams    SQLVerb\nget         appId, subdomain  SELECT\nlist        subdomain         SELECT\n",
+				"method 'application.apps.list' columns:\nname           type\n",
+				"signOnMode     string\n",
+			},
+		},
+		{
+			name:           "extended resource",
+			serviceFilter:  "application",
+			resourceFilter: "apps",
+			extended:       true,
+			expected: []string{
+				"id              name         title            description",
+				"MethodName  RequiredParams    SQLVerb  description",
+			},
+		},
+		{
+			name:          "absent service",
+			serviceFilter: "absent",
+			expectedErr:   "absent",
+		},
+		{
+			name:           "absent resource",
+			serviceFilter:  "application",
+			resourceFilter: "absent",
+			expectedErr:    "cannot find resource 'absent' in service 'application'",
+		},
+	} {
+		services, err := describeProvider(reg, prov, tc.serviceFilter, tc.resourceFilter, tc.extended)
+		if tc.expectedErr != "" {
+			assert.ErrorContains(t, err, tc.expectedErr, tc.name)
+			continue
+		}
+		assert.NilError(t, err, tc.name)
+		var buf bytes.Buffer
+		printDescribeTables(&buf, prov.GetName(), services, tc.extended)
+		for _, expected := range tc.expected {
+			assert.Assert(t, bytes.Contains(buf.Bytes(), []byte(expected)), "%s: expected '%s' in:\n%s", tc.name, expected, buf.String())
+		}
+	}
+
+	t.Logf("TestDescribeOkta passed")
+}
@@ -0,0 +1,84 @@
+package argparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+)
+
+type diffContext struct {
+	RegistryCfgStr string
+	OutputFormat   string
+	FailOnBreaking bool
+}
+
+var (
+	diffCtx diffContext
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff (<lhs-path> <rhs-path> | <provider> <lhs-version> <rhs-version>)",
+	Short: "Semantic diff between two provider versions",
+	Long: `Semantic diff between two provider versions.
+
+Either two local provider paths, or a provider name plus two versions resolved
+through the registry given by '--registry', are compared.
+Reports added and removed services, resources and methods, changed SQL verbs,
+changed required parameters and added, removed or retyped SELECT columns,
+each classified as breaking or non-breaking.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 || len(args) > 3 {
+			cmd.Help()
+			os.Exit(0)
+		}
+		RunDiffCommand(runtimeCtx, diffCtx, args)
+	},
+}
+
+func RunDiffCommand(rtCtx runtimeContext, dCtx diffContext, args []string) {
+	var lhsCtx, rhsCtx registryContext
+	var lhsArg, rhsArg string
+	switch len(args) {
+	case 2:
+		lhsArg, rhsArg = args[0], args[1]
+	default:
+		lhsArg, rhsArg = args[0], args[0]
+		lhsCtx = registryContext{RegistryCfgStr: dCtx.RegistryCfgStr, ProviderVersion: args[1]}
+		rhsCtx = registryContext{RegistryCfgStr: dCtx.RegistryCfgStr, ProviderVersion: args[2]}
+	}
+	lhsReg, lhs, err := loadProvider(lhsCtx, lhsArg)
+	printErrorAndExitOneIfError(err)
+	rhsReg, rhs, err := loadProvider(rhsCtx, rhsArg)
+	printErrorAndExitOneIfError(err)
+	changes, err := openapistackql.NewProviderDiffer(lhsReg, rhsReg).Diff(lhs, rhs)
+	printErrorAndExitOneIfError(err)
+	switch dCtx.OutputFormat {
+	case "json":
+		if changes == nil {
+			changes = []openapistackql.ProviderChange{}
+		}
+		b, err := json.MarshalIndent(changes, "", "  ")
+		printErrorAndExitOneIfError(err)
+		fmt.Fprintln(os.Stdout, string(b))
+	case "text", "":
+		breakingCount := 0
+		for _, c := range changes {
+			if c.Breaking {
+				breakingCount++
+			}
+			fmt.Fprintln(os.Stdout, c.String())
+		}
+		fmt.Fprintf(os.Stdout, "\n%d change(s), %d breaking\n", len(changes), breakingCount)
+	default:
+		printErrorAndExitOneIfError(fmt.Errorf("unsupported output format '%s'", dCtx.OutputFormat))
+	}
+	if dCtx.FailOnBreaking && openapistackql.HasBreakingChanges(changes) {
+		os.Exit(1)
+	}
+}
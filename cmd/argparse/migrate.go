@@ -0,0 +1,79 @@
+package argparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+)
+
+type migrateContext struct {
+	DocRoot      string
+	DryRun       bool
+	OutputFormat string
+}
+
+var (
+	migrateCtx migrateContext
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Rewrite deprecated constructs in a provider doc tree",
+	Long: `Rewrite deprecated constructs in a provider doc tree.
+
+Accepts the path to a provider root directory, or to its 'provider.yaml',
+and rewrites, in place, the deprecated constructs found in every service
+and resources doc reachable from it:
+  pathRef    method 'path' and 'operation' refs merged into an operation ref
+  verbCase   upper case http verbs in operation refs lower cased
+
+Each change is reported, with a file and JSON pointer location.  The migrated
+tree is re-loaded before any file is written; should this reveal any problem
+not present beforehand, nothing is written and the exit status is non-zero.
+Signatures of rewritten docs must be regenerated.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 || args[0] == "" {
+			cmd.Help()
+			os.Exit(0)
+		}
+		RunMigrateCommand(runtimeCtx, migrateCtx, args[0])
+	},
+}
+
+func RunMigrateCommand(rtCtx runtimeContext, mCtx migrateContext, providerPath string) {
+	docRoot, providerDocPath, err := resolveProviderDocPath(providerPath, mCtx.DocRoot)
+	printErrorAndExitOneIfError(err)
+	m := openapistackql.NewProviderMigrator(docRoot, mCtx.DryRun)
+	report, err := m.Migrate(providerDocPath)
+	printErrorAndExitOneIfError(err)
+	switch mCtx.OutputFormat {
+	case "json":
+		b, err := json.MarshalIndent(report, "", "  ")
+		printErrorAndExitOneIfError(err)
+		fmt.Fprintln(os.Stdout, string(b))
+	case "text", "":
+		for _, c := range report.Changes {
+			fmt.Fprintln(os.Stdout, c.String())
+		}
+		for _, f := range report.Findings {
+			fmt.Fprintln(os.Stdout, f.String())
+		}
+		fmt.Fprintf(os.Stdout, "\n%d change(s) to %d file(s) in '%s'\n", len(report.Changes), len(report.Files), providerDocPath)
+		if len(report.Findings) > 0 {
+			fmt.Fprintf(os.Stdout, "%d problem(s) introduced; no files written\n", len(report.Findings))
+		} else if mCtx.DryRun {
+			fmt.Fprintln(os.Stdout, "dry run; no files written")
+		}
+	default:
+		printErrorAndExitOneIfError(fmt.Errorf("unsupported output format '%s'", mCtx.OutputFormat))
+	}
+	if len(report.Findings) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,52 @@
+package argparse
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+)
+
+type packageContext struct {
+	PrivateKeyPath   string
+	PrivateKeyFormat string
+}
+
+var (
+	packageCtx packageContext
+)
+
+// packageCmd represents the package command
+var packageCmd = &cobra.Command{
+	Use:   "package <registry-root> <provider> <version>",
+	Short: "Sign and archive a provider version for publication",
+	Long: `Sign and archive a provider version for publication.
+
+Every doc under '<registry-root>/src/<provider>/<version>' is signed with the
+Ed25519 private key given by '--key', the archive '<registry-root>/dist/<provider>/<version>.tgz'
+is written and the version is recorded in '<registry-root>/dist/providers.yaml'.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 3 {
+			cmd.Help()
+			os.Exit(0)
+		}
+		RunPackageCommand(runtimeCtx, packageCtx, args[0], args[1], args[2])
+	},
+}
+
+func RunPackageCommand(rtCtx runtimeContext, pCtx packageContext, registryRoot string, prov string, version string) {
+	pp, err := openapistackql.NewProviderPackager(
+		openapistackql.PackagerConfig{
+			RegistryRoot:     registryRoot,
+			PrivateKeyPath:   pCtx.PrivateKeyPath,
+			PrivateKeyFormat: pCtx.PrivateKeyFormat,
+		},
+	)
+	printErrorAndExitOneIfError(err)
+	err = pp.Package(prov, version)
+	printErrorAndExitOneIfError(err)
+	fmt.Fprintf(os.Stdout, "successfully packaged provider '%s' version '%s'\n", prov, version)
+}
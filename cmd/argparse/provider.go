@@ -0,0 +1,84 @@
+package argparse
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+)
+
+type registryContext struct {
+	RegistryCfgStr  string
+	ProviderVersion string
+}
+
+func parseRegistryConfig(cfgStr string) (openapistackql.RegistryConfig, error) {
+	var rc openapistackql.RegistryConfig
+	if cfgStr == "" {
+		return rc, nil
+	}
+	// accept either inline JSON / YAML or a path to a file containing same
+	b := []byte(cfgStr)
+	if fb, err := os.ReadFile(cfgStr); err == nil {
+		b = fb
+	}
+	err := yaml.Unmarshal(b, &rc)
+	return rc, err
+}
+
+// newLocalTreeRegistry returns an unverified registry serving docs
+// straight from a working tree rooted at docRoot.
+func newLocalTreeRegistry(docRoot string) (openapistackql.RegistryAPI, error) {
+	srcPrefix := ""
+	return openapistackql.NewRegistry(
+		openapistackql.RegistryConfig{
+			RegistryURL:   fmt.Sprintf("file://%s", docRoot),
+			SrcPrefix:     &srcPrefix,
+			VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+		},
+		nil,
+	)
+}
+
+// loadProvider resolves a provider either from a local path
+// (provider root directory or 'provider.yaml') or by name through the configured registry.
+func loadProvider(regCtx registryContext, providerArg string) (openapistackql.RegistryAPI, openapistackql.Provider, error) {
+	if _, err := os.Stat(providerArg); err == nil {
+		docRoot, providerDocPath, err := resolveProviderDocPath(providerArg, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		reg, err := newLocalTreeRegistry(docRoot)
+		if err != nil {
+			return nil, nil, err
+		}
+		pathElems := strings.Split(path.Dir(providerDocPath), "/")
+		if len(pathElems) != 2 {
+			return nil, nil, fmt.Errorf("cannot infer provider name and version from path '%s'", providerDocPath)
+		}
+		prov, err := reg.LoadProviderByName(pathElems[0], pathElems[1])
+		return reg, prov, err
+	}
+	rc, err := parseRegistryConfig(regCtx.RegistryCfgStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	reg, err := openapistackql.NewRegistry(rc, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	version := regCtx.ProviderVersion
+	if version == "" {
+		version, err = reg.GetLatestAvailableVersion(providerArg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot determine version for provider '%s'; please supply one: %s", providerArg, err.Error())
+		}
+	}
+	prov, err := reg.LoadProviderByName(providerArg, version)
+	return reg, prov, err
+}
@@ -0,0 +1,170 @@
+package argparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/registryserver"
+	"github.com/stackql/go-openapistackql/pkg/semver"
+)
+
+type registryCmdContext struct {
+	ConfigFile   string
+	Local        bool
+	ServeAddress string
+}
+
+var (
+	registryCmdCtx registryCmdContext
+)
+
+// registryCmd represents the registry command
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Provider registry management",
+	Long: `Provider registry management.
+
+Each subcommand operates against the registry described by the 'RegistryConfig' YAML
+file passed via '--config'.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+		os.Exit(0)
+	},
+}
+
+var registryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List providers and their latest versions",
+	Long:  `List providers and their latest versions; with '--local', list providers held under the local doc root.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		reg := getRegistryFromConfigFile(registryCmdCtx.ConfigFile)
+		var provs map[string]openapistackql.ProviderDescription
+		if registryCmdCtx.Local {
+			provs = reg.ListLocallyAvailableProviders()
+		} else {
+			var err error
+			provs, err = reg.ListAllAvailableProviders()
+			printErrorAndExitOneIfError(err)
+		}
+		printProviderDescriptions(provs)
+	},
+}
+
+var registryVersionsCmd = &cobra.Command{
+	Use:   "versions <provider>",
+	Short: "List all published versions of a provider",
+	Long:  `List all published versions of a provider.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 || args[0] == "" {
+			cmd.Help()
+			os.Exit(0)
+		}
+		reg := getRegistryFromConfigFile(registryCmdCtx.ConfigFile)
+		provs, err := reg.ListAllProviderVersions(args[0])
+		printErrorAndExitOneIfError(err)
+		printProviderDescriptions(provs)
+	},
+}
+
+var registryPullCmd = &cobra.Command{
+	Use:   "pull <provider> [<version>|<constraint>]",
+	Short: "Pull a provider archive into the local doc root",
+	Long: `Pull a provider archive into the local doc root.
+
+When no version is nominated, the latest published stable version is pulled.
+A constraint, eg: '^0.1' or '>=0.1.0 <0.2.0', pulls the latest published version satisfying it.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 || args[0] == "" {
+			cmd.Help()
+			os.Exit(0)
+		}
+		reg := getRegistryFromConfigFile(registryCmdCtx.ConfigFile)
+		prov := args[0]
+		var version string
+		if len(args) > 1 && !semver.IsConstraint(args[1]) {
+			version = args[1]
+		} else {
+			var err error
+			version, err = reg.GetLatestPublishedVersion(prov, args[1:]...)
+			printErrorAndExitOneIfError(err)
+		}
+		err := reg.PullAndPersistProviderArchive(prov, version)
+		printErrorAndExitOneIfError(err)
+		fmt.Fprintf(os.Stdout, "successfully pulled provider '%s' version '%s'\n", prov, version)
+	},
+}
+
+var registryVerifyCmd = &cobra.Command{
+	Use:   "verify [<provider>]",
+	Short: "Audit signatures of locally held docs",
+	Long: `Audit signatures of locally held docs.
+
+Every doc under the local doc root, or under the nominated provider only,
+is checked against its detached signature; failures are listed and the exit code is non-zero.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		reg := getRegistryFromConfigFile(registryCmdCtx.ConfigFile)
+		var prov string
+		if len(args) > 0 {
+			prov = args[0]
+		}
+		failures, err := reg.VerifyLocalDocs(prov)
+		printErrorAndExitOneIfError(err)
+		for _, f := range failures {
+			fmt.Fprintln(os.Stdout, f.Error())
+		}
+		fmt.Fprintf(os.Stdout, "\n%d doc(s) failed verification\n", len(failures))
+		if len(failures) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var registryServeCmd = &cobra.Command{
+	Use:   "serve <registry-root>",
+	Short: "Serve a local directory as a provider registry over HTTP",
+	Long: `Serve a local directory as a provider registry over HTTP.
+
+The directory must use the registry layout: 'src/' docs with '.sig' files,
+'dist/' archives and 'dist/providers.yaml'.
+Point 'RegistryConfig.url' at the listen address to consume it.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 || args[0] == "" {
+			cmd.Help()
+			os.Exit(0)
+		}
+		rs, err := registryserver.NewRegistryServer(args[0])
+		printErrorAndExitOneIfError(err)
+		fmt.Fprintf(os.Stdout, "serving registry root '%s' on '%s'\n", rs.GetRoot(), registryCmdCtx.ServeAddress)
+		err = http.ListenAndServe(registryCmdCtx.ServeAddress, rs)
+		printErrorAndExitOneIfError(err)
+	},
+}
+
+func getRegistryFromConfigFile(configFile string) openapistackql.RegistryAPI {
+	if configFile == "" {
+		printErrorAndExitOneIfError(fmt.Errorf("registry config file must be supplied via '--config'"))
+	}
+	b, err := os.ReadFile(configFile)
+	printErrorAndExitOneIfError(err)
+	var rc openapistackql.RegistryConfig
+	printErrorAndExitOneIfError(yaml.Unmarshal(b, &rc))
+	reg, err := openapistackql.NewRegistry(rc, nil)
+	printErrorAndExitOneIfError(err)
+	return reg
+}
+
+func printProviderDescriptions(provs map[string]openapistackql.ProviderDescription) {
+	b, err := json.MarshalIndent(openapistackql.ProvidersList{Providers: provs}, "", "  ")
+	printErrorAndExitOneIfError(err)
+	fmt.Fprintln(os.Stdout, string(b))
+}
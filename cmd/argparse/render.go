@@ -0,0 +1,207 @@
+package argparse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/stackql/stackql-parser/go/vt/sqlparser"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/internaldto"
+	"github.com/stackql/go-openapistackql/pkg/requesttranslate"
+)
+
+type renderContext struct {
+	registryContext
+	Body         string
+	OutputFormat string
+}
+
+var (
+	renderCtx renderContext
+)
+
+type renderedRequest struct {
+	Method string              `json:"method"`
+	URL    string              `json:"url"`
+	Header map[string][]string `json:"header,omitempty"`
+	Body   string              `json:"body,omitempty"`
+}
+
+// renderCmd represents the render command
+var renderCmd = &cobra.Command{
+	Use:   "render <provider> <service> <resource> <method> [<key>=<value> ...]",
+	Short: "Dry run request construction for a method",
+	Long: `Dry run request construction for a method.
+
+Builds the HTTP request(s) for the nominated method exactly as for execution,
+including server variable substitution, query transposition and request translation,
+and prints method, URL, headers and body; nothing is sent.
+Parameter values of the form JSON('...') are subject to query transposition.
+Request body attributes may be supplied as '` + openapistackql.RequestBodyBaseKey + `<attr>=<value>' or wholesale through '--body'.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 4 {
+			cmd.Help()
+			os.Exit(0)
+		}
+		RunRenderCommand(runtimeCtx, renderCtx, args)
+	},
+}
+
+func parseRenderParams(args []string) (map[string]interface{}, error) {
+	rv := make(map[string]interface{})
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("parameter '%s' not of the form <key>=<value>", arg)
+		}
+		rv[kv[0]] = parseRenderParamVal(kv[1])
+	}
+	return rv, nil
+}
+
+// parseRenderParamVal mimics the parser output for JSON('...') literals,
+// so that query transposition is exercised as it would be from SQL.
+func parseRenderParamVal(val string) interface{} {
+	if len(val) > 6 && strings.ToUpper(val[:5]) == "JSON(" && strings.HasSuffix(val, ")") {
+		arg := strings.Trim(val[5:len(val)-1], "'")
+		return &sqlparser.FuncExpr{
+			Name: sqlparser.NewColIdent("JSON"),
+			Exprs: sqlparser.SelectExprs{
+				&sqlparser.AliasedExpr{Expr: sqlparser.NewStrVal([]byte(arg))},
+			},
+		}
+	}
+	return val
+}
+
+func RunRenderCommand(rtCtx runtimeContext, rCtx renderContext, args []string) {
+	reg, prov, err := loadProvider(rCtx.registryContext, args[0])
+	printErrorAndExitOneIfError(err)
+	ps, err := prov.GetProviderService(args[1])
+	printErrorAndExitOneIfError(err)
+	resources, err := openapistackql.LoadProviderServiceResources(reg, ps)
+	printErrorAndExitOneIfError(err)
+	rsc, ok := resources[args[2]]
+	if !ok {
+		printErrorAndExitOneIfError(fmt.Errorf("cannot find resource '%s' in service '%s'", args[2], args[1]))
+	}
+	m, err := rsc.FindMethod(args[3])
+	printErrorAndExitOneIfError(err)
+	svc, ok := rsc.GetService()
+	if !ok {
+		printErrorAndExitOneIfError(fmt.Errorf("no service attached to resource '%s'", args[2]))
+	}
+	params, err := parseRenderParams(args[4:])
+	printErrorAndExitOneIfError(err)
+	var execCtx openapistackql.ExecContext
+	if rCtx.Body != "" {
+		execCtx, err = newRenderExecContext(m, rsc, rCtx.Body)
+		printErrorAndExitOneIfError(err)
+	}
+	requests, err := renderRequests(prov, svc, m, params, execCtx)
+	printErrorAndExitOneIfError(err)
+	switch rCtx.OutputFormat {
+	case "json":
+		b, err := json.MarshalIndent(requests, "", "  ")
+		printErrorAndExitOneIfError(err)
+		fmt.Fprintln(os.Stdout, string(b))
+	case "text", "":
+		for i, r := range requests {
+			if i > 0 {
+				fmt.Fprintln(os.Stdout, "---")
+			}
+			printRenderedRequest(os.Stdout, r)
+		}
+	default:
+		printErrorAndExitOneIfError(fmt.Errorf("unsupported output format '%s'", rCtx.OutputFormat))
+	}
+}
+
+func newRenderExecContext(m openapistackql.OperationStore, rsc openapistackql.Resource, body string) (openapistackql.ExecContext, error) {
+	var payloadMap map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &payloadMap); err != nil {
+		return nil, fmt.Errorf("request body must be a JSON object: %s", err.Error())
+	}
+	header := make(map[string][]string)
+	if req, ok := m.GetRequest(); ok && req.GetBodyMediaType() != "" {
+		header["Content-Type"] = []string{req.GetBodyMediaType()}
+	}
+	return openapistackql.NewExecContext(internaldto.NewExecPayload([]byte(body), header, payloadMap), rsc), nil
+}
+
+func renderRequests(
+	prov openapistackql.Provider,
+	svc openapistackql.Service,
+	m openapistackql.OperationStore,
+	params map[string]interface{},
+	execCtx openapistackql.ExecContext,
+) ([]renderedRequest, error) {
+	pr := openapistackql.NewHTTPPreparator(prov, svc, m, map[int]map[string]interface{}{0: params}, nil, execCtx, nil)
+	armoury, err := pr.BuildHTTPRequestCtx()
+	if err != nil {
+		return nil, err
+	}
+	translator, err := requesttranslate.NewRequestTranslator(m.GetRequestTranslateAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+	var rv []renderedRequest
+	for _, p := range armoury.GetRequestParams() {
+		req, err := translator.Translate(p.GetRequest())
+		if err != nil {
+			return nil, err
+		}
+		body, err := readRenderedBody(req, p.GetBodyBytes())
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, renderedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header,
+			Body:   string(body),
+		})
+	}
+	return rv, nil
+}
+
+func readRenderedBody(req *http.Request, fallback []byte) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return fallback, nil
+	}
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	if len(b) == 0 {
+		return fallback, nil
+	}
+	return b, nil
+}
+
+func printRenderedRequest(w io.Writer, r renderedRequest) {
+	fmt.Fprintf(w, "%s %s\n", r.Method, r.URL)
+	var keys []string
+	for k := range r.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range r.Header[k] {
+			fmt.Fprintf(w, "%s: %s\n", k, v)
+		}
+	}
+	if r.Body != "" {
+		fmt.Fprintf(w, "\n%s\n", r.Body)
+	}
+}
@@ -0,0 +1,103 @@
+package argparse
+
+import (
+	"testing"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+
+	"gotest.tools/assert"
+)
+
+func TestRenderOkta(t *testing.T) {
+	reg, prov, err := loadProvider(registryContext{}, loadOktaTestProviderPath(t))
+	assert.NilError(t, err)
+	ps, err := prov.GetProviderService("application")
+	assert.NilError(t, err)
+	resources, err := openapistackql.LoadProviderServiceResources(reg, ps)
+	assert.NilError(t, err)
+	apps, ok := resources["apps"]
+	assert.Assert(t, ok)
+	svc, ok := apps.GetService()
+	assert.Assert(t, ok)
+	for _, tc := range []struct {
+		name        string
+		method      string
+		args        []string
+		body        string
+		expected    []renderedRequest
+		expectedErr string
+	}{
+		{
+			name:   "get",
+			method: "get",
+			args:   []string{"subdomain=example", "appId=abc"},
+			expected: []renderedRequest{
+				{Method: "GET", URL: "https://example.okta.com/api/v1/apps/abc?", Header: map[string][]string{"Accept": {"application/json"}}},
+			},
+		},
+		{
+			name:   "insert with body attributes",
+			method: "insert",
+			args:   []string{"subdomain=example", openapistackql.RequestBodyBaseKey + "label=x", openapistackql.RequestBodyBaseKey + "signOnMode=BOOKMARK"},
+			expected: []renderedRequest{
+				{
+					Method: "POST",
+					URL:    "https://example.okta.com/api/v1/apps?",
+					Header: map[string][]string{"Accept": {"application/json"}, "Content-Type": {"application/json"}},
+					Body:   `{"label":"x","signOnMode":"BOOKMARK"}`,
+				},
+			},
+		},
+		{
+			name:   "insert with body",
+			method: "insert",
+			args:   []string{"subdomain=example"},
+			body:   `{"label":"x"}`,
+			expected: []renderedRequest{
+				{
+					Method: "POST",
+					URL:    "https://example.okta.com/api/v1/apps?",
+					Header: map[string][]string{"Accept": {"application/json"}, "Content-Type": {"application/json"}},
+					Body:   `{"label":"x"}`,
+				},
+			},
+		},
+		{
+			name:        "missing path parameter",
+			method:      "get",
+			args:        []string{"subdomain=example"},
+			expectedErr: "missing required path parameter 'appId'",
+		},
+		{
+			name:        "malformed parameter",
+			method:      "get",
+			args:        []string{"subdomain"},
+			expectedErr: "parameter 'subdomain' not of the form <key>=<value>",
+		},
+	} {
+		m, err := apps.FindMethod(tc.method)
+		assert.NilError(t, err, tc.name)
+		requests, err := func() ([]renderedRequest, error) {
+			params, err := parseRenderParams(tc.args)
+			if err != nil {
+				return nil, err
+			}
+			var execCtx openapistackql.ExecContext
+			if tc.body != "" {
+				execCtx, err = newRenderExecContext(m, apps, tc.body)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return renderRequests(prov, svc, m, params, execCtx)
+		}()
+		if tc.expectedErr != "" {
+			assert.ErrorContains(t, err, tc.expectedErr, tc.name)
+			continue
+		}
+		assert.NilError(t, err, tc.name)
+		assert.DeepEqual(t, requests, tc.expected)
+	}
+
+	t.Logf("TestRenderOkta passed")
+}
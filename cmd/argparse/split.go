@@ -0,0 +1,64 @@
+package argparse
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+)
+
+type splitContext struct {
+	DocDir        string
+	ResourcesFile string
+}
+
+var (
+	splitCtx splitContext
+)
+
+// splitCmd represents the split command
+var splitCmd = &cobra.Command{
+	Use:   "split <service-doc> <output-dir>",
+	Short: "Split a service doc into per resource docs",
+	Long: `Split a service doc into per resource docs.
+
+One minimal service doc per resource in 'x-stackQL-resources' is written
+to the output directory, keeping only the paths and components
+transitively referenced by that resource.  The matching resources file
+is written to '--resources-file', or else 'resources.yaml' in the output
+directory.
+
+Operation refs in the resources file are rooted at '--doc-dir', the
+registry relative location of the output directory, eg:
+'googleapis.com/v0.1.0/services-split/compute'.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			cmd.Help()
+			os.Exit(0)
+		}
+		RunSplitCommand(runtimeCtx, splitCtx, args[0], args[1])
+	},
+}
+
+func RunSplitCommand(rtCtx runtimeContext, sCtx splitContext, docPath string, outputDir string) {
+	b, err := os.ReadFile(docPath)
+	printErrorAndExitOneIfError(err)
+	split, err := openapistackql.SplitServiceDoc(b, openapistackql.ServiceSplitterConfig{DocDir: sCtx.DocDir})
+	printErrorAndExitOneIfError(err)
+	err = os.MkdirAll(outputDir, 0755)
+	printErrorAndExitOneIfError(err)
+	for p, db := range split.Docs {
+		err = os.WriteFile(filepath.Join(outputDir, path.Base(p)), db, openapistackql.ConfigFilesMode)
+		printErrorAndExitOneIfError(err)
+	}
+	resourcesFile := sCtx.ResourcesFile
+	if resourcesFile == "" {
+		resourcesFile = filepath.Join(outputDir, "resources.yaml")
+	}
+	err = os.WriteFile(resourcesFile, split.Resources, openapistackql.ConfigFilesMode)
+	printErrorAndExitOneIfError(err)
+}
@@ -0,0 +1,97 @@
+package argparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+)
+
+type validateContext struct {
+	DocRoot      string
+	OutputFormat string
+}
+
+var (
+	validateCtx validateContext
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Lint a provider doc tree",
+	Long: `Lint a provider doc tree.
+
+Accepts the path to a provider root directory, or to its 'provider.yaml',
+and resolves every service, resource, method, SQL verb, inverse,
+pagination token semantic and view reachable from it.
+All problems are reported, each with a file and JSON pointer location.
+Exits non-zero if any problem is found.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 || args[0] == "" {
+			cmd.Help()
+			os.Exit(0)
+		}
+		RunValidateCommand(runtimeCtx, validateCtx, args[0])
+	},
+}
+
+func resolveProviderDocPath(providerPath string, docRoot string) (string, string, error) {
+	fi, err := os.Stat(providerPath)
+	if err != nil {
+		return "", "", err
+	}
+	providerFile := providerPath
+	if fi.IsDir() {
+		providerFile = filepath.Join(providerPath, "provider.yaml")
+	}
+	providerFile, err = filepath.Abs(providerFile)
+	if err != nil {
+		return "", "", err
+	}
+	if docRoot == "" {
+		// provider docs live at <root>/<provider>/<version>/provider.yaml
+		docRoot = filepath.Dir(filepath.Dir(filepath.Dir(providerFile)))
+	}
+	docRoot, err = filepath.Abs(docRoot)
+	if err != nil {
+		return "", "", err
+	}
+	rel, err := filepath.Rel(docRoot, providerFile)
+	if err != nil {
+		return "", "", err
+	}
+	return docRoot, filepath.ToSlash(rel), nil
+}
+
+func RunValidateCommand(rtCtx runtimeContext, vCtx validateContext, providerPath string) {
+	docRoot, providerDocPath, err := resolveProviderDocPath(providerPath, vCtx.DocRoot)
+	printErrorAndExitOneIfError(err)
+	v := openapistackql.NewProviderValidator(docRoot)
+	findings, err := v.Validate(providerDocPath)
+	printErrorAndExitOneIfError(err)
+	switch vCtx.OutputFormat {
+	case "json":
+		if findings == nil {
+			findings = []openapistackql.ValidationFinding{}
+		}
+		b, err := json.MarshalIndent(findings, "", "  ")
+		printErrorAndExitOneIfError(err)
+		fmt.Fprintln(os.Stdout, string(b))
+	case "text", "":
+		for _, f := range findings {
+			fmt.Fprintln(os.Stdout, f.String())
+		}
+		fmt.Fprintf(os.Stdout, "\n%d problem(s) found in '%s'\n", len(findings), providerDocPath)
+	default:
+		printErrorAndExitOneIfError(fmt.Errorf("unsupported output format '%s'", vCtx.OutputFormat))
+	}
+	if len(findings) > 0 {
+		os.Exit(1)
+	}
+}
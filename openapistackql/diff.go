@@ -0,0 +1,245 @@
+package openapistackql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	_ ProviderDiffer = &standardProviderDiffer{}
+)
+
+type ProviderChangeKind string
+
+const (
+	ServiceAdded            ProviderChangeKind = "service_added"
+	ServiceRemoved          ProviderChangeKind = "service_removed"
+	ServiceUnresolved       ProviderChangeKind = "service_unresolved"
+	ResourceAdded           ProviderChangeKind = "resource_added"
+	ResourceRemoved         ProviderChangeKind = "resource_removed"
+	MethodAdded             ProviderChangeKind = "method_added"
+	MethodRemoved           ProviderChangeKind = "method_removed"
+	SQLVerbChanged          ProviderChangeKind = "sql_verb_changed"
+	RequiredParamAdded      ProviderChangeKind = "required_param_added"
+	RequiredParamRemoved    ProviderChangeKind = "required_param_removed"
+	ColumnAdded             ProviderChangeKind = "column_added"
+	ColumnRemoved           ProviderChangeKind = "column_removed"
+	ColumnRetyped           ProviderChangeKind = "column_retyped"
+	SelectColumnsUnresolved ProviderChangeKind = "select_columns_unresolved"
+)
+
+// ProviderChange is a single semantic difference between two provider versions.
+// Path is the dot delimited location of the change, eg: 'compute.instances.list.status'.
+type ProviderChange struct {
+	Kind     ProviderChangeKind `json:"kind" yaml:"kind"`
+	Path     string             `json:"path" yaml:"path"`
+	Detail   string             `json:"detail,omitempty" yaml:"detail,omitempty"`
+	Breaking bool               `json:"breaking" yaml:"breaking"`
+}
+
+func (pc ProviderChange) String() string {
+	classification := "non-breaking"
+	if pc.Breaking {
+		classification = "breaking"
+	}
+	if pc.Detail == "" {
+		return fmt.Sprintf("[%s] %s: %s", classification, pc.Kind, pc.Path)
+	}
+	return fmt.Sprintf("[%s] %s: %s (%s)", classification, pc.Kind, pc.Path, pc.Detail)
+}
+
+type ProviderDiffer interface {
+	Diff(lhs Provider, rhs Provider) ([]ProviderChange, error)
+}
+
+type standardProviderDiffer struct {
+	lhsRegistry RegistryAPI
+	rhsRegistry RegistryAPI
+	changes     []ProviderChange
+}
+
+// NewProviderDiffer returns a differ which resolves the
+// older (lhs) and newer (rhs) provider through the respective registries.
+func NewProviderDiffer(lhsRegistry RegistryAPI, rhsRegistry RegistryAPI) ProviderDiffer {
+	return &standardProviderDiffer{
+		lhsRegistry: lhsRegistry,
+		rhsRegistry: rhsRegistry,
+	}
+}
+
+// HasBreakingChanges returns true if any of the supplied changes is breaking.
+func HasBreakingChanges(changes []ProviderChange) bool {
+	for _, c := range changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+func (pd *standardProviderDiffer) addChange(kind ProviderChangeKind, breaking bool, detail string, pathElems ...string) {
+	pd.changes = append(pd.changes, ProviderChange{
+		Kind:     kind,
+		Path:     strings.Join(pathElems, "."),
+		Detail:   detail,
+		Breaking: breaking,
+	})
+}
+
+func (pd *standardProviderDiffer) Diff(lhs Provider, rhs Provider) ([]ProviderChange, error) {
+	if lhs == nil || rhs == nil {
+		return nil, fmt.Errorf("cannot diff nil provider")
+	}
+	pd.changes = nil
+	lhsServices := lhs.GetProviderServices()
+	rhsServices := rhs.GetProviderServices()
+	for _, k := range unionKeys(lhsServices, rhsServices) {
+		lps, lok := lhsServices[k]
+		rps, rok := rhsServices[k]
+		switch {
+		case !rok:
+			pd.addChange(ServiceRemoved, true, "", k)
+		case !lok:
+			pd.addChange(ServiceAdded, false, "", k)
+		default:
+			pd.diffProviderService(k, lps, rps)
+		}
+	}
+	return pd.changes, nil
+}
+
+func (pd *standardProviderDiffer) diffProviderService(svcKey string, lhs, rhs ProviderService) {
+	lhsResources, lErr := LoadProviderServiceResources(pd.lhsRegistry, lhs)
+	rhsResources, rErr := LoadProviderServiceResources(pd.rhsRegistry, rhs)
+	if lErr != nil || rErr != nil {
+		var errStrs []string
+		if lErr != nil {
+			errStrs = append(errStrs, fmt.Sprintf("lhs: %s", lErr.Error()))
+		}
+		if rErr != nil {
+			errStrs = append(errStrs, fmt.Sprintf("rhs: %s", rErr.Error()))
+		}
+		pd.addChange(ServiceUnresolved, false, strings.Join(errStrs, "; "), svcKey)
+		return
+	}
+	for _, k := range unionKeys(lhsResources, rhsResources) {
+		lr, lok := lhsResources[k]
+		rr, rok := rhsResources[k]
+		switch {
+		case !rok:
+			pd.addChange(ResourceRemoved, true, "", svcKey, k)
+		case !lok:
+			pd.addChange(ResourceAdded, false, "", svcKey, k)
+		default:
+			pd.diffResource(svcKey, k, lr, rr)
+		}
+	}
+}
+
+func (pd *standardProviderDiffer) diffResource(svcKey, rscKey string, lhs, rhs Resource) {
+	lhsMethods := lhs.GetMethodsMatched()
+	rhsMethods := rhs.GetMethodsMatched()
+	for _, k := range unionKeys(lhsMethods, rhsMethods) {
+		lm, lok := lhsMethods[k]
+		rm, rok := rhsMethods[k]
+		switch {
+		case !rok:
+			pd.addChange(MethodRemoved, true, "", svcKey, rscKey, k)
+		case !lok:
+			pd.addChange(MethodAdded, false, "", svcKey, rscKey, k)
+		default:
+			pd.diffMethod(svcKey, rscKey, k, &lm, &rm)
+		}
+	}
+}
+
+func (pd *standardProviderDiffer) diffMethod(svcKey, rscKey, methodKey string, lhs, rhs OperationStore) {
+	lhsVerb := strings.ToLower(lhs.GetSQLVerb())
+	rhsVerb := strings.ToLower(rhs.GetSQLVerb())
+	if lhsVerb != rhsVerb {
+		pd.addChange(SQLVerbChanged, true, fmt.Sprintf("'%s' -> '%s'", lhsVerb, rhsVerb), svcKey, rscKey, methodKey)
+	}
+	lhsRequired := getPresentedRequiredParams(lhs)
+	rhsRequired := getPresentedRequiredParams(rhs)
+	for _, k := range unionKeys(lhsRequired, rhsRequired) {
+		_, lok := lhsRequired[k]
+		_, rok := rhsRequired[k]
+		switch {
+		case !rok:
+			pd.addChange(RequiredParamRemoved, false, "", svcKey, rscKey, methodKey, k)
+		case !lok:
+			pd.addChange(RequiredParamAdded, true, "", svcKey, rscKey, methodKey, k)
+		}
+	}
+	if lhsVerb != "select" || rhsVerb != "select" {
+		return
+	}
+	lhsCols, lErr := getSelectColumnTypes(lhs)
+	rhsCols, rErr := getSelectColumnTypes(rhs)
+	if lErr != nil || rErr != nil {
+		if (lErr == nil) != (rErr == nil) {
+			pd.addChange(SelectColumnsUnresolved, rErr != nil, "", svcKey, rscKey, methodKey)
+		}
+		return
+	}
+	for _, k := range unionKeys(lhsCols, rhsCols) {
+		lt, lok := lhsCols[k]
+		rt, rok := rhsCols[k]
+		switch {
+		case !rok:
+			pd.addChange(ColumnRemoved, true, "", svcKey, rscKey, methodKey, k)
+		case !lok:
+			pd.addChange(ColumnAdded, false, "", svcKey, rscKey, methodKey, k)
+		case lt != rt:
+			pd.addChange(ColumnRetyped, true, fmt.Sprintf("'%s' -> '%s'", lt, rt), svcKey, rscKey, methodKey, k)
+		}
+	}
+}
+
+func getPresentedRequiredParams(m OperationStore) map[string]struct{} {
+	rv := make(map[string]struct{})
+	s, _ := m.ToPresentationMap(false)[RequiredParams].(string)
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			rv[p] = struct{}{}
+		}
+	}
+	return rv
+}
+
+func getSelectColumnTypes(m OperationStore) (map[string]string, error) {
+	schema, _, err := m.GetSelectSchemaAndObjectPath()
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("no select schema for method '%s'", m.GetName())
+	}
+	rv := make(map[string]string)
+	for _, col := range schema.Tabulate(false).GetColumns() {
+		colType := ""
+		if s := col.GetSchema(); s != nil {
+			colType = s.GetType()
+		}
+		rv[col.GetName()] = colType
+	}
+	return rv, nil
+}
+
+func unionKeys[V any](lhs, rhs map[string]V) []string {
+	keys := make(map[string]struct{}, len(lhs)+len(rhs))
+	for k := range lhs {
+		keys[k] = struct{}{}
+	}
+	for k := range rhs {
+		keys[k] = struct{}{}
+	}
+	rv := make([]string, 0, len(keys))
+	for k := range keys {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
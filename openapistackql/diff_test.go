@@ -0,0 +1,74 @@
+package openapistackql_test
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
+
+	"gotest.tools/assert"
+)
+
+func writeContrivedProviderVersion(t *testing.T, docRoot string, version string, mutate func(string) string) {
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry", "src"))
+	assert.NilError(t, err)
+	for _, p := range []string{"provider.yaml", "services/contrived_service.yaml"} {
+		b, err := os.ReadFile(filepath.Join(srcRoot, "contrivedprovider", "v0.1.0", p))
+		assert.NilError(t, err)
+		s := strings.ReplaceAll(string(b), "contrivedprovider/v0.1.0/", fmt.Sprintf("contrivedprovider/%s/", version))
+		if mutate != nil {
+			s = mutate(s)
+		}
+		dst := filepath.Join(docRoot, "contrivedprovider", version, p)
+		assert.NilError(t, os.MkdirAll(filepath.Dir(dst), 0755))
+		assert.NilError(t, os.WriteFile(dst, []byte(s), 0644))
+	}
+}
+
+func TestDiffContrivedProviderVersions(t *testing.T) {
+	docRoot := t.TempDir()
+	writeContrivedProviderVersion(t, docRoot, "v0.1.0", nil)
+	writeContrivedProviderVersion(t, docRoot, "v0.1.1", func(s string) string {
+		s = strings.Replace(s, "        custom_404:\n          type: boolean", "        custom_404:\n          type: string", 1)
+		return strings.Replace(s, "        html_url:\n", "        homepage_url:\n", 1)
+	})
+	srcPrefix := ""
+	r, err := NewRegistry(
+		RegistryConfig{
+			RegistryURL:   fmt.Sprintf("file://%s", filepath.ToSlash(docRoot)),
+			SrcPrefix:     &srcPrefix,
+			VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+		},
+		nil,
+	)
+	assert.NilError(t, err)
+	lhs, err := r.LoadProviderByName("contrivedprovider", "v0.1.0")
+	assert.NilError(t, err)
+	rhs, err := r.LoadProviderByName("contrivedprovider", "v0.1.1")
+	assert.NilError(t, err)
+
+	changes, err := NewProviderDiffer(r, r).Diff(lhs, lhs)
+	assert.NilError(t, err)
+	assert.Equal(t, len(changes), 0)
+
+	changes, err = NewProviderDiffer(r, r).Diff(lhs, rhs)
+	assert.NilError(t, err)
+	changeMap := make(map[string]ProviderChange)
+	for _, c := range changes {
+		changeMap[c.Path] = c
+	}
+	assert.Equal(t, len(changes), 3)
+	assert.Equal(t, changeMap["contrived_service.pages.get_pages.custom_404"].Kind, ColumnRetyped)
+	assert.Equal(t, changeMap["contrived_service.pages.get_pages.html_url"].Kind, ColumnRemoved)
+	assert.Equal(t, changeMap["contrived_service.pages.get_pages.homepage_url"].Kind, ColumnAdded)
+	assert.Assert(t, !changeMap["contrived_service.pages.get_pages.homepage_url"].Breaking)
+	assert.Assert(t, HasBreakingChanges(changes))
+
+	t.Logf("TestDiffContrivedProviderVersions passed")
+}
@@ -0,0 +1,550 @@
+package openapistackql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	discoveryDefaultProviderName string = "google"
+	discoveryMediaType           string = "application/json"
+	discoveryResponseKey         string = "200"
+	discoveryPageTokenParam      string = "pageToken"
+	discoveryNextPageTokenProp   string = "nextPageToken"
+	discoveryAggregatedList      string = "aggregatedList"
+	discoverySecuritySchemeKey   string = "Oauth2"
+	discoveryAuthorizationURL    string = "https://accounts.google.com/o/oauth2/auth"
+)
+
+// discoverySQLVerbs maps Discovery method names onto SQL verbs;
+// other methods are only reachable through EXEC.
+var discoverySQLVerbs = map[string]string{
+	"get":                   "select",
+	"list":                  "select",
+	discoveryAggregatedList: "select",
+	"insert":                "insert",
+	"create":                "insert",
+	"patch":                 "update",
+	"update":                "update",
+	"delete":                "delete",
+}
+
+var discoveryPathParamRegex = regexp.MustCompile(`{\+?([^}]+)}`)
+
+// DiscoveryConverterConfig parameterises ConvertDiscoveryDoc.
+type DiscoveryConverterConfig struct {
+	// ProviderName prefixes resource ids, eg: 'google.compute.instances'; defaults to 'google'.
+	ProviderName string
+}
+
+type discoveryDoc struct {
+	Name        string                        `json:"name"`
+	Version     string                        `json:"version"`
+	Title       string                        `json:"title"`
+	Description string                        `json:"description"`
+	RootURL     string                        `json:"rootUrl"`
+	ServicePath string                        `json:"servicePath"`
+	Parameters  map[string]*discoveryParam    `json:"parameters"`
+	Schemas     map[string]*discoverySchema   `json:"schemas"`
+	Resources   map[string]*discoveryResource `json:"resources"`
+	Auth        struct {
+		OAuth2 struct {
+			Scopes map[string]struct {
+				Description string `json:"description"`
+			} `json:"scopes"`
+		} `json:"oauth2"`
+	} `json:"auth"`
+}
+
+type discoveryResource struct {
+	Methods   map[string]*discoveryMethod   `json:"methods"`
+	Resources map[string]*discoveryResource `json:"resources"`
+}
+
+type discoveryMethod struct {
+	ID          string                     `json:"id"`
+	Path        string                     `json:"path"`
+	FlatPath    string                     `json:"flatPath"`
+	HTTPMethod  string                     `json:"httpMethod"`
+	Description string                     `json:"description"`
+	Parameters  map[string]*discoveryParam `json:"parameters"`
+	Request     *discoverySchema           `json:"request"`
+	Response    *discoverySchema           `json:"response"`
+	Scopes      []string                   `json:"scopes"`
+}
+
+type discoveryParam struct {
+	discoverySchema
+	Location string `json:"location"`
+	Required bool   `json:"required"`
+	Repeated bool   `json:"repeated"`
+}
+
+type discoverySchema struct {
+	Ref                  string                      `json:"$ref"`
+	Type                 string                      `json:"type"`
+	Format               string                      `json:"format"`
+	Description          string                      `json:"description"`
+	Pattern              string                      `json:"pattern"`
+	Enum                 []string                    `json:"enum"`
+	Default              interface{}                 `json:"default"`
+	ReadOnly             bool                        `json:"readOnly"`
+	Deprecated           bool                        `json:"deprecated"`
+	Items                *discoverySchema            `json:"items"`
+	Properties           map[string]*discoverySchema `json:"properties"`
+	AdditionalProperties *discoverySchema            `json:"additionalProperties"`
+}
+
+// ConvertDiscoveryDoc converts a Google Discovery doc into an OpenAPI 3 service doc, in yaml,
+// including 'x-stackQL-resources': one resource per Discovery resource, keyed by its dotted path,
+// eg: 'projects.locations.clusters', with select, insert, update and delete verbs
+// assigned to the conventionally named methods.
+func ConvertDiscoveryDoc(b []byte, cfg DiscoveryConverterConfig) ([]byte, error) {
+	var dd discoveryDoc
+	if err := json.Unmarshal(b, &dd); err != nil {
+		return nil, fmt.Errorf("cannot read discovery doc: %s", err.Error())
+	}
+	if dd.Name == "" || dd.RootURL == "" {
+		return nil, fmt.Errorf("cannot read discovery doc: 'name' and 'rootUrl' are required")
+	}
+	if cfg.ProviderName == "" {
+		cfg.ProviderName = discoveryDefaultProviderName
+	}
+	c := &discoveryConverter{
+		cfg:   cfg,
+		doc:   &dd,
+		paths: make(map[string]map[string]interface{}),
+		rscs:  make(map[string]interface{}),
+	}
+	rv, err := c.convert()
+	if err != nil {
+		return nil, err
+	}
+	return marshalServiceDocYAML(rv)
+}
+
+// marshalServiceDocYAML indents as do the docs of the provider registry.
+func marshalServiceDocYAML(doc interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type discoveryConverter struct {
+	cfg   DiscoveryConverterConfig
+	doc   *discoveryDoc
+	paths map[string]map[string]interface{}
+	rscs  map[string]interface{}
+}
+
+func (c *discoveryConverter) convert() (map[string]interface{}, error) {
+	dd := c.doc
+	components := map[string]interface{}{}
+	schemas := make(map[string]interface{}, len(dd.Schemas))
+	for k, s := range dd.Schemas {
+		schemas[k] = discoverySchemaToOpenapi(s)
+	}
+	components["schemas"] = schemas
+	if len(dd.Parameters) > 0 {
+		params := make(map[string]interface{}, len(dd.Parameters))
+		for k, p := range dd.Parameters {
+			params[discoveryParamComponentKey(k)] = discoveryParamToOpenapi(k, p)
+		}
+		components["parameters"] = params
+	}
+	if len(dd.Auth.OAuth2.Scopes) > 0 {
+		scopes := make(map[string]interface{}, len(dd.Auth.OAuth2.Scopes))
+		for k, v := range dd.Auth.OAuth2.Scopes {
+			scopes[k] = v.Description
+		}
+		components["securitySchemes"] = map[string]interface{}{
+			discoverySecuritySchemeKey: map[string]interface{}{
+				"type": "oauth2",
+				"flows": map[string]interface{}{
+					"implicit": map[string]interface{}{
+						"authorizationUrl": discoveryAuthorizationURL,
+						"scopes":           scopes,
+					},
+				},
+			},
+		}
+	}
+	for _, k := range sortedKeys(dd.Resources) {
+		if err := c.convertResource(k, dd.Resources[k]); err != nil {
+			return nil, err
+		}
+	}
+	components[ExtensionKeyResources] = c.rscs
+	paths := make(map[string]interface{}, len(c.paths))
+	for k, v := range c.paths {
+		paths[k] = v
+	}
+	info := map[string]interface{}{
+		"title":   dd.Title,
+		"version": dd.Version,
+	}
+	if dd.Description != "" {
+		info["description"] = dd.Description
+	}
+	return map[string]interface{}{
+		"openapi": convertedOpenapiVersion,
+		"info":    info,
+		"servers": []interface{}{
+			map[string]interface{}{"url": strings.TrimSuffix(dd.RootURL+dd.ServicePath, "/")},
+		},
+		"paths":      paths,
+		"components": components,
+	}, nil
+}
+
+func (c *discoveryConverter) convertResource(key string, dr *discoveryResource) error {
+	tag := strings.Split(key, ".")[0]
+	if len(dr.Methods) > 0 {
+		methods := make(map[string]interface{}, len(dr.Methods))
+		sqlVerbs := map[string][]string{"select": {}, "insert": {}, "update": {}, "delete": {}}
+		paginated := false
+		for _, methodName := range sortedKeys(dr.Methods) {
+			dm := dr.Methods[methodName]
+			path, verb, err := c.addOperation(tag, dm)
+			if err != nil {
+				return fmt.Errorf("resource '%s' method '%s': %s", key, methodName, err.Error())
+			}
+			method := map[string]interface{}{
+				"operation": map[string]interface{}{
+					"$ref": fmt.Sprintf("#/paths/%s/%s", escapeJSONPointerToken(path), verb),
+				},
+			}
+			if dm.Request != nil {
+				method["request"] = map[string]interface{}{"mediaType": discoveryMediaType}
+			}
+			if dm.Response != nil {
+				response := map[string]interface{}{
+					"mediaType":     discoveryMediaType,
+					"openAPIDocKey": discoveryResponseKey,
+				}
+				objectKey, ok, err := c.getObjectKey(methodName, dm.Response)
+				if err != nil {
+					return fmt.Errorf("resource '%s' method '%s': %s", key, methodName, err.Error())
+				}
+				if ok {
+					response["objectKey"] = objectKey
+				}
+				method["response"] = response
+				hasNextPageToken, err := c.hasProperty(dm.Response, discoveryNextPageTokenProp)
+				if err != nil {
+					return fmt.Errorf("resource '%s' method '%s': %s", key, methodName, err.Error())
+				}
+				if _, ok := dm.Parameters[discoveryPageTokenParam]; ok && hasNextPageToken {
+					paginated = true
+				}
+			}
+			methods[methodName] = method
+			if sqlVerb, ok := discoverySQLVerbs[methodName]; ok {
+				sqlVerbs[sqlVerb] = append(sqlVerbs[sqlVerb], methodName)
+			}
+		}
+		verbRefs := make(map[string]interface{}, len(sqlVerbs))
+		for sqlVerb, methodNames := range sqlVerbs {
+			// the most specific method is preferred where several match
+			sort.SliceStable(methodNames, func(i, j int) bool {
+				return countRequiredParams(dr.Methods[methodNames[i]]) > countRequiredParams(dr.Methods[methodNames[j]])
+			})
+			refs := make([]interface{}, 0, len(methodNames))
+			for _, methodName := range methodNames {
+				refs = append(refs, map[string]interface{}{
+					"$ref": fmt.Sprintf("#/components/%s/%s/methods/%s", ExtensionKeyResources, escapeJSONPointerToken(key), methodName),
+				})
+			}
+			verbRefs[sqlVerb] = refs
+		}
+		rsc := map[string]interface{}{
+			"id":       fmt.Sprintf("%s.%s.%s", c.cfg.ProviderName, c.doc.Name, key),
+			"name":     key,
+			"title":    key,
+			"methods":  methods,
+			"sqlVerbs": verbRefs,
+		}
+		if paginated {
+			rsc["config"] = map[string]interface{}{
+				"pagination": map[string]interface{}{
+					"requestToken":  map[string]interface{}{"key": discoveryPageTokenParam, "location": "query"},
+					"responseToken": map[string]interface{}{"key": "$." + discoveryNextPageTokenProp, "location": "body"},
+				},
+			}
+		}
+		c.rscs[key] = rsc
+	}
+	for _, k := range sortedKeys(dr.Resources) {
+		if err := c.convertResource(key+"."+k, dr.Resources[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addOperation adds the method to the doc's paths, returning its path and verb.
+// The flat path is preferred, as path templates such as 'v1/{+name}' span several segments;
+// its parameters are then named as in the flat path.
+func (c *discoveryConverter) addOperation(tag string, dm *discoveryMethod) (string, string, error) {
+	rawPath := dm.FlatPath
+	if rawPath == "" {
+		rawPath = dm.Path
+	}
+	if rawPath == "" || dm.HTTPMethod == "" {
+		return "", "", fmt.Errorf("method '%s' has no path or http method", dm.ID)
+	}
+	path := "/" + strings.TrimPrefix(discoveryPathParamRegex.ReplaceAllString(rawPath, "{$1}"), "/")
+	verb := strings.ToLower(dm.HTTPMethod)
+	var params []interface{}
+	for _, k := range sortedKeys(dm.Parameters) {
+		p := dm.Parameters[k]
+		if p.Location == "path" {
+			continue
+		}
+		params = append(params, discoveryParamToOpenapi(k, p))
+	}
+	for _, m := range discoveryPathParamRegex.FindAllStringSubmatch(rawPath, -1) {
+		p, ok := dm.Parameters[m[1]]
+		if !ok || p.Location != "path" {
+			p = &discoveryParam{discoverySchema: discoverySchema{Type: "string"}, Location: "path"}
+		}
+		p.Required = true
+		params = append(params, discoveryParamToOpenapi(m[1], p))
+	}
+	op := map[string]interface{}{
+		"operationId": dm.ID,
+		"tags":        []interface{}{tag},
+		"responses":   map[string]interface{}{discoveryResponseKey: c.responseToOpenapi(dm.Response)},
+	}
+	if dm.Description != "" {
+		op["description"] = dm.Description
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if dm.Request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{
+				discoveryMediaType: map[string]interface{}{"schema": discoverySchemaToOpenapi(dm.Request)},
+			},
+		}
+	}
+	if len(dm.Scopes) > 0 {
+		op["security"] = []interface{}{
+			map[string]interface{}{discoverySecuritySchemeKey: dm.Scopes},
+		}
+	}
+	pathItem, ok := c.paths[path]
+	if !ok {
+		pathItem = make(map[string]interface{})
+		if len(c.doc.Parameters) > 0 {
+			var refs []interface{}
+			for _, k := range sortedKeys(c.doc.Parameters) {
+				refs = append(refs, map[string]interface{}{"$ref": "#/components/parameters/" + discoveryParamComponentKey(k)})
+			}
+			pathItem["parameters"] = refs
+		}
+		c.paths[path] = pathItem
+	}
+	if _, exists := pathItem[verb]; exists {
+		return "", "", fmt.Errorf("method '%s' collides with another on '%s %s'", dm.ID, verb, path)
+	}
+	pathItem[verb] = op
+	return path, verb, nil
+}
+
+func (c *discoveryConverter) responseToOpenapi(s *discoverySchema) map[string]interface{} {
+	rv := map[string]interface{}{"description": "Successful response"}
+	if s != nil {
+		rv["content"] = map[string]interface{}{
+			discoveryMediaType: map[string]interface{}{"schema": discoverySchemaToOpenapi(s)},
+		}
+	}
+	return rv
+}
+
+// resolve follows schema refs; a ref which is absent from the doc, or which refers back to itself, is an error.
+func (c *discoveryConverter) resolve(s *discoverySchema) (*discoverySchema, error) {
+	var visited map[string]struct{}
+	for s != nil && s.Ref != "" {
+		if _, ok := visited[s.Ref]; ok {
+			return nil, fmt.Errorf("schema $ref '%s' is cyclic", s.Ref)
+		}
+		if visited == nil {
+			visited = make(map[string]struct{})
+		}
+		visited[s.Ref] = struct{}{}
+		resolved, ok := c.doc.Schemas[s.Ref]
+		if !ok || resolved == nil {
+			return nil, fmt.Errorf("schema $ref '%s' does not resolve", s.Ref)
+		}
+		s = resolved
+	}
+	return s, nil
+}
+
+func (c *discoveryConverter) hasProperty(s *discoverySchema, name string) (bool, error) {
+	s, err := c.resolve(s)
+	if err != nil || s == nil {
+		return false, err
+	}
+	_, ok := s.Properties[name]
+	return ok, nil
+}
+
+// getObjectKey locates the listed items in a response, eg: '$.items' or, for an aggregatedList,
+// whose 'items' maps scopes onto lists, '$.items.*.instances[*]'.
+func (c *discoveryConverter) getObjectKey(methodName string, response *discoverySchema) (string, bool, error) {
+	s, err := c.resolve(response)
+	if err != nil || s == nil {
+		return "", false, err
+	}
+	if methodName == discoveryAggregatedList {
+		items, ok := s.Properties["items"]
+		if !ok {
+			return "", false, nil
+		}
+		items, err = c.resolve(items)
+		if err != nil || items == nil {
+			return "", false, err
+		}
+		scoped, err := c.resolve(items.AdditionalProperties)
+		if err != nil || scoped == nil {
+			return "", false, err
+		}
+		k, ok, err := c.getArrayProperty(scoped)
+		if err != nil || !ok {
+			return "", false, err
+		}
+		return fmt.Sprintf("$.items.*.%s[*]", k), true, nil
+	}
+	if !strings.HasPrefix(methodName, "list") {
+		return "", false, nil
+	}
+	k, ok, err := c.getArrayProperty(s)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	return "$." + k, true, nil
+}
+
+// getArrayProperty prefers 'items', else the first array property by name.
+func (c *discoveryConverter) getArrayProperty(s *discoverySchema) (string, bool, error) {
+	if items, ok := s.Properties["items"]; ok {
+		resolved, err := c.resolve(items)
+		if err != nil {
+			return "", false, err
+		}
+		if resolved != nil && resolved.Type == "array" {
+			return "items", true, nil
+		}
+	}
+	for _, k := range sortedKeys(s.Properties) {
+		prop, err := c.resolve(s.Properties[k])
+		if err != nil {
+			return "", false, err
+		}
+		if prop != nil && prop.Type == "array" {
+			return k, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func countRequiredParams(dm *discoveryMethod) int {
+	rv := 0
+	for _, p := range dm.Parameters {
+		if p.Required {
+			rv++
+		}
+	}
+	return rv
+}
+
+// discoveryParamComponentKey avoids the '$' of eg: '$.xgafv' in component keys.
+func discoveryParamComponentKey(name string) string {
+	return strings.ReplaceAll(name, "$", "_")
+}
+
+func discoveryParamToOpenapi(name string, p *discoveryParam) map[string]interface{} {
+	schema := discoverySchemaToOpenapi(&p.discoverySchema)
+	delete(schema, "description")
+	if p.Repeated {
+		schema = map[string]interface{}{"type": "array", "items": schema}
+	}
+	rv := map[string]interface{}{
+		"name":   name,
+		"in":     p.Location,
+		"schema": schema,
+	}
+	if p.Description != "" {
+		rv["description"] = p.Description
+	}
+	if p.Required {
+		rv["required"] = true
+	}
+	return rv
+}
+
+func discoverySchemaToOpenapi(s *discoverySchema) map[string]interface{} {
+	if s.Ref != "" {
+		return map[string]interface{}{"$ref": schemaRefPrefix + escapeJSONPointerToken(s.Ref)}
+	}
+	rv := make(map[string]interface{})
+	if s.Type != "" && s.Type != "any" {
+		rv["type"] = s.Type
+	}
+	if s.Format != "" {
+		rv["format"] = s.Format
+	}
+	if s.Description != "" {
+		rv["description"] = s.Description
+	}
+	if s.Pattern != "" {
+		rv["pattern"] = s.Pattern
+	}
+	if len(s.Enum) > 0 {
+		enum := make([]interface{}, 0, len(s.Enum))
+		for _, v := range s.Enum {
+			enum = append(enum, v)
+		}
+		rv["enum"] = enum
+	}
+	// Discovery defaults are strings, whatever the type
+	if s.Default != nil && s.Type == "string" {
+		rv["default"] = s.Default
+	}
+	if s.ReadOnly {
+		rv["readOnly"] = true
+	}
+	if s.Deprecated {
+		rv["deprecated"] = true
+	}
+	if s.Items != nil {
+		rv["items"] = discoverySchemaToOpenapi(s.Items)
+	}
+	if len(s.Properties) > 0 {
+		props := make(map[string]interface{}, len(s.Properties))
+		for k, v := range s.Properties {
+			props[k] = discoverySchemaToOpenapi(v)
+		}
+		rv["properties"] = props
+	}
+	if s.AdditionalProperties != nil {
+		rv["additionalProperties"] = discoverySchemaToOpenapi(s.AdditionalProperties)
+	}
+	return rv
+}
@@ -0,0 +1,118 @@
+package openapistackql_test
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/PaesslerAG/jsonpath"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+
+	"gotest.tools/assert"
+)
+
+func loadDiscoveryTestService(t *testing.T) Service {
+	docPath, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "input", "discovery", "compute-v1.json"))
+	assert.NilError(t, err)
+	b, err := os.ReadFile(docPath)
+	assert.NilError(t, err)
+	converted, err := ConvertDiscoveryDoc(b, DiscoveryConverterConfig{})
+	assert.NilError(t, err)
+	svc, err := NewLoader().LoadFromBytes(converted)
+	assert.NilError(t, err)
+	return svc
+}
+
+func TestConvertDiscoveryDoc(t *testing.T) {
+	svc := loadDiscoveryTestService(t)
+	assert.Equal(t, svc.GetT().Servers[0].URL, "https://compute.googleapis.com/compute/v1")
+
+	rscs, err := svc.GetResources()
+	assert.NilError(t, err)
+	assert.Equal(t, len(rscs), 3)
+
+	at, err := svc.GetResource("acceleratorTypes")
+	assert.NilError(t, err)
+	assert.Equal(t, at.GetID(), "google.compute.acceleratorTypes")
+	os, remainingParams, ok := at.GetFirstMethodMatchFromSQLVerb("select", map[string]interface{}{"project": struct{}{}})
+	assert.Assert(t, ok)
+	assert.Assert(t, len(remainingParams) == 0)
+	assert.Equal(t, os.GetOperationRef().Value.OperationID, "compute.acceleratorTypes.aggregatedList")
+	assert.Equal(t, os.GetSelectItemsKey(), "$.items.*.acceleratorTypes[*]")
+	os, _, ok = at.GetFirstMethodMatchFromSQLVerb("select", map[string]interface{}{"project": struct{}{}, "zone": struct{}{}, "acceleratorType": struct{}{}})
+	assert.Assert(t, ok)
+	assert.Equal(t, os.GetOperationRef().Value.OperationID, "compute.acceleratorTypes.get")
+	ts, ok := at.GetPaginationRequestTokenSemantic()
+	assert.Assert(t, ok)
+	assert.Equal(t, ts.GetKey(), "pageToken")
+	ts, ok = at.GetPaginationResponseTokenSemantic()
+	assert.Assert(t, ok)
+	assert.Equal(t, ts.GetKey(), "$.nextPageToken")
+
+	// the aggregated object key flattens scoped lists
+	var aggregated interface{}
+	assert.NilError(t, json.Unmarshal([]byte(`{"items": {"zones/us-east1-b": {"acceleratorTypes": [{"name": "nvidia-tesla-t4"}]}, "zones/us-west1-a": {"warning": {"message": "none"}}}}`), &aggregated))
+	selected, err := jsonpath.Get("$.items.*.acceleratorTypes[*]", aggregated)
+	assert.NilError(t, err)
+	assert.Equal(t, len(selected.([]interface{})), 1)
+
+	instances, err := svc.GetResource("instances")
+	assert.NilError(t, err)
+	os, _, ok = instances.GetFirstMethodMatchFromSQLVerb("insert", map[string]interface{}{"project": struct{}{}, "zone": struct{}{}})
+	assert.Assert(t, ok)
+	assert.Equal(t, os.GetOperationRef().Value.OperationID, "compute.instances.insert")
+	os, _, ok = instances.GetFirstMethodMatchFromSQLVerb("select", map[string]interface{}{"project": struct{}{}, "zone": struct{}{}})
+	assert.Assert(t, ok)
+	assert.Equal(t, os.GetSelectItemsKey(), "$.items")
+	_, err = instances.FindMethod("start")
+	assert.NilError(t, err)
+	_, _, ok = instances.GetFirstMethodMatchFromSQLVerb("update", map[string]interface{}{"project": struct{}{}, "zone": struct{}{}, "instance": struct{}{}})
+	assert.Assert(t, !ok)
+
+	// flat paths name path parameters by segment
+	reservations, err := svc.GetResource("projects.locations.reservations")
+	assert.NilError(t, err)
+	get, err := reservations.FindMethod("get")
+	assert.NilError(t, err)
+	op := get.GetOperationRef().Value
+	assert.Assert(t, op.Parameters.GetByInAndName("path", "projectsId") != nil)
+	assert.Assert(t, op.Parameters.GetByInAndName("path", "name") == nil)
+	assert.Equal(t, op.Parameters.GetByInAndName("query", "view").Schema.Value.Type, "array")
+
+	t.Logf("TestConvertDiscoveryDoc passed")
+}
+
+func TestConvertDiscoveryDocUnresolvedRefs(t *testing.T) {
+	for schemas, expected := range map[string]string{
+		`{"WidgetList": {"type": "object", "properties": {"items": {"$ref": "Missing"}}}}`: "resource 'widgets' method 'list': schema $ref 'Missing' does not resolve",
+		`{"WidgetList": {"$ref": "Widgets"}, "Widgets": {"$ref": "WidgetList"}}`:           "resource 'widgets' method 'list': schema $ref 'WidgetList' is cyclic",
+	} {
+		doc := `{
+  "name": "widgets",
+  "version": "v1",
+  "rootUrl": "https://widgets.googleapis.com/",
+  "servicePath": "v1/",
+  "schemas": ` + schemas + `,
+  "resources": {
+    "widgets": {
+      "methods": {
+        "list": {
+          "id": "widgets.widgets.list",
+          "path": "projects/{project}/widgets",
+          "httpMethod": "GET",
+          "parameters": {"project": {"type": "string", "location": "path", "required": true}},
+          "response": {"$ref": "WidgetList"}
+        }
+      }
+    }
+  }
+}`
+		_, err := ConvertDiscoveryDoc([]byte(doc), DiscoveryConverterConfig{})
+		assert.ErrorContains(t, err, expected)
+	}
+
+	t.Logf("TestConvertDiscoveryDocUnresolvedRefs passed")
+}
@@ -0,0 +1,405 @@
+package openapistackql
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/getkin/kin-openapi/openapi3"
+	yamlconv "github.com/ghodss/yaml"
+)
+
+const (
+	convertedOpenapiVersion string = "3.0.3"
+	schemaRefPrefix         string = "#/components/schemas/"
+)
+
+// docVersionRegex matches the version key leading an openapi or swagger doc, in yaml or json.
+var docVersionRegex = regexp.MustCompile(`(?m)(?:^|[{,]\s*)["']?(openapi|swagger)["']?\s*:\s*["']?(\d+\.\d+)`)
+
+// GetDocFormat returns the spec and major.minor version declared by a doc, eg: "openapi", "3.1";
+// only the leading declaration is considered, so large docs are not parsed.
+func GetDocFormat(bytes []byte) (string, string, bool) {
+	m := docVersionRegex.FindSubmatch(bytes)
+	if m == nil {
+		return "", "", false
+	}
+	return string(m[1]), string(m[2]), true
+}
+
+// ConvertToOpenapi3 returns a Swagger 2.0 or OpenAPI 3.1 doc as an OpenAPI 3.0 doc, in json;
+// x-stackQL-* extensions are carried across.  Any other doc is returned as is.
+func ConvertToOpenapi3(bytes []byte) ([]byte, error) {
+	spec, version, ok := GetDocFormat(bytes)
+	switch {
+	case ok && spec == "swagger" && version == "2.0":
+		return convertSwagger2(bytes)
+	case ok && spec == "openapi" && version == "3.1":
+		return convertOpenapi31(bytes)
+	default:
+		return bytes, nil
+	}
+}
+
+// loadOpenapi3Doc loads a doc in any supported format through l.
+func loadOpenapi3Doc(l *openapi3.Loader, bytes []byte) (*openapi3.T, error) {
+	converted, err := ConvertToOpenapi3(bytes)
+	if err != nil {
+		return nil, err
+	}
+	return l.LoadFromData(converted)
+}
+
+func unmarshalGenericDoc(bytes []byte) (map[string]interface{}, error) {
+	jsonBytes, err := yamlconv.YAMLToJSON(bytes)
+	if err != nil {
+		return nil, err
+	}
+	var rv map[string]interface{}
+	if err := json.Unmarshal(jsonBytes, &rv); err != nil {
+		return nil, err
+	}
+	if rv == nil {
+		return nil, fmt.Errorf("doc is not an object")
+	}
+	return rv, nil
+}
+
+// convertSwagger2 converts through kin-openapi.  Swagger 2.0 has no components,
+// so extensions found either there or at the top level of the doc
+// are placed in the components of the converted doc, as the loader expects.
+func convertSwagger2(bytes []byte) ([]byte, error) {
+	raw, err := unmarshalGenericDoc(bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read swagger 2.0 doc: %s", err.Error())
+	}
+	componentExtensions := make(map[string]interface{})
+	if components, ok := raw["components"].(map[string]interface{}); ok {
+		for k, v := range components {
+			if strings.HasPrefix(k, "x-") {
+				componentExtensions[k] = v
+			}
+		}
+	}
+	delete(raw, "components")
+	if rscs, ok := raw[ExtensionKeyResources]; ok {
+		componentExtensions[ExtensionKeyResources] = rscs
+		delete(raw, ExtensionKeyResources)
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var doc2 openapi2.T
+	if err := json.Unmarshal(b, &doc2); err != nil {
+		return nil, fmt.Errorf("cannot read swagger 2.0 doc: %s", err.Error())
+	}
+	doc3, err := openapi2conv.ToV3(&doc2)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert swagger 2.0 doc: %s", err.Error())
+	}
+	if len(componentExtensions) > 0 {
+		if doc3.Components.Extensions == nil {
+			doc3.Components.Extensions = make(map[string]interface{}, len(componentExtensions))
+		}
+		for k, v := range componentExtensions {
+			ext, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			doc3.Components.Extensions[k] = json.RawMessage(ext)
+		}
+	}
+	return json.Marshal(doc3)
+}
+
+// convertOpenapi31 rewrites those JSON Schema constructs of OpenAPI 3.1
+// which have an OpenAPI 3.0 equivalent; '$defs' are moved to the doc's components.
+func convertOpenapi31(bytes []byte) ([]byte, error) {
+	raw, err := unmarshalGenericDoc(bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read openapi 3.1 doc: %s", err.Error())
+	}
+	raw["openapi"] = convertedOpenapiVersion
+	c := newOpenapi31Converter(raw)
+	c.convertDoc(raw, "")
+	if err := c.hoistDefs(); err != nil {
+		return nil, err
+	}
+	rewriteRefs(raw, c.movedRefs)
+	return json.Marshal(raw)
+}
+
+type hoistedDef struct {
+	name   string
+	schema map[string]interface{}
+}
+
+type openapi31Converter struct {
+	doc        map[string]interface{}
+	schemas    map[string]interface{}
+	hoisted    []hoistedDef
+	movedRefs  map[string]string
+	usedNames  map[string]struct{}
+	scopedDefs []map[string]string
+}
+
+func newOpenapi31Converter(doc map[string]interface{}) *openapi31Converter {
+	rv := &openapi31Converter{
+		doc:       doc,
+		movedRefs: make(map[string]string),
+		usedNames: make(map[string]struct{}),
+	}
+	if components, ok := doc["components"].(map[string]interface{}); ok {
+		if schemas, ok := components["schemas"].(map[string]interface{}); ok {
+			rv.schemas = schemas
+			for k := range schemas {
+				rv.usedNames[k] = struct{}{}
+			}
+		}
+	}
+	return rv
+}
+
+// convertDoc finds schemas outside of extensions and examples.
+func (c *openapi31Converter) convertDoc(node interface{}, ptr string) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(n) {
+			v := n[k]
+			childPtr := ptr + "/" + escapeJSONPointerToken(k)
+			switch {
+			case strings.HasPrefix(k, "x-"), k == "example", k == "examples":
+			case k == "schema":
+				if s, ok := v.(map[string]interface{}); ok {
+					c.convertSchema(s, childPtr, "")
+				}
+			case k == "schemas" && ptr == "/components":
+				if schemas, ok := v.(map[string]interface{}); ok {
+					for _, name := range sortedKeys(schemas) {
+						if s, ok := schemas[name].(map[string]interface{}); ok {
+							c.convertSchema(s, childPtr+"/"+escapeJSONPointerToken(name), name)
+						}
+					}
+				}
+			default:
+				c.convertDoc(v, childPtr)
+			}
+		}
+	case []interface{}:
+		for i, v := range n {
+			c.convertDoc(v, fmt.Sprintf("%s/%d", ptr, i))
+		}
+	}
+}
+
+func (c *openapi31Converter) convertSchema(s map[string]interface{}, ptr string, name string) {
+	if defs, ok := s["$defs"].(map[string]interface{}); ok {
+		scope := make(map[string]string, len(defs))
+		for _, defName := range sortedKeys(defs) {
+			hoistedName := c.newSchemaName(name, defName)
+			scope[defName] = hoistedName
+			c.movedRefs["#"+ptr+"/$defs/"+escapeJSONPointerToken(defName)] = schemaRefPrefix + escapeJSONPointerToken(hoistedName)
+		}
+		c.scopedDefs = append(c.scopedDefs, scope)
+		defer func() { c.scopedDefs = c.scopedDefs[:len(c.scopedDefs)-1] }()
+		for _, defName := range sortedKeys(defs) {
+			def, ok := defs[defName].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			c.convertSchema(def, ptr+"/$defs/"+escapeJSONPointerToken(defName), scope[defName])
+			c.hoisted = append(c.hoisted, hoistedDef{name: scope[defName], schema: def})
+		}
+		delete(s, "$defs")
+	}
+	if ref, ok := s["$ref"].(string); ok {
+		s["$ref"] = c.resolveScopedRef(ref)
+	}
+	convertSchemaKeywords(s)
+	for _, k := range []string{"properties", "patternProperties"} {
+		if props, ok := s[k].(map[string]interface{}); ok {
+			for _, propName := range sortedKeys(props) {
+				if prop, ok := props[propName].(map[string]interface{}); ok {
+					c.convertSchema(prop, ptr+"/"+k+"/"+escapeJSONPointerToken(propName), "")
+				}
+			}
+		}
+	}
+	for _, k := range []string{"items", "additionalProperties", "not", "contains", "if", "then", "else"} {
+		if sub, ok := s[k].(map[string]interface{}); ok {
+			c.convertSchema(sub, ptr+"/"+k, "")
+		}
+	}
+	for _, k := range []string{"allOf", "anyOf", "oneOf", "prefixItems"} {
+		if subs, ok := s[k].([]interface{}); ok {
+			for i, v := range subs {
+				if sub, ok := v.(map[string]interface{}); ok {
+					c.convertSchema(sub, fmt.Sprintf("%s/%s/%d", ptr, k, i), "")
+				}
+			}
+		}
+	}
+}
+
+// resolveScopedRef points a reference of the form '#/$defs/name',
+// commonly used for the '$defs' of an enclosing schema, at the hoisted schema.
+func (c *openapi31Converter) resolveScopedRef(ref string) string {
+	if !strings.HasPrefix(ref, "#/$defs/") {
+		return ref
+	}
+	defName, rest := strings.TrimPrefix(ref, "#/$defs/"), ""
+	if i := strings.Index(defName, "/"); i >= 0 {
+		defName, rest = defName[:i], defName[i:]
+	}
+	defName = unescapeJSONPointerToken(defName)
+	for i := len(c.scopedDefs) - 1; i >= 0; i-- {
+		if hoistedName, ok := c.scopedDefs[i][defName]; ok {
+			return schemaRefPrefix + escapeJSONPointerToken(hoistedName) + rest
+		}
+	}
+	return ref
+}
+
+// newSchemaName keeps the def's own name where free, else qualifies it with that of its parent.
+func (c *openapi31Converter) newSchemaName(parentName string, defName string) string {
+	candidate := defName
+	if _, taken := c.usedNames[candidate]; taken && parentName != "" {
+		candidate = parentName + "_" + defName
+	}
+	rv := candidate
+	for i := 2; ; i++ {
+		if _, taken := c.usedNames[rv]; !taken {
+			break
+		}
+		rv = fmt.Sprintf("%s_%d", candidate, i)
+	}
+	c.usedNames[rv] = struct{}{}
+	return rv
+}
+
+func (c *openapi31Converter) hoistDefs() error {
+	if len(c.hoisted) == 0 {
+		return nil
+	}
+	if c.schemas == nil {
+		components, ok := c.doc["components"].(map[string]interface{})
+		if !ok {
+			if _, exists := c.doc["components"]; exists {
+				return fmt.Errorf("cannot convert openapi 3.1 doc: components is not an object")
+			}
+			components = make(map[string]interface{})
+			c.doc["components"] = components
+		}
+		c.schemas = make(map[string]interface{}, len(c.hoisted))
+		components["schemas"] = c.schemas
+	}
+	for _, def := range c.hoisted {
+		c.schemas[def.name] = def.schema
+	}
+	return nil
+}
+
+// convertSchemaKeywords rewrites the keywords of a single schema, not its subschemas.
+func convertSchemaKeywords(s map[string]interface{}) {
+	if types, ok := s["type"].([]interface{}); ok {
+		var nonNull []interface{}
+		for _, t := range types {
+			if t == "null" {
+				s["nullable"] = true
+				continue
+			}
+			nonNull = append(nonNull, t)
+		}
+		delete(s, "type")
+		switch len(nonNull) {
+		case 0:
+		case 1:
+			s["type"] = nonNull[0]
+		default:
+			alternatives := make([]interface{}, 0, len(nonNull))
+			for _, t := range nonNull {
+				alternatives = append(alternatives, map[string]interface{}{"type": t})
+			}
+			_, hasAnyOf := s["anyOf"]
+			_, hasOneOf := s["oneOf"]
+			if !hasAnyOf && !hasOneOf {
+				s["anyOf"] = alternatives
+			} else {
+				allOf, _ := s["allOf"].([]interface{})
+				s["allOf"] = append(allOf, map[string]interface{}{"anyOf": alternatives})
+			}
+		}
+	} else if s["type"] == "null" {
+		delete(s, "type")
+		s["nullable"] = true
+	}
+	if v, ok := s["const"]; ok {
+		if _, hasEnum := s["enum"]; !hasEnum {
+			s["enum"] = []interface{}{v}
+		}
+		delete(s, "const")
+	}
+	if examples, ok := s["examples"].([]interface{}); ok {
+		if _, hasExample := s["example"]; !hasExample && len(examples) > 0 {
+			s["example"] = examples[0]
+		}
+		delete(s, "examples")
+	}
+	for bound, exclusive := range map[string]string{"minimum": "exclusiveMinimum", "maximum": "exclusiveMaximum"} {
+		if v, ok := s[exclusive].(float64); ok {
+			s[bound] = v
+			s[exclusive] = true
+		}
+	}
+}
+
+// rewriteRefs repoints references anywhere in the doc, extensions included,
+// to schemas which have been moved.
+func rewriteRefs(node interface{}, moved map[string]string) {
+	if len(moved) == 0 {
+		return
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			if ref, ok := v.(string); ok && k == "$ref" {
+				n[k] = rewriteRef(ref, moved)
+				continue
+			}
+			rewriteRefs(v, moved)
+		}
+	case []interface{}:
+		for _, v := range n {
+			rewriteRefs(v, moved)
+		}
+	}
+}
+
+func rewriteRef(ref string, moved map[string]string) string {
+	if !strings.HasPrefix(ref, "#/") {
+		return ref
+	}
+	// the longest match wins, for defs nested in defs
+	var matched string
+	for from := range moved {
+		if (ref == from || strings.HasPrefix(ref, from+"/")) && len(from) > len(matched) {
+			matched = from
+		}
+	}
+	if matched == "" {
+		return ref
+	}
+	return moved[matched] + strings.TrimPrefix(ref, matched)
+}
+
+func escapeJSONPointerToken(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func unescapeJSONPointerToken(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+}
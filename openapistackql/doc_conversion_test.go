@@ -0,0 +1,194 @@
+package openapistackql_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+
+	"gotest.tools/assert"
+)
+
+var swagger2PetsDoc = []byte(`swagger: "2.0"
+info:
+  version: 1.0.0
+  title: Pets
+host: pets.example.com
+basePath: /v1
+produces:
+  - application/json
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - name: limit
+          in: query
+          type: integer
+      responses:
+        "200":
+          description: Pets
+          schema:
+            $ref: "#/definitions/Pets"
+      x-stackQL-method-note: kept
+definitions:
+  Pet:
+    type: object
+    properties:
+      id:
+        type: integer
+      name:
+        type: string
+  Pets:
+    type: array
+    items:
+      $ref: "#/definitions/Pet"
+x-stackQL-resources:
+  pets:
+    id: example.pets.pets
+    name: pets
+    title: Pets
+    methods:
+      list:
+        operation:
+          $ref: '#/paths/~1pets/get'
+        response:
+          mediaType: application/json
+          openAPIDocKey: '200'
+    sqlVerbs:
+      select:
+        - $ref: '#/components/x-stackQL-resources/pets/methods/list'
+      insert: []
+      update: []
+      delete: []
+`)
+
+var openapi31PetsDoc = []byte(`openapi: 3.1.0
+info:
+  version: 1.0.0
+  title: Pets
+servers:
+  - url: https://pets.example.com/v1
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        "200":
+          description: Pets
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/Pet"
+components:
+  schemas:
+    Pet:
+      type: object
+      $defs:
+        Tag:
+          type: object
+          properties:
+            label:
+              type: [string, "null"]
+      properties:
+        id:
+          type: integer
+          exclusiveMinimum: 0
+        kind:
+          const: pet
+        name:
+          type: string
+          examples:
+            - Rex
+        tags:
+          type: array
+          items:
+            $ref: "#/$defs/Tag"
+        owner:
+          type: [string, integer, "null"]
+  x-stackQL-resources:
+    pets:
+      id: example.pets.pets
+      name: pets
+      title: Pets
+      methods:
+        list:
+          operation:
+            $ref: '#/paths/~1pets/get'
+          response:
+            mediaType: application/json
+            openAPIDocKey: '200'
+      sqlVerbs:
+        select:
+          - $ref: '#/components/x-stackQL-resources/pets/methods/list'
+        insert: []
+        update: []
+        delete: []
+`)
+
+func TestSwagger2DocLoad(t *testing.T) {
+	spec, version, ok := GetDocFormat(swagger2PetsDoc)
+	assert.Assert(t, ok)
+	assert.Equal(t, spec, "swagger")
+	assert.Equal(t, version, "2.0")
+
+	svc, err := NewLoader().LoadFromBytes(swagger2PetsDoc)
+	assert.NilError(t, err)
+	assert.Equal(t, svc.GetT().OpenAPI, "3.0.3")
+	assert.Equal(t, svc.GetT().Servers[0].URL, "https://pets.example.com/v1")
+
+	rsc, err := svc.GetResource("pets")
+	assert.NilError(t, err)
+	os, _, ok := rsc.GetFirstMethodMatchFromSQLVerb("select", map[string]interface{}{})
+	assert.Assert(t, ok)
+	assert.Equal(t, os.GetOperationRef().Value.OperationID, "listPets")
+	_, ok = os.GetOperationRef().Value.Extensions["x-stackQL-method-note"]
+	assert.Assert(t, ok)
+	sc, _, err := os.GetResponseBodySchemaAndMediaType()
+	assert.NilError(t, err)
+	assert.Equal(t, sc.GetType(), "array")
+	items, err := sc.GetItems()
+	assert.NilError(t, err)
+	assert.Equal(t, items.GetName(), "Pet")
+
+	t.Logf("TestSwagger2DocLoad passed")
+}
+
+func TestOpenapi31DocLoad(t *testing.T) {
+	svc, err := NewLoader().LoadFromBytes(openapi31PetsDoc)
+	assert.NilError(t, err)
+	assert.Equal(t, svc.GetT().OpenAPI, "3.0.3")
+
+	rsc, err := svc.GetResource("pets")
+	assert.NilError(t, err)
+	_, ok := rsc.GetMethods()["list"]
+	assert.Assert(t, ok)
+
+	pet := svc.GetT().Components.Schemas["Pet"].Value
+	assert.Equal(t, pet.Properties["id"].Value.ExclusiveMin, true)
+	assert.Equal(t, *pet.Properties["id"].Value.Min, float64(0))
+	assert.DeepEqual(t, pet.Properties["kind"].Value.Enum, []interface{}{"pet"})
+	assert.Equal(t, pet.Properties["name"].Value.Example, "Rex")
+	owner := pet.Properties["owner"].Value
+	assert.Assert(t, owner.Nullable)
+	assert.Equal(t, len(owner.AnyOf), 2)
+
+	// '$defs' are moved to the components
+	assert.Equal(t, pet.Properties["tags"].Value.Items.Ref, "#/components/schemas/Tag")
+	tag, ok := svc.GetT().Components.Schemas["Tag"]
+	assert.Assert(t, ok)
+	label := tag.Value.Properties["label"].Value
+	assert.Equal(t, label.Type, "string")
+	assert.Assert(t, label.Nullable)
+
+	b, err := ConvertToOpenapi3(openapi31PetsDoc)
+	assert.NilError(t, err)
+	var converted map[string]interface{}
+	assert.NilError(t, json.Unmarshal(b, &converted))
+	_, ok = converted["components"].(map[string]interface{})["x-stackQL-resources"]
+	assert.Assert(t, ok)
+
+	t.Logf("TestOpenapi31DocLoad passed")
+}
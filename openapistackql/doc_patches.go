@@ -0,0 +1,574 @@
+package openapistackql
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	overlayVersionPrefix string = "1."
+	docPatchKindOverlay  string = "overlay"
+	docPatchKindMerge    string = "mergePatch"
+)
+
+// OverlayInfo describes an overlay.
+type OverlayInfo struct {
+	Title   string `json:"title" yaml:"title"`
+	Version string `json:"version" yaml:"version"`
+}
+
+// OverlayAction updates or removes the nodes selected by Target,
+// a JSONPath expression supporting child, index, wildcard and
+// simple equality filter selectors, eg:
+// "$.components['x-stackQL-resources'].disks.methods[?(@.operation.$ref == '...')]".
+type OverlayAction struct {
+	Target      string      `json:"target" yaml:"target"`
+	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
+	Update      interface{} `json:"update,omitempty" yaml:"update,omitempty"`
+	Remove      bool        `json:"remove,omitempty" yaml:"remove,omitempty"`
+}
+
+// Overlay is an OpenAPI Overlay doc; Extends is the doc path,
+// or path.Match pattern thereof, to which it applies,
+// eg: 'googleapis.com/*/services/compute-v1.yaml'.
+type Overlay struct {
+	Overlay string          `json:"overlay" yaml:"overlay"`
+	Info    OverlayInfo     `json:"info" yaml:"info"`
+	Extends string          `json:"extends" yaml:"extends"`
+	Actions []OverlayAction `json:"actions" yaml:"actions"`
+}
+
+// MergePatch is a JSON Merge Patch (RFC 7386) applied to
+// the doc path, or path.Match pattern thereof, Target.
+type MergePatch struct {
+	Target      string      `json:"target" yaml:"target"`
+	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
+	Patch       interface{} `json:"patch" yaml:"patch"`
+}
+
+// AppliedDocPatch records a single overlay action or merge patch
+// applied to a doc, along with the count of nodes it affected.
+type AppliedDocPatch struct {
+	DocPath string `json:"docPath" yaml:"docPath"`
+	Kind    string `json:"kind" yaml:"kind"`
+	Name    string `json:"name" yaml:"name"`
+	Target  string `json:"target" yaml:"target"`
+	Matches int    `json:"matches" yaml:"matches"`
+}
+
+// DocPatches are applied to provider, resources and service docs
+// as they are read, overlays first and then merge patches,
+// each in the order declared.
+type DocPatches struct {
+	Overlays     []*Overlay    `json:"overlays" yaml:"overlays"`
+	MergePatches []*MergePatch `json:"mergePatches" yaml:"mergePatches"`
+
+	mutex   sync.Mutex
+	applied []AppliedDocPatch
+}
+
+// ReadDocPatches reads and checks the patches file at path.
+func ReadDocPatches(path string) (*DocPatches, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := ParseDocPatches(b)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read patches file '%s': %s", path, err.Error())
+	}
+	return rv, nil
+}
+
+// ParseDocPatches parses and checks a patches doc.
+func ParseDocPatches(b []byte) (*DocPatches, error) {
+	var rv DocPatches
+	if err := yaml.Unmarshal(b, &rv); err != nil {
+		return nil, err
+	}
+	if err := rv.check(); err != nil {
+		return nil, err
+	}
+	return &rv, nil
+}
+
+func (dp *DocPatches) check() error {
+	for i, ov := range dp.Overlays {
+		if ov == nil {
+			return fmt.Errorf("overlay %d is empty", i)
+		}
+		if !strings.HasPrefix(ov.Overlay, overlayVersionPrefix) {
+			return fmt.Errorf("overlay %d has unsupported version '%s'", i, ov.Overlay)
+		}
+		if _, err := path.Match(ov.Extends, ""); ov.Extends == "" || err != nil {
+			return fmt.Errorf("overlay %d has invalid 'extends' '%s'", i, ov.Extends)
+		}
+		for j, action := range ov.Actions {
+			if _, err := parseDocPatchTarget(action.Target); err != nil {
+				return fmt.Errorf("overlay %d action %d: %s", i, j, err.Error())
+			}
+			if action.Update == nil && !action.Remove {
+				return fmt.Errorf("overlay %d action %d has neither 'update' nor 'remove'", i, j)
+			}
+		}
+	}
+	for i, mp := range dp.MergePatches {
+		if mp == nil {
+			return fmt.Errorf("merge patch %d is empty", i)
+		}
+		if _, err := path.Match(mp.Target, ""); mp.Target == "" || err != nil {
+			return fmt.Errorf("merge patch %d has invalid target '%s'", i, mp.Target)
+		}
+	}
+	return nil
+}
+
+// GetApplied returns a record of every patch applied thus far.
+func (dp *DocPatches) GetApplied() []AppliedDocPatch {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+	rv := make([]AppliedDocPatch, len(dp.applied))
+	copy(rv, dp.applied)
+	return rv
+}
+
+// Verify returns an error naming each overlay action or merge patch
+// which has not yet taken effect on any doc loaded, eg: because
+// the doc it extends has been restructured upstream.
+func (dp *DocPatches) Verify() error {
+	matches := make(map[string]int)
+	for _, a := range dp.GetApplied() {
+		matches[a.Kind+"\x00"+a.Name+"\x00"+a.Target] += a.Matches
+	}
+	var unmatched []string
+	for i, ov := range dp.Overlays {
+		for j, action := range ov.Actions {
+			name := ov.getActionName(i, j)
+			if matches[docPatchKindOverlay+"\x00"+name+"\x00"+action.Target] == 0 {
+				unmatched = append(unmatched, fmt.Sprintf("%s '%s'", docPatchKindOverlay, name))
+			}
+		}
+	}
+	for i, mp := range dp.MergePatches {
+		name := mp.getName(i)
+		if matches[docPatchKindMerge+"\x00"+name+"\x00"+mp.Target] == 0 {
+			unmatched = append(unmatched, fmt.Sprintf("%s '%s'", docPatchKindMerge, name))
+		}
+	}
+	if len(unmatched) > 0 {
+		return fmt.Errorf("patches not applied: %s", strings.Join(unmatched, ", "))
+	}
+	return nil
+}
+
+// mergeDocPatches returns a snapshot of several sets of patches, with those applied thus far,
+// such that each patch keeps the name by which its applications were recorded.
+func mergeDocPatches(dps []*DocPatches) *DocPatches {
+	rv := &DocPatches{}
+	for _, dp := range dps {
+		for i, ov := range dp.Overlays {
+			named := *ov
+			if named.Info.Title == "" {
+				named.Info.Title = fmt.Sprintf("overlay %d", i)
+			}
+			rv.Overlays = append(rv.Overlays, &named)
+		}
+		for i, mp := range dp.MergePatches {
+			named := *mp
+			named.Description = mp.getName(i)
+			rv.MergePatches = append(rv.MergePatches, &named)
+		}
+		rv.applied = append(rv.applied, dp.GetApplied()...)
+	}
+	return rv
+}
+
+func (ov *Overlay) getActionName(overlayIdx, actionIdx int) string {
+	title := ov.Info.Title
+	if title == "" {
+		title = fmt.Sprintf("overlay %d", overlayIdx)
+	}
+	return fmt.Sprintf("%s action %d", title, actionIdx)
+}
+
+func (mp *MergePatch) getName(idx int) string {
+	if mp.Description != "" {
+		return mp.Description
+	}
+	return fmt.Sprintf("merge patch %d", idx)
+}
+
+func (dp *DocPatches) record(a AppliedDocPatch, logger *logrus.Logger) {
+	logger.Debugf("applied %s '%s' to '%s' at '%s': %d nodes matched", a.Kind, a.Name, a.DocPath, a.Target, a.Matches)
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+	dp.applied = append(dp.applied, a)
+}
+
+// apply returns the doc at docPath with all matching patches applied;
+// docs matched by no patch are returned unchanged.
+func (dp *DocPatches) apply(docPath string, b []byte, logger *logrus.Logger) ([]byte, error) {
+	if dp == nil {
+		return b, nil
+	}
+	docPath = fsPath(docPath)
+	var overlays []int
+	for i, ov := range dp.Overlays {
+		if ok, _ := path.Match(ov.Extends, docPath); ok {
+			overlays = append(overlays, i)
+		}
+	}
+	var mergePatches []int
+	for i, mp := range dp.MergePatches {
+		if ok, _ := path.Match(mp.Target, docPath); ok {
+			mergePatches = append(mergePatches, i)
+		}
+	}
+	if len(overlays) == 0 && len(mergePatches) == 0 {
+		return b, nil
+	}
+	doc, err := unmarshalGenericDoc(b)
+	if err != nil {
+		return nil, fmt.Errorf("cannot patch doc '%s': %s", docPath, err.Error())
+	}
+	var root interface{} = doc
+	for _, i := range overlays {
+		ov := dp.Overlays[i]
+		for j, action := range ov.Actions {
+			n, err := applyOverlayAction(&root, action)
+			if err != nil {
+				return nil, fmt.Errorf("cannot patch doc '%s' with %s: %s", docPath, ov.getActionName(i, j), err.Error())
+			}
+			dp.record(AppliedDocPatch{DocPath: docPath, Kind: docPatchKindOverlay, Name: ov.getActionName(i, j), Target: action.Target, Matches: n}, logger)
+		}
+	}
+	for _, i := range mergePatches {
+		mp := dp.MergePatches[i]
+		patch, err := normaliseDocPatchValue(mp.Patch)
+		if err != nil {
+			return nil, err
+		}
+		root = applyMergePatch(root, patch)
+		dp.record(AppliedDocPatch{DocPath: docPath, Kind: docPatchKindMerge, Name: mp.getName(i), Target: mp.Target, Matches: 1}, logger)
+	}
+	return json.Marshal(root)
+}
+
+// normaliseDocPatchValue round trips patch values through JSON,
+// so that they are typed as are the docs to which they apply.
+func normaliseDocPatchValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var rv interface{}
+	if err := json.Unmarshal(b, &rv); err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// applyMergePatch implements RFC 7386.
+func applyMergePatch(target interface{}, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = applyMergePatch(targetObj[k], v)
+	}
+	return targetObj
+}
+
+// applyOverlayAction returns the count of nodes matched.  Objects are
+// merged with the update recursively, arrays have it appended and
+// other values are replaced.
+func applyOverlayAction(root *interface{}, action OverlayAction) (int, error) {
+	selectors, err := parseDocPatchTarget(action.Target)
+	if err != nil {
+		return 0, err
+	}
+	nodes := selectDocPatchNodes(root, selectors)
+	if action.Remove {
+		for _, n := range nodes {
+			if n.remove == nil {
+				return 0, fmt.Errorf("cannot remove the doc root")
+			}
+			n.remove()
+		}
+		*root = pruneRemovedNodes(*root)
+		return len(nodes), nil
+	}
+	update, err := normaliseDocPatchValue(action.Update)
+	if err != nil {
+		return 0, err
+	}
+	for _, n := range nodes {
+		switch v := n.value.(type) {
+		case map[string]interface{}:
+			mergeOverlayUpdate(v, update)
+		case []interface{}:
+			if items, ok := update.([]interface{}); ok {
+				n.set(append(v, items...))
+				continue
+			}
+			n.set(append(v, update))
+		default:
+			n.set(update)
+		}
+	}
+	return len(nodes), nil
+}
+
+func mergeOverlayUpdate(target map[string]interface{}, update interface{}) {
+	updateObj, ok := update.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for k, v := range updateObj {
+		if existing, ok := target[k].(map[string]interface{}); ok {
+			if _, isObj := v.(map[string]interface{}); isObj {
+				mergeOverlayUpdate(existing, v)
+				continue
+			}
+		}
+		target[k] = v
+	}
+}
+
+type removedDocPatchNode struct{}
+
+// pruneRemovedNodes drops array elements marked for removal.
+func pruneRemovedNodes(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			n[k] = pruneRemovedNodes(v)
+		}
+	case []interface{}:
+		rv := make([]interface{}, 0, len(n))
+		for _, v := range n {
+			if _, removed := v.(removedDocPatchNode); removed {
+				continue
+			}
+			rv = append(rv, pruneRemovedNodes(v))
+		}
+		return rv
+	}
+	return node
+}
+
+type docPatchNode struct {
+	value  interface{}
+	set    func(interface{})
+	remove func()
+}
+
+type docPatchSelector struct {
+	name       string
+	index      int
+	isIndex    bool
+	isWildcard bool
+	filterPath []string
+	filterVal  interface{}
+	isFilter   bool
+}
+
+func selectDocPatchNodes(root *interface{}, selectors []docPatchSelector) []docPatchNode {
+	nodes := []docPatchNode{{value: *root, set: func(v interface{}) { *root = v }}}
+	for _, sel := range selectors {
+		var next []docPatchNode
+		for _, n := range nodes {
+			next = append(next, sel.selectChildren(n)...)
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func (sel docPatchSelector) selectChildren(n docPatchNode) []docPatchNode {
+	var rv []docPatchNode
+	switch v := n.value.(type) {
+	case map[string]interface{}:
+		switch {
+		case sel.isIndex:
+		case sel.isWildcard, sel.isFilter:
+			for _, k := range sortedKeys(v) {
+				if sel.isFilter && !sel.matchesFilter(v[k]) {
+					continue
+				}
+				rv = append(rv, newMapDocPatchNode(v, k))
+			}
+		default:
+			if _, ok := v[sel.name]; ok {
+				rv = append(rv, newMapDocPatchNode(v, sel.name))
+			}
+		}
+	case []interface{}:
+		switch {
+		case sel.isIndex:
+			i := sel.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				rv = append(rv, newArrayDocPatchNode(v, i))
+			}
+		case sel.isWildcard, sel.isFilter:
+			for i := range v {
+				if sel.isFilter && !sel.matchesFilter(v[i]) {
+					continue
+				}
+				rv = append(rv, newArrayDocPatchNode(v, i))
+			}
+		}
+	}
+	return rv
+}
+
+func (sel docPatchSelector) matchesFilter(v interface{}) bool {
+	for _, k := range sel.filterPath {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		v, ok = m[k]
+		if !ok {
+			return false
+		}
+	}
+	return v == sel.filterVal
+}
+
+func newMapDocPatchNode(m map[string]interface{}, k string) docPatchNode {
+	return docPatchNode{
+		value:  m[k],
+		set:    func(v interface{}) { m[k] = v },
+		remove: func() { delete(m, k) },
+	}
+}
+
+func newArrayDocPatchNode(a []interface{}, i int) docPatchNode {
+	return docPatchNode{
+		value:  a[i],
+		set:    func(v interface{}) { a[i] = v },
+		remove: func() { a[i] = removedDocPatchNode{} },
+	}
+}
+
+// parseDocPatchTarget parses the supported JSONPath subset:
+// '$' followed by '.name', "['name']", '[n]', '.*', '[*]'
+// and '[?(@.a.b == <literal>)]' selectors.
+func parseDocPatchTarget(target string) ([]docPatchSelector, error) {
+	if !strings.HasPrefix(target, "$") {
+		return nil, fmt.Errorf("target '%s' must begin with '$'", target)
+	}
+	var rv []docPatchSelector
+	s := target[1:]
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, ".."):
+			return nil, fmt.Errorf("target '%s': recursive descent is not supported", target)
+		case strings.HasPrefix(s, "."):
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			if name == "" {
+				return nil, fmt.Errorf("target '%s' has an empty name", target)
+			}
+			if name == "*" {
+				rv = append(rv, docPatchSelector{isWildcard: true})
+			} else {
+				rv = append(rv, docPatchSelector{name: name})
+			}
+			s = s[end:]
+		case strings.HasPrefix(s, "["):
+			sel, rest, err := parseDocPatchBracket(s)
+			if err != nil {
+				return nil, fmt.Errorf("target '%s': %s", target, err.Error())
+			}
+			rv = append(rv, sel)
+			s = rest
+		default:
+			return nil, fmt.Errorf("target '%s' is not a supported JSONPath expression", target)
+		}
+	}
+	return rv, nil
+}
+
+func parseDocPatchBracket(s string) (docPatchSelector, string, error) {
+	switch {
+	case strings.HasPrefix(s, "['"), strings.HasPrefix(s, `["`):
+		quote := s[1:2]
+		end := strings.Index(s[2:], quote+"]")
+		if end < 0 {
+			return docPatchSelector{}, "", fmt.Errorf("unterminated name selector")
+		}
+		return docPatchSelector{name: s[2 : 2+end]}, s[2+end+2:], nil
+	case strings.HasPrefix(s, "[*]"):
+		return docPatchSelector{isWildcard: true}, s[3:], nil
+	case strings.HasPrefix(s, "[?("):
+		end := strings.Index(s, ")]")
+		if end < 0 {
+			return docPatchSelector{}, "", fmt.Errorf("unterminated filter selector")
+		}
+		sel, err := parseDocPatchFilter(s[3:end])
+		if err != nil {
+			return docPatchSelector{}, "", err
+		}
+		return sel, s[end+2:], nil
+	}
+	end := strings.Index(s, "]")
+	if end < 0 {
+		return docPatchSelector{}, "", fmt.Errorf("unterminated selector")
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(s[1:end]))
+	if err != nil {
+		return docPatchSelector{}, "", fmt.Errorf("unsupported selector '%s'", s[:end+1])
+	}
+	return docPatchSelector{index: i, isIndex: true}, s[end+1:], nil
+}
+
+func parseDocPatchFilter(expr string) (docPatchSelector, error) {
+	elems := strings.SplitN(expr, "==", 2)
+	if len(elems) != 2 {
+		return docPatchSelector{}, fmt.Errorf("unsupported filter '%s'; only equality is supported", expr)
+	}
+	lhs := strings.TrimSpace(elems[0])
+	if !strings.HasPrefix(lhs, "@.") {
+		return docPatchSelector{}, fmt.Errorf("unsupported filter '%s'", expr)
+	}
+	var val interface{}
+	if err := yaml.Unmarshal([]byte(strings.TrimSpace(elems[1])), &val); err != nil {
+		return docPatchSelector{}, fmt.Errorf("unsupported filter value in '%s'", expr)
+	}
+	val, err := normaliseDocPatchValue(val)
+	if err != nil {
+		return docPatchSelector{}, err
+	}
+	// only scalars are comparable with the nodes filtered
+	switch val.(type) {
+	case map[string]interface{}, []interface{}:
+		return docPatchSelector{}, fmt.Errorf("unsupported filter value in '%s'; only scalars are supported", expr)
+	}
+	return docPatchSelector{filterPath: strings.Split(lhs[2:], "."), filterVal: val, isFilter: true}, nil
+}
@@ -0,0 +1,225 @@
+package openapistackql_test
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+
+	"gotest.tools/assert"
+)
+
+var containerDocPatches = []byte(`overlays:
+  - overlay: 1.0.0
+    info:
+      title: container fixes
+      version: 1.0.0
+    extends: googleapis.com/*/services/container-v1.yaml
+    actions:
+      - target: $.components['x-stackQL-resources']['projects.locations.clusters']
+        update:
+          sqlVerbs:
+            select:
+              - $ref: '#/components/x-stackQL-resources/projects.locations.clusters/methods/list'
+      - target: $.components['x-stackQL-resources']['projects.locations.clusters'].methods.list.response
+        update:
+          objectKey: $.clusters
+      - target: $.components['x-stackQL-resources']['projects.locations.clusters'].methods.getJwks
+        remove: true
+      - target: $.paths['/v1/projects/{projectsId}/locations/{locationsId}/clusters'].get.parameters[?(@.in == 'query')]
+        remove: true
+      - target: $.components['x-stackQL-resources'].absent
+        update:
+          title: absent
+mergePatches:
+  - target: googleapis.com/*/services/container-v1.yaml
+    description: zone cluster pagination
+    patch:
+      components:
+        x-stackQL-resources:
+          projects.zones.clusters:
+            config:
+              pagination:
+                requestToken:
+                  key: pageToken
+                  location: query
+                responseToken:
+                  key: $.nextPageToken
+                  location: body
+  - target: googleapis.com/*/services/storage-v1.yaml
+    patch:
+      info:
+        title: unused
+`)
+
+func TestDocPatchesLoad(t *testing.T) {
+	fileRoot, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry", "src"))
+	assert.NilError(t, err)
+	dp, err := ParseDocPatches(containerDocPatches)
+	assert.NilError(t, err)
+	l := NewLoaderWithOptions(LoaderOptions{FileRoot: fileRoot, DocPatches: dp})
+
+	pr, err := l.LoadProviderByName("googleapis.com", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := pr.GetProviderService("container")
+	assert.NilError(t, err)
+	svc, err := ps.GetService()
+	assert.NilError(t, err)
+
+	clusters, err := svc.GetResource("projects.locations.clusters")
+	assert.NilError(t, err)
+	opStore, _, ok := clusters.GetFirstMethodMatchFromSQLVerb("select", map[string]interface{}{"projectsId": struct{}{}, "locationsId": struct{}{}})
+	assert.Assert(t, ok)
+	assert.Equal(t, opStore.GetOperationRef().Value.OperationID, "container.projects.locations.clusters.list")
+	assert.Equal(t, opStore.GetSelectItemsKey(), "$.clusters")
+	assert.Equal(t, len(opStore.GetOperationRef().Value.Parameters), 2)
+	_, err = clusters.FindMethod("getJwks")
+	assert.Assert(t, err != nil)
+
+	zoneClusters, err := svc.GetResource("projects.zones.clusters")
+	assert.NilError(t, err)
+	ts, ok := zoneClusters.GetPaginationRequestTokenSemantic()
+	assert.Assert(t, ok)
+	assert.Equal(t, ts.GetKey(), "pageToken")
+
+	applied := dp.GetApplied()
+	assert.Equal(t, len(applied), 6)
+	for _, a := range applied {
+		assert.Equal(t, a.DocPath, "googleapis.com/v0.1.0/services/container-v1.yaml")
+	}
+	assert.Equal(t, applied[3].Matches, 2)
+	assert.Equal(t, applied[4].Matches, 0)
+	assert.Error(t, dp.Verify(), "patches not applied: overlay 'container fixes action 4', mergePatch 'merge patch 1'")
+
+	t.Logf("TestDocPatchesLoad passed")
+}
+
+func TestDocPatchesParse(t *testing.T) {
+	for doc, expected := range map[string]string{
+		"overlays:\n  - overlay: 2.0.0\n    extends: a.yaml\n":                                                           "overlay 0 has unsupported version '2.0.0'",
+		"overlays:\n  - overlay: 1.0.0\n    actions:\n      - target: $.a\n        remove: true\n":                       "overlay 0 has invalid 'extends' ''",
+		"overlays:\n  - overlay: 1.0.0\n    extends: a.yaml\n    actions:\n      - target: $..a\n        remove: true\n": "recursive descent is not supported",
+		"overlays:\n  - overlay: 1.0.0\n    extends: a.yaml\n    actions:\n      - target: $.a\n":                        "overlay 0 action 0 has neither 'update' nor 'remove'",
+		"mergePatches:\n  - patch: {}\n": "merge patch 0 has invalid target ''",
+		"overlays:\n  - overlay: 1.0.0\n    extends: a.yaml\n    actions:\n      - target: \"$.a[?(@.x == {a: 1})]\"\n        remove: true\n": "only scalars are supported",
+		"overlays:\n  - overlay: 1.0.0\n    extends: a.yaml\n    actions:\n      - target: \"$.a[?(@.tags == [a])]\"\n        remove: true\n": "only scalars are supported",
+	} {
+		_, err := ParseDocPatches([]byte(doc))
+		assert.ErrorContains(t, err, expected)
+	}
+
+	t.Logf("TestDocPatchesParse passed")
+}
+
+func TestRegistryDocPatches(t *testing.T) {
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry/src/mockableprovider/v0.1.0")
+	assert.NilError(t, err)
+	registryRoot := t.TempDir()
+	copyTree(t, srcRoot, filepath.Join(registryRoot, "src", "mockableprovider", "v0.1.0"))
+	patchesPath := filepath.Join(t.TempDir(), "patches.yaml")
+	assert.NilError(t, os.WriteFile(patchesPath, []byte(`mergePatches:
+  - target: mockableprovider/v0.1.0/services/mockable_service.yaml
+    patch:
+      components:
+        x-stackQL-resources:
+          widgets:
+            methods:
+              list:
+                response:
+                  objectKey: $.widgets
+`), 0644))
+	reg, err := NewRegistry(
+		RegistryConfig{
+			RegistryURL:   fmt.Sprintf("file://%s", registryRoot),
+			VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+			PatchesPath:   patchesPath,
+		},
+		nil,
+	)
+	assert.NilError(t, err)
+	prov, err := reg.LoadProviderByName("mockableprovider", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := prov.GetProviderService("mockable_service")
+	assert.NilError(t, err)
+	svc, err := reg.GetService(ps)
+	assert.NilError(t, err)
+	widgets, err := svc.GetResource("widgets")
+	assert.NilError(t, err)
+	list, err := widgets.FindMethod("list")
+	assert.NilError(t, err)
+	assert.Equal(t, list.GetSelectItemsKey(), "$.widgets")
+
+	dp, ok := reg.GetDocPatches()
+	assert.Assert(t, ok)
+	assert.NilError(t, dp.Verify())
+
+	t.Logf("TestRegistryDocPatches passed")
+}
+
+func TestChainedRegistryDocPatches(t *testing.T) {
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry/src/mockableprovider/v0.1.0")
+	assert.NilError(t, err)
+	overrideRoot := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(overrideRoot, "src"), 0755))
+	baseRoot := t.TempDir()
+	copyTree(t, srcRoot, filepath.Join(baseRoot, "src", "mockableprovider", "v0.1.0"))
+	writePatches := func(description string, objectKey string) string {
+		patchesPath := filepath.Join(t.TempDir(), "patches.yaml")
+		assert.NilError(t, os.WriteFile(patchesPath, []byte(fmt.Sprintf(`mergePatches:
+  - target: mockableprovider/v0.1.0/services/mockable_service.yaml
+    description: %s
+    patch:
+      components:
+        x-stackQL-resources:
+          widgets:
+            methods:
+              list:
+                response:
+                  objectKey: %s
+`, description, objectKey)), 0644))
+		return patchesPath
+	}
+	reg, err := NewChainedRegistry(
+		[]RegistryConfig{
+			{
+				RegistryURL:   fmt.Sprintf("file://%s", overrideRoot),
+				VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+				PatchesPath:   writePatches("override", "$.overridden"),
+			},
+			{
+				RegistryURL:   fmt.Sprintf("file://%s", baseRoot),
+				VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+				PatchesPath:   writePatches("base", "$.widgets"),
+			},
+		},
+		nil,
+	)
+	assert.NilError(t, err)
+	prov, err := reg.LoadProviderByName("mockableprovider", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := prov.GetProviderService("mockable_service")
+	assert.NilError(t, err)
+	svc, err := reg.GetService(ps)
+	assert.NilError(t, err)
+	widgets, err := svc.GetResource("widgets")
+	assert.NilError(t, err)
+	list, err := widgets.FindMethod("list")
+	assert.NilError(t, err)
+	assert.Equal(t, list.GetSelectItemsKey(), "$.widgets")
+
+	// the doc was served, and so patched, by the base layer alone
+	dp, ok := reg.GetDocPatches()
+	assert.Assert(t, ok)
+	applied := dp.GetApplied()
+	assert.Equal(t, len(applied), 1)
+	assert.Equal(t, applied[0].Name, "base")
+	assert.Error(t, dp.Verify(), "patches not applied: mergePatch 'override'")
+
+	t.Logf("TestChainedRegistryDocPatches passed")
+}
@@ -0,0 +1,123 @@
+package openapistackql_test
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+
+	"gotest.tools/assert"
+)
+
+// countingFS records how many times each file is opened.
+type countingFS struct {
+	fs.FS
+	mutex sync.Mutex
+	opens map[string]int
+}
+
+func newCountingFS(fsys fs.FS) *countingFS {
+	return &countingFS{FS: fsys, opens: make(map[string]int)}
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.mutex.Lock()
+	c.opens[name]++
+	c.mutex.Unlock()
+	return c.FS.Open(name)
+}
+
+func (c *countingFS) getOpens(name string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.opens[name]
+}
+
+var concurrentComputeResourceKeys = []string{"acceleratorTypes", "addresses", "disks", "instances"}
+
+// hammerServiceFragments resolves fragments and their resources from many goroutines at once.
+func hammerServiceFragments(t *testing.T, getFragment func(resourceKey string) (Service, error)) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 32*len(concurrentComputeResourceKeys))
+	for i := 0; i < 32; i++ {
+		for _, k := range concurrentComputeResourceKeys {
+			wg.Add(1)
+			go func(resourceKey string) {
+				defer wg.Done()
+				svc, err := getFragment(resourceKey)
+				if err != nil {
+					errs <- err
+					return
+				}
+				rsc, err := svc.GetResource(resourceKey)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if _, err := rsc.FindMethod("get"); err != nil {
+					errs <- err
+				}
+			}(k)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NilError(t, err)
+	}
+}
+
+func TestConcurrentServiceFragmentAccess(t *testing.T) {
+	fileRoot, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry", "src"))
+	assert.NilError(t, err)
+	docSource := newCountingFS(os.DirFS(fileRoot))
+	l := NewLoaderWithOptions(LoaderOptions{DocSource: docSource})
+	pr, err := l.LoadProviderByName("googleapis.com", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := pr.GetProviderService("compute")
+	assert.NilError(t, err)
+
+	hammerServiceFragments(t, ps.GetServiceFragment)
+
+	for _, doc := range []string{
+		"googleapis.com/v0.1.0/resources/compute-v1.yaml",
+		"googleapis.com/v0.1.0/services-split/compute/compute-v1.yaml",
+		"googleapis.com/v0.1.0/services-split/compute/compute-disks-v1.yaml",
+	} {
+		assert.Equal(t, docSource.getOpens(doc), 1, doc)
+	}
+
+	t.Logf("TestConcurrentServiceFragmentAccess passed")
+}
+
+func TestConcurrentRegistryServiceFragmentAccess(t *testing.T) {
+	registryRoot, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry"))
+	assert.NilError(t, err)
+	fsys := newCountingFS(os.DirFS(registryRoot))
+	r, err := NewFSRegistry(fsys, RegistryConfig{VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true}})
+	assert.NilError(t, err)
+	pr, err := r.LoadProviderByName("google", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := pr.GetProviderService("compute")
+	assert.NilError(t, err)
+
+	hammerServiceFragments(t, func(resourceKey string) (Service, error) {
+		return r.GetServiceFragment(ps, resourceKey)
+	})
+
+	for _, doc := range []string{
+		"src/googleapis.com/v0.1.0/resources/compute-v1.yaml",
+		"src/googleapis.com/v0.1.0/services-split/compute/compute-v1.yaml",
+		"src/googleapis.com/v0.1.0/services-split/compute/compute-disks-v1.yaml",
+	} {
+		assert.Equal(t, fsys.getOpens(doc), 1, doc)
+	}
+
+	t.Logf("TestConcurrentRegistryServiceFragmentAccess passed")
+}
@@ -41,12 +41,18 @@ type DiscoveryDoc interface {
 type Loader interface {
 	LoadFromBytes(bytes []byte) (Service, error)
 	LoadFromBytesAndResources(rr ResourceRegister, resourceKey string, bytes []byte) (Service, error)
+	GetServiceDocBytes(url string) ([]byte, error)
+	LoadProviderByName(prov, version string) (Provider, error)
+	LoadProviderDocFromBytes(bytes []byte) (Provider, error)
+	LoadServiceDocFromBytes(ps ProviderService, bytes []byte) (Service, error)
+	LoadServiceDocFromFile(ps ProviderService, fileName string) (Service, error)
 	//
 	extractAndMergeQueryTransposeServiceLevel(svc Service) error
 }
 
 type standardLoader struct {
 	*openapi3.Loader
+	opts *LoaderOptions
 	//
 	visitedExpectedRequest  map[Schema]struct{}
 	visitedExpectedResponse map[Schema]struct{}
@@ -71,12 +77,13 @@ func loadResourcesShallow(ps ProviderService, bt []byte) (ResourceRegister, erro
 	}
 	rv.SetProvider(p)
 	rv.SetProviderService(ps)
+	rv.setDocDigest(DocDigest(bt))
 	resourceregisterLoadBackwardsCompatibility(rv)
 	return rv, nil
 }
 
 func (l *standardLoader) LoadFromBytes(bytes []byte) (Service, error) {
-	doc, err := l.LoadFromData(bytes)
+	doc, err := loadOpenapi3Doc(l.Loader, bytes)
 	if err != nil {
 		return nil, err
 	}
@@ -88,8 +95,36 @@ func (l *standardLoader) LoadFromBytes(bytes []byte) (Service, error) {
 	return svc, nil
 }
 
+// LoadFromBytesAndResources serves from the loader's service cache where the resources
+// were themselves read from a doc, else resolves and caches the subset.
 func (l *standardLoader) LoadFromBytesAndResources(rr ResourceRegister, resourceKey string, bytes []byte) (Service, error) {
-	doc, err := l.LoadFromData(bytes)
+	cache := l.opts.ServiceCache
+	if cache == nil || rr.getDocDigest() == "" {
+		return l.loadFromBytesAndResources(rr, resourceKey, bytes)
+	}
+	key := serviceCacheKey{docDigest: DocDigest(bytes), resourcesDigest: rr.getDocDigest(), resourceKey: resourceKey}
+	if cached, ok := cache.get(key); ok {
+		if svc, ok := cached.(*standardService); ok {
+			prov, ps := rr.getProvider(), rr.getProviderService()
+			if prov == nil {
+				prov = svc.Provider
+			}
+			if ps == nil {
+				ps = svc.ProviderService
+			}
+			return cloneCachedService(svc, prov, ps), nil
+		}
+	}
+	svc, err := l.loadFromBytesAndResources(rr, resourceKey, bytes)
+	if err != nil {
+		return nil, err
+	}
+	cache.put(key, svc)
+	return svc, nil
+}
+
+func (l *standardLoader) loadFromBytesAndResources(rr ResourceRegister, resourceKey string, bytes []byte) (Service, error) {
+	doc, err := loadOpenapi3Doc(l.Loader, bytes)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +138,12 @@ func (l *standardLoader) LoadFromBytesAndResources(rr ResourceRegister, resource
 	if err != nil {
 		return nil, err
 	}
+	if prov := rr.getProvider(); prov != nil {
+		svc.setProvider(prov)
+	}
+	if ps := rr.getProviderService(); ps != nil {
+		svc.setProviderService(ps)
+	}
 	return svc, nil
 }
 
@@ -325,6 +366,27 @@ func (l *standardLoader) mergeResource(svc Service,
 	}
 	rsc.setProvider(svc.getProvider())
 	rsc.setProviderService(svc.getProviderService())
+	return l.visitOperations(svc, rsc)
+}
+
+func (l *standardLoader) visitOperations(svc Service, rsc Resource) error {
+	if len(l.opts.OperationVisitors) == 0 {
+		return nil
+	}
+	methods := rsc.GetMethods()
+	keys := make([]string, 0, len(methods))
+	for k := range methods {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		op := methods[k]
+		for _, visitor := range l.opts.OperationVisitors {
+			if err := visitor(svc, rsc, &op); err != nil {
+				return fmt.Errorf("operation visitor failed for resource '%s' method '%s': %s", rsc.GetName(), k, err.Error())
+			}
+		}
+	}
 	return nil
 }
 
@@ -369,8 +431,13 @@ func (pr *standardProvider) ToYamlFile(filePath string) error {
 }
 
 func NewLoader() Loader {
+	return newStandardLoader(getLoaderOptions())
+}
+
+func newStandardLoader(opts *LoaderOptions) *standardLoader {
 	return &standardLoader{
 		&openapi3.Loader{Context: context.Background()},
+		opts,
 		make(map[Schema]struct{}),
 		make(map[Schema]struct{}),
 		make(map[*openapi3.Operation]struct{}),
@@ -502,7 +569,35 @@ func getProviderDoc(provider string) (string, error) {
 }
 
 func loadServiceDocFromBytes(ps ProviderService, bytes []byte) (Service, error) {
-	loader := NewLoader()
+	return loadServiceDocFromBytesWithLoader(newProviderServiceLoader(ps), ps, bytes)
+}
+
+// loadServiceDocFromBytesWithLoader serves from the loader's service cache where possible;
+// each hit is a copy of the cached service, bound to the supplied provider service.
+func loadServiceDocFromBytesWithLoader(loader *standardLoader, ps ProviderService, bytes []byte) (Service, error) {
+	cache := loader.opts.ServiceCache
+	if cache == nil {
+		return resolveServiceDocFromBytes(loader, ps, bytes)
+	}
+	key := serviceCacheKey{docDigest: DocDigest(bytes)}
+	if cached, ok := cache.get(key); ok {
+		if svc, ok := cached.(*standardService); ok {
+			prov, ok := ps.GetProvider()
+			if !ok {
+				return nil, fmt.Errorf("provider service '%s' does not have a provider", ps.GetID())
+			}
+			return cloneCachedService(svc, prov, ps), nil
+		}
+	}
+	rv, err := resolveServiceDocFromBytes(loader, ps, bytes)
+	if err != nil {
+		return nil, err
+	}
+	cache.put(key, rv)
+	return rv, nil
+}
+
+func resolveServiceDocFromBytes(loader *standardLoader, ps ProviderService, bytes []byte) (Service, error) {
 	rv, err := loader.LoadFromBytes(bytes)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,157 @@
+package openapistackql
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OperationVisitor is called for each operation resolved while loading a service doc;
+// an error aborts the load.
+type OperationVisitor func(svc Service, rsc Resource, op OperationStore) error
+
+// LoaderOptions scope doc resolution to a single loader,
+// in place of the package global OpenapiFileRoot.
+type LoaderOptions struct {
+	// FileRoot is the directory against which doc paths are resolved; defaults to OpenapiFileRoot.
+	FileRoot string
+	// DocSource, where set, supplies docs in place of FileRoot, eg: an embed.FS.
+	DocSource fs.FS
+	// Logger defaults to the logrus standard logger.
+	Logger *logrus.Logger
+	// OperationVisitors are called, in order, for each operation resolved;
+	// services served from ServiceCache are not revisited.
+	OperationVisitors []OperationVisitor
+	// ServiceCache, where set, holds resolved services for reuse across loads through these options;
+	// there is no default cache.
+	ServiceCache ServiceCache
+	// DocPatches, where set, are applied to each doc as it is read.
+	DocPatches *DocPatches
+}
+
+// getLoaderOptions snapshots the package globals, for callers not supplying options.
+func getLoaderOptions() *LoaderOptions {
+	return &LoaderOptions{
+		FileRoot: OpenapiFileRoot,
+	}
+}
+
+// NewLoaderWithOptions returns a loader whose providers and services,
+// including those lazily loaded thereafter, are resolved through the supplied options.
+func NewLoaderWithOptions(opts LoaderOptions) Loader {
+	if opts.FileRoot == "" && opts.DocSource == nil {
+		opts.FileRoot = OpenapiFileRoot
+	}
+	return newStandardLoader(&opts)
+}
+
+func (opts *LoaderOptions) getLogger() *logrus.Logger {
+	if opts == nil || opts.Logger == nil {
+		return logrus.StandardLogger()
+	}
+	return opts.Logger
+}
+
+func (opts *LoaderOptions) getDocSource() fs.FS {
+	if opts.DocSource != nil {
+		return opts.DocSource
+	}
+	return os.DirFS(opts.FileRoot)
+}
+
+func (opts *LoaderOptions) getDocBytes(url string) ([]byte, error) {
+	b, err := fs.ReadFile(opts.getDocSource(), fsPath(url))
+	if err != nil {
+		return nil, err
+	}
+	return opts.DocPatches.apply(url, b, opts.getLogger())
+}
+
+// getProviderDocPath returns the latest doc in the provider directory, eg: 'okta/v0.1.0/provider.yaml'.
+func (opts *LoaderOptions) getProviderDocPath(providerDir string) (string, error) {
+	dir := fsPath(providerDir)
+	entries, err := fs.ReadDir(opts.getDocSource(), dir)
+	if err != nil {
+		return "", err
+	}
+	var fileNames []string
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasSuffix(entry.Name(), ".sig") {
+			fileNames = append(fileNames, entry.Name())
+		}
+	}
+	if len(fileNames) == 0 {
+		return "", fmt.Errorf("no openapi files present in directory = '%s'", dir)
+	}
+	sort.Strings(fileNames)
+	return path.Join(dir, fileNames[len(fileNames)-1]), nil
+}
+
+func (l *standardLoader) GetServiceDocBytes(url string) ([]byte, error) {
+	return l.opts.getDocBytes(url)
+}
+
+func (l *standardLoader) LoadProviderByName(prov, version string) (Provider, error) {
+	prov = normaliseProviderName(prov)
+	docPath, err := l.opts.getProviderDocPath(path.Join(prov, version))
+	if err != nil {
+		return nil, err
+	}
+	b, err := l.opts.getDocBytes(docPath)
+	if err != nil {
+		return nil, err
+	}
+	l.opts.getLogger().Debugf("loaded provider doc '%s'\n", docPath)
+	return l.LoadProviderDocFromBytes(b)
+}
+
+func (l *standardLoader) LoadProviderDocFromBytes(bytes []byte) (Provider, error) {
+	pr, err := loadProviderDocFromBytes(bytes)
+	if err != nil {
+		return nil, err
+	}
+	pr.setLoaderOptions(l.opts)
+	return pr, nil
+}
+
+func (l *standardLoader) LoadServiceDocFromBytes(ps ProviderService, bytes []byte) (Service, error) {
+	return loadServiceDocFromBytesWithLoader(l, ps, bytes)
+}
+
+// LoadServiceDocFromFile reads fileName relative to the loader's doc source.
+func (l *standardLoader) LoadServiceDocFromFile(ps ProviderService, fileName string) (Service, error) {
+	b, err := l.opts.getDocBytes(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return loadServiceDocFromBytesWithLoader(l, ps, b)
+}
+
+// getProviderServiceLoaderOptions returns the options of the loader which produced the provider service, if any.
+func getProviderServiceLoaderOptions(ps ProviderService) *LoaderOptions {
+	if pr, ok := ps.GetProvider(); ok && pr != nil {
+		if opts, ok := pr.getLoaderOptions(); ok {
+			return opts
+		}
+	}
+	return nil
+}
+
+func getProviderServiceDocBytes(ps ProviderService, url string) ([]byte, error) {
+	if opts := getProviderServiceLoaderOptions(ps); opts != nil {
+		return opts.getDocBytes(url)
+	}
+	return getServiceDocBytes(url)
+}
+
+func newProviderServiceLoader(ps ProviderService) *standardLoader {
+	if opts := getProviderServiceLoaderOptions(ps); opts != nil {
+		return newStandardLoader(opts)
+	}
+	return newStandardLoader(getLoaderOptions())
+}
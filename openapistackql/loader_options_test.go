@@ -0,0 +1,98 @@
+package openapistackql_test
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	"github.com/sirupsen/logrus"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+
+	"gotest.tools/assert"
+)
+
+func TestLoaderWithOptionsFileRoot(t *testing.T) {
+	fileRoot, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry", "src"))
+	assert.NilError(t, err)
+	// the globals must play no part in a loader built from options
+	originalRoot := OpenapiFileRoot
+	OpenapiFileRoot = t.TempDir()
+	defer func() { OpenapiFileRoot = originalRoot }()
+
+	visited := make(map[string]int)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	l := NewLoaderWithOptions(LoaderOptions{
+		FileRoot: fileRoot,
+		Logger:   logger,
+		OperationVisitors: []OperationVisitor{
+			func(svc Service, rsc Resource, op OperationStore) error {
+				visited[fmt.Sprintf("%s.%s", rsc.GetName(), op.GetName())]++
+				return nil
+			},
+		},
+	})
+
+	pr, err := l.LoadProviderByName("okta", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := pr.GetProviderService("application")
+	assert.NilError(t, err)
+	svc, err := ps.GetService()
+	assert.NilError(t, err)
+	assert.Equal(t, svc.GetName(), "application")
+
+	rscs, err := svc.GetResources()
+	assert.NilError(t, err)
+	expected := 0
+	for _, rsc := range rscs {
+		expected += len(rsc.GetMethods())
+	}
+	assert.Assert(t, expected > 0)
+	assert.Equal(t, len(visited), expected)
+	for k, v := range visited {
+		assert.Equal(t, v, 1, k)
+	}
+
+	_, err = LoadProviderByName("okta", "v0.1.0")
+	assert.Assert(t, err != nil)
+
+	t.Logf("TestLoaderWithOptionsFileRoot passed")
+}
+
+func TestLoaderWithOptionsDocSource(t *testing.T) {
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry", "src", "mockableprovider"))
+	assert.NilError(t, err)
+	docSource := fstest.MapFS{}
+	for k, v := range mapFSFromDir(t, srcRoot) {
+		docSource["mockableprovider/"+k] = v
+	}
+
+	l := NewLoaderWithOptions(LoaderOptions{DocSource: docSource})
+	pr, err := l.LoadProviderByName("mockableprovider", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := pr.GetProviderService("mockable_service")
+	assert.NilError(t, err)
+	svc, err := l.LoadServiceDocFromFile(ps, "mockableprovider/v0.1.0/services/mockable_service.yaml")
+	assert.NilError(t, err)
+	assert.Equal(t, svc.GetName(), "Mockable Service for a Mockable Provider")
+	lazySvc, err := ps.GetService()
+	assert.NilError(t, err)
+	assert.Equal(t, lazySvc.GetName(), svc.GetName())
+
+	failing := NewLoaderWithOptions(LoaderOptions{
+		DocSource: docSource,
+		OperationVisitors: []OperationVisitor{
+			func(svc Service, rsc Resource, op OperationStore) error {
+				return fmt.Errorf("rejected")
+			},
+		},
+	})
+	_, err = failing.LoadServiceDocFromFile(ps, "mockableprovider/v0.1.0/services/mockable_service.yaml")
+	assert.ErrorContains(t, err, "operation visitor failed")
+
+	t.Logf("TestLoaderWithOptionsDocSource passed")
+}
@@ -0,0 +1,191 @@
+package openapistackql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	lockfileVersion    int    = 1
+	lockedDigestPrefix string = "sha256:"
+)
+
+// LockedProvider records the provider version resolved
+// and the digest of every doc loaded for it, keyed by doc path.
+type LockedProvider struct {
+	Version string            `json:"version" yaml:"version"`
+	Docs    map[string]string `json:"docs" yaml:"docs"`
+}
+
+// Lockfile pins providers to the exact versions and doc contents
+// first loaded through a Registry, so that later loads are reproducible.
+type Lockfile struct {
+	Version   int                       `json:"version" yaml:"version"`
+	Providers map[string]LockedProvider `json:"providers" yaml:"providers"`
+}
+
+func NewLockfile() Lockfile {
+	return Lockfile{
+		Version:   lockfileVersion,
+		Providers: make(map[string]LockedProvider),
+	}
+}
+
+// ReadLockfile reads the lockfile at path; a missing file yields an empty lockfile.
+func ReadLockfile(path string) (Lockfile, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return NewLockfile(), nil
+	}
+	if err != nil {
+		return Lockfile{}, err
+	}
+	lf := NewLockfile()
+	if err := yaml.Unmarshal(b, &lf); err != nil {
+		return Lockfile{}, fmt.Errorf("cannot read lockfile '%s': %s", path, err.Error())
+	}
+	if lf.Version != lockfileVersion {
+		return Lockfile{}, fmt.Errorf("lockfile '%s' has unsupported version %d", path, lf.Version)
+	}
+	if lf.Providers == nil {
+		lf.Providers = make(map[string]LockedProvider)
+	}
+	return lf, nil
+}
+
+// Write persists the lockfile to path, replacing any existing file atomically.
+func (lf Lockfile) Write(path string) error {
+	b, err := yaml.Marshal(lf)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, fmt.Sprintf(".%s-*", filepath.Base(path)))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (lf Lockfile) GetLockedVersion(prov string) (string, bool) {
+	lp, ok := lf.Providers[normaliseProviderName(prov)]
+	if !ok || lp.Version == "" {
+		return "", false
+	}
+	return lp.Version, true
+}
+
+func (lf Lockfile) clone() Lockfile {
+	rv := NewLockfile()
+	for k, v := range lf.Providers {
+		docs := make(map[string]string, len(v.Docs))
+		for p, d := range v.Docs {
+			docs[p] = d
+		}
+		rv.Providers[k] = LockedProvider{Version: v.Version, Docs: docs}
+	}
+	return rv
+}
+
+func DocDigest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return lockedDigestPrefix + hex.EncodeToString(sum[:])
+}
+
+// docLock enforces and records a Lockfile for a Registry,
+// persisting it whenever a new doc or version is recorded.
+// Absent update, loading any version other than that locked is an error.
+type docLock struct {
+	mutex    sync.Mutex
+	path     string
+	update   bool
+	lockfile Lockfile
+}
+
+func newDocLock(path string, update bool) (*docLock, error) {
+	lf, err := ReadLockfile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &docLock{
+		path:     path,
+		update:   update,
+		lockfile: lf,
+	}, nil
+}
+
+func (dl *docLock) getLockfile() Lockfile {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+	return dl.lockfile.clone()
+}
+
+func (dl *docLock) getLockedVersion(prov string) (string, bool) {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+	return dl.lockfile.GetLockedVersion(prov)
+}
+
+// checkVersion refuses a version other than that locked for the provider, unless updating.
+func (dl *docLock) checkVersion(prov string, version string) error {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+	return dl.checkVersionUnlocked(normaliseProviderName(prov), version)
+}
+
+func (dl *docLock) checkVersionUnlocked(prov string, version string) error {
+	lp, ok := dl.lockfile.Providers[prov]
+	if !ok || lp.Version == "" || lp.Version == version || dl.update {
+		return nil
+	}
+	return fmt.Errorf("provider '%s' is locked to version '%s' in lockfile '%s'; cannot load version '%s' without updating the lockfile", prov, lp.Version, dl.path, version)
+}
+
+// checkAndRecord verifies the doc against any recorded digest, else records it.
+// Docs are attributed to the provider and version named by
+// the leading elements of their path, eg: 'okta/v0.1.0/provider.yaml';
+// when updating, loading a different version of a provider replaces its record.
+func (dl *docLock) checkAndRecord(docPath string, b []byte) error {
+	elems := strings.Split(strings.TrimPrefix(docPath, "/"), "/")
+	if len(elems) < 3 {
+		return nil
+	}
+	prov, version := elems[0], elems[1]
+	digest := DocDigest(b)
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+	if err := dl.checkVersionUnlocked(prov, version); err != nil {
+		return err
+	}
+	lp, ok := dl.lockfile.Providers[prov]
+	if !ok || lp.Version != version {
+		lp = LockedProvider{Version: version, Docs: make(map[string]string)}
+	}
+	if locked, ok := lp.Docs[docPath]; ok {
+		if locked != digest {
+			return fmt.Errorf("doc '%s' does not match lockfile '%s': locked digest '%s', found '%s'", docPath, dl.path, locked, digest)
+		}
+		return nil
+	}
+	lp.Docs[docPath] = digest
+	dl.lockfile.Providers[prov] = lp
+	return dl.lockfile.Write(dl.path)
+}
@@ -0,0 +1,148 @@
+package openapistackql_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+
+	"gotest.tools/assert"
+)
+
+func newLockedTestRegistry(t *testing.T, registryRoot string, lockfilePath string) RegistryAPI {
+	reg, err := NewRegistry(
+		RegistryConfig{
+			RegistryURL:   fmt.Sprintf("file://%s", registryRoot),
+			VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+			LockfilePath:  lockfilePath,
+		},
+		nil,
+	)
+	assert.NilError(t, err)
+	return reg
+}
+
+func loadLockedMockableService(t *testing.T, reg RegistryAPI, version string) error {
+	prov, err := reg.LoadProviderByName("mockableprovider", version)
+	if err != nil {
+		return err
+	}
+	ps, err := prov.GetProviderService("mockable_service")
+	assert.NilError(t, err)
+	_, err = reg.GetService(ps)
+	return err
+}
+
+func TestRegistryLockfile(t *testing.T) {
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry/src/mockableprovider/v0.1.0")
+	assert.NilError(t, err)
+	registryRoot := t.TempDir()
+	provRoot := filepath.Join(registryRoot, "src", "mockableprovider")
+	copyTree(t, srcRoot, filepath.Join(provRoot, "v0.1.0"))
+	lockfilePath := filepath.Join(t.TempDir(), "stackql.lock")
+
+	reg := newLockedTestRegistry(t, registryRoot, lockfilePath)
+	assert.NilError(t, loadLockedMockableService(t, reg, "^0.1"))
+	lf, ok := reg.GetLockfile()
+	assert.Assert(t, ok)
+	locked := lf.Providers["mockableprovider"]
+	assert.Equal(t, locked.Version, "v0.1.0")
+	assert.Equal(t, len(locked.Docs), 2)
+	serviceDocPath := "mockableprovider/v0.1.0/services/mockable_service.yaml"
+	b, err := os.ReadFile(filepath.Join(provRoot, "v0.1.0", "services", "mockable_service.yaml"))
+	assert.NilError(t, err)
+	assert.Equal(t, locked.Docs[serviceDocPath], DocDigest(b))
+
+	persisted, err := ReadLockfile(lockfilePath)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, persisted, lf)
+
+	// a newer matching version does not displace the locked version
+	copyTree(t, filepath.Join(provRoot, "v0.1.0"), filepath.Join(provRoot, "v0.1.1"))
+	for _, p := range []string{"provider.yaml", "services/mockable_service.yaml"} {
+		fp := filepath.Join(provRoot, "v0.1.1", p)
+		b, err := os.ReadFile(fp)
+		assert.NilError(t, err)
+		assert.NilError(t, os.WriteFile(fp, []byte(strings.ReplaceAll(string(b), "v0.1.0", "v0.1.1")), 0644))
+	}
+	reg = newLockedTestRegistry(t, registryRoot, lockfilePath)
+	v, err := reg.ResolveProviderVersion("mockableprovider", "^0.1")
+	assert.NilError(t, err)
+	assert.Equal(t, v, "v0.1.0")
+	v, err = newLockedTestRegistry(t, registryRoot, "").ResolveProviderVersion("mockableprovider", "^0.1")
+	assert.NilError(t, err)
+	assert.Equal(t, v, "v0.1.1")
+
+	// republishing a doc under the same version is refused
+	assert.NilError(t, os.WriteFile(filepath.Join(provRoot, "v0.1.0", "services", "mockable_service.yaml"), append(b, []byte("\n# republished\n")...), 0644))
+	err = loadLockedMockableService(t, reg, "^0.1")
+	assert.ErrorContains(t, err, fmt.Sprintf("doc '%s' does not match lockfile", serviceDocPath))
+
+	t.Logf("TestRegistryLockfile passed")
+}
+
+func TestRegistryLockfileVersionMismatch(t *testing.T) {
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry/src/mockableprovider/v0.1.0")
+	assert.NilError(t, err)
+	registryRoot := t.TempDir()
+	provRoot := filepath.Join(registryRoot, "src", "mockableprovider")
+	copyTree(t, srcRoot, filepath.Join(provRoot, "v0.1.0"))
+	copyTree(t, srcRoot, filepath.Join(provRoot, "v0.2.0"))
+	for _, p := range []string{"provider.yaml", "services/mockable_service.yaml"} {
+		fp := filepath.Join(provRoot, "v0.2.0", p)
+		b, err := os.ReadFile(fp)
+		assert.NilError(t, err)
+		assert.NilError(t, os.WriteFile(fp, []byte(strings.ReplaceAll(string(b), "v0.1.0", "v0.2.0")), 0644))
+	}
+	lockfilePath := filepath.Join(t.TempDir(), "stackql.lock")
+
+	// exact versions are locked as are constraints
+	reg := newLockedTestRegistry(t, registryRoot, lockfilePath)
+	assert.NilError(t, loadLockedMockableService(t, reg, "v0.1.0"))
+	locked, err := os.ReadFile(lockfilePath)
+	assert.NilError(t, err)
+
+	for _, version := range []string{"v0.2.0", "^0.2"} {
+		err = loadLockedMockableService(t, newLockedTestRegistry(t, registryRoot, lockfilePath), version)
+		assert.ErrorContains(t, err, "provider 'mockableprovider' is locked to version 'v0.1.0'")
+	}
+	b, err := os.ReadFile(lockfilePath)
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), string(locked))
+
+	// republishing a doc is refused on exact version loads
+	servicePath := filepath.Join(provRoot, "v0.1.0", "services", "mockable_service.yaml")
+	original, err := os.ReadFile(servicePath)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(servicePath, append(original, []byte("\n# republished\n")...), 0644))
+	err = loadLockedMockableService(t, newLockedTestRegistry(t, registryRoot, lockfilePath), "v0.1.0")
+	assert.ErrorContains(t, err, "does not match lockfile")
+	assert.NilError(t, os.WriteFile(servicePath, original, 0644))
+
+	// an explicit update replaces the locked version
+	reg, err = NewRegistry(
+		RegistryConfig{
+			RegistryURL:    fmt.Sprintf("file://%s", registryRoot),
+			VerfifyConfig:  &edcrypto.VerifierConfig{NopVerify: true},
+			LockfilePath:   lockfilePath,
+			LockfileUpdate: true,
+		},
+		nil,
+	)
+	assert.NilError(t, err)
+	assert.NilError(t, loadLockedMockableService(t, reg, "v0.2.0"))
+	persisted, err := ReadLockfile(lockfilePath)
+	assert.NilError(t, err)
+	v, ok := persisted.GetLockedVersion("mockableprovider")
+	assert.Assert(t, ok)
+	assert.Equal(t, v, "v0.2.0")
+	assert.Equal(t, len(persisted.Providers["mockableprovider"].Docs), 2)
+
+	t.Logf("TestRegistryLockfileVersionMismatch passed")
+}
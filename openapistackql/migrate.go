@@ -0,0 +1,376 @@
+package openapistackql
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DocMigrationKindPathRef is the merge of a deprecated method 'path' ref
+	// into its 'operation' ref.
+	DocMigrationKindPathRef string = "pathRef"
+	// DocMigrationKindVerbCase is the lower casing of the http verb
+	// terminating an operation ref.
+	DocMigrationKindVerbCase string = "verbCase"
+)
+
+// DocMigrationChange locates a single deprecated construct rewritten.
+// File is relative to the doc root and Pointer is a JSON pointer into that file.
+type DocMigrationChange struct {
+	File    string `json:"file" yaml:"file"`
+	Pointer string `json:"pointer" yaml:"pointer"`
+	Kind    string `json:"kind" yaml:"kind"`
+	From    string `json:"from" yaml:"from"`
+	To      string `json:"to" yaml:"to"`
+}
+
+func (c DocMigrationChange) String() string {
+	return fmt.Sprintf("%s#%s: %s '%s' -> '%s'", c.File, c.Pointer, c.Kind, c.From, c.To)
+}
+
+// DocMigrationReport is the outcome of migrating a provider doc tree.
+type DocMigrationReport struct {
+	Changes []DocMigrationChange `json:"changes" yaml:"changes"`
+	// Files are the docs rewritten, or which would be in a dry run.
+	Files []string `json:"files" yaml:"files"`
+	// Findings are those problems found on re-loading the migrated tree
+	// which were not present beforehand; files are only written absent any.
+	Findings []ValidationFinding `json:"findings" yaml:"findings"`
+}
+
+type ProviderMigrator interface {
+	Migrate(providerDocPath string) (DocMigrationReport, error)
+}
+
+type standardProviderMigrator struct {
+	docRoot string
+	dryRun  bool
+	docs    map[string][]byte
+	visited map[string]struct{}
+	report  DocMigrationReport
+}
+
+// NewProviderMigrator returns a migrator that rewrites deprecated constructs,
+// as otherwise patched in memory on load, into the current canonical format.
+// Docs are read from docRoot and, but for a dry run, rewritten in place there once the migrated
+// tree, held in memory, validates as by NewProviderValidator with no findings absent beforehand.
+func NewProviderMigrator(docRoot string, dryRun bool) ProviderMigrator {
+	return &standardProviderMigrator{
+		docRoot: docRoot,
+		dryRun:  dryRun,
+	}
+}
+
+// Migrate rewrites the provider doc at providerDocPath and every service and
+// resources doc reachable from it.  The migrated tree is validated before
+// anything is written; docs which cannot be read are left to validation to report.
+func (m *standardProviderMigrator) Migrate(providerDocPath string) (DocMigrationReport, error) {
+	m.docs = make(map[string][]byte)
+	m.visited = make(map[string]struct{})
+	m.report = DocMigrationReport{}
+	b, err := os.ReadFile(path.Join(m.docRoot, providerDocPath))
+	if err != nil {
+		return m.report, err
+	}
+	pr, err := loadProviderDocFromBytes(b)
+	if err != nil {
+		return m.report, fmt.Errorf("cannot parse provider doc '%s': %s", providerDocPath, err.Error())
+	}
+	prov := pr.(*standardProvider)
+	for _, k := range sortedKeys(prov.ProviderServices) {
+		ps := prov.ProviderServices[k]
+		if rrPath := ps.getResourcesRefRef(); rrPath != "" {
+			svcPaths, err := m.migrateResourcesDoc(rrPath)
+			if err != nil {
+				return m.report, err
+			}
+			for _, svcPath := range svcPaths {
+				if err := m.migrateServiceDoc(svcPath); err != nil {
+					return m.report, err
+				}
+			}
+			continue
+		}
+		if svcPath := ps.getServiceRefRef(); svcPath != "" {
+			if err := m.migrateServiceDoc(svcPath); err != nil {
+				return m.report, err
+			}
+		}
+	}
+	m.report.Files = sortedKeys(m.docs)
+	if len(m.docs) == 0 {
+		return m.report, nil
+	}
+	before, err := NewProviderValidator(m.docRoot).Validate(providerDocPath)
+	if err != nil {
+		return m.report, err
+	}
+	after, err := newProviderValidatorWithDocs(m.docRoot, m.docs).Validate(providerDocPath)
+	if err != nil {
+		return m.report, err
+	}
+	m.report.Findings = getIntroducedFindings(before, after)
+	if m.dryRun || len(m.report.Findings) > 0 {
+		return m.report, nil
+	}
+	for _, docPath := range m.report.Files {
+		if err := os.WriteFile(path.Join(m.docRoot, docPath), m.docs[docPath], ConfigFilesMode); err != nil {
+			return m.report, err
+		}
+	}
+	return m.report, nil
+}
+
+// getIntroducedFindings disregards pointers, as these move with migrated refs.
+func getIntroducedFindings(before []ValidationFinding, after []ValidationFinding) []ValidationFinding {
+	seen := make(map[string]int)
+	for _, f := range before {
+		seen[f.File+"\x00"+f.Message]++
+	}
+	var rv []ValidationFinding
+	for _, f := range after {
+		k := f.File + "\x00" + f.Message
+		if seen[k] > 0 {
+			seen[k]--
+			continue
+		}
+		rv = append(rv, f)
+	}
+	return rv
+}
+
+// docEdits are the textual rewrites of a single doc, made in place
+// so that all else in the doc is left byte for byte unchanged.
+type docEdits struct {
+	lines   []string
+	replace map[int]docEdit
+	remove  map[int]struct{}
+}
+
+// docEdit replaces the columns [start, end) of a line.
+type docEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+func newDocEdits(b []byte) *docEdits {
+	return &docEdits{
+		lines:   strings.Split(string(b), "\n"),
+		replace: make(map[int]docEdit),
+		remove:  make(map[int]struct{}),
+	}
+}
+
+func (de *docEdits) isEmpty() bool {
+	return len(de.replace) == 0 && len(de.remove) == 0
+}
+
+// replaceScalar rewrites a single line scalar as single quoted.
+func (de *docEdits) replaceScalar(node *yaml.Node, s string) bool {
+	line := node.Line - 1
+	start := node.Column - 1
+	if line < 0 || line >= len(de.lines) || start < 0 || start >= len(de.lines[line]) {
+		return false
+	}
+	if _, ok := de.replace[line]; ok {
+		return false
+	}
+	raw := de.lines[line]
+	end := start + len(node.Value)
+	switch raw[start] {
+	case '\'', '"':
+		closing := strings.IndexByte(raw[start+1:], raw[start])
+		if closing < 0 {
+			return false
+		}
+		end = start + 1 + closing + 1
+	}
+	if end > len(raw) || (raw[start] != '\'' && raw[start] != '"' && raw[start:end] != node.Value) {
+		return false
+	}
+	de.replace[line] = docEdit{start: start, end: end, text: "'" + strings.ReplaceAll(s, "'", "''") + "'"}
+	return true
+}
+
+// removeEntry drops the lines of a block mapping entry.
+func (de *docEdits) removeEntry(key *yaml.Node, value *yaml.Node) {
+	for i := key.Line - 1; i < getYAMLLastLine(value); i++ {
+		de.remove[i] = struct{}{}
+	}
+}
+
+func (de *docEdits) bytes() []byte {
+	rv := make([]string, 0, len(de.lines))
+	for i, line := range de.lines {
+		if _, ok := de.remove[i]; ok {
+			continue
+		}
+		if e, ok := de.replace[i]; ok {
+			line = line[:e.start] + e.text + line[e.end:]
+		}
+		rv = append(rv, line)
+	}
+	return []byte(strings.Join(rv, "\n"))
+}
+
+// readDocNode returns nil for docs previously visited or absent.
+func (m *standardProviderMigrator) readDocNode(docPath string) (*yaml.Node, *docEdits, error) {
+	if _, ok := m.visited[docPath]; ok {
+		return nil, nil, nil
+	}
+	m.visited[docPath] = struct{}{}
+	b, err := os.ReadFile(path.Join(m.docRoot, docPath))
+	if err != nil {
+		return nil, nil, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, nil, fmt.Errorf("cannot parse doc '%s': %s", docPath, err.Error())
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, nil
+	}
+	return doc.Content[0], newDocEdits(b), nil
+}
+
+func (m *standardProviderMigrator) migrateServiceDoc(svcPath string) error {
+	root, edits, err := m.readDocNode(svcPath)
+	if root == nil || err != nil {
+		return err
+	}
+	rscs := getYAMLMappingValue(getYAMLMappingValue(root, "components"), ExtensionKeyResources)
+	forEachYAMLMappingEntry(rscs, func(k string, rsc *yaml.Node) {
+		m.migrateMethods(svcPath, jsonPointer("components", ExtensionKeyResources, k), rsc, "", edits)
+	})
+	if !edits.isEmpty() {
+		m.docs[svcPath] = edits.bytes()
+	}
+	return nil
+}
+
+// migrateResourcesDoc returns the service docs referenced therein.
+func (m *standardProviderMigrator) migrateResourcesDoc(rrPath string) ([]string, error) {
+	root, edits, err := m.readDocNode(rrPath)
+	if root == nil || err != nil {
+		return nil, err
+	}
+	topSvcPath := getYAMLRef(getYAMLMappingValue(root, "serviceDoc"))
+	svcPaths := make(map[string]struct{})
+	if topSvcPath != "" {
+		svcPaths[topSvcPath] = struct{}{}
+	}
+	forEachYAMLMappingEntry(getYAMLMappingValue(root, "resources"), func(k string, rsc *yaml.Node) {
+		svcPath := getYAMLRef(getYAMLMappingValue(rsc, "serviceDoc"))
+		if svcPath == "" {
+			svcPath = topSvcPath
+		} else {
+			svcPaths[svcPath] = struct{}{}
+		}
+		m.migrateMethods(rrPath, jsonPointer("resources", k), rsc, svcPath, edits)
+	})
+	if !edits.isEmpty() {
+		m.docs[rrPath] = edits.bytes()
+	}
+	return sortedKeys(svcPaths), nil
+}
+
+// migrateMethods rewrites the operation refs of a resource, those in
+// resources docs being qualified with the service doc path, as on load.
+// Methods in flow style are left to the load time shims.
+func (m *standardProviderMigrator) migrateMethods(docPath string, ptr string, rsc *yaml.Node, svcPath string, edits *docEdits) {
+	forEachYAMLMappingEntry(getYAMLMappingValue(rsc, "methods"), func(k string, method *yaml.Node) {
+		refNode := getYAMLMappingValue(getYAMLMappingValue(method, "operation"), "$ref")
+		if refNode == nil || refNode.Kind != yaml.ScalarNode || method.Style&yaml.FlowStyle != 0 {
+			return
+		}
+		mPtr := ptr + jsonPointer("methods", k)
+		if pathKey, pathNode := getYAMLMappingEntry(method, "path"); pathNode != nil {
+			pathRef := getYAMLRef(pathNode)
+			if pathRef == "" {
+				return
+			}
+			to := svcPath + "#/paths/" + strings.ReplaceAll(pathRef, "/", "~1") + "/" + strings.ToLower(refNode.Value)
+			if !edits.replaceScalar(refNode, to) {
+				return
+			}
+			edits.removeEntry(pathKey, pathNode)
+			m.report.Changes = append(m.report.Changes, DocMigrationChange{
+				File:    docPath,
+				Pointer: mPtr + jsonPointer("path", "$ref"),
+				Kind:    DocMigrationKindPathRef,
+				From:    fmt.Sprintf("%s %s", refNode.Value, pathRef),
+				To:      to,
+			})
+			return
+		}
+		idx := strings.LastIndex(refNode.Value, "/")
+		if !strings.Contains(refNode.Value, "#") || idx < 0 {
+			return
+		}
+		verb := refNode.Value[idx+1:]
+		if verb == strings.ToLower(verb) {
+			return
+		}
+		to := refNode.Value[:idx+1] + strings.ToLower(verb)
+		if !edits.replaceScalar(refNode, to) {
+			return
+		}
+		m.report.Changes = append(m.report.Changes, DocMigrationChange{
+			File:    docPath,
+			Pointer: mPtr + jsonPointer("operation", "$ref"),
+			Kind:    DocMigrationKindVerbCase,
+			From:    refNode.Value,
+			To:      to,
+		})
+	})
+}
+
+func getYAMLMappingValue(node *yaml.Node, key string) *yaml.Node {
+	_, rv := getYAMLMappingEntry(node, key)
+	return rv
+}
+
+func getYAMLMappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func forEachYAMLMappingEntry(node *yaml.Node, f func(string, *yaml.Node)) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		f(node.Content[i].Value, node.Content[i+1])
+	}
+}
+
+// getYAMLLastLine returns the last line spanned by a node.
+func getYAMLLastLine(node *yaml.Node) int {
+	rv := node.Line
+	for _, c := range node.Content {
+		if l := getYAMLLastLine(c); l > rv {
+			rv = l
+		}
+	}
+	return rv
+}
+
+func getYAMLRef(node *yaml.Node) string {
+	refNode := getYAMLMappingValue(node, "$ref")
+	if refNode == nil {
+		return ""
+	}
+	return refNode.Value
+}
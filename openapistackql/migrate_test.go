@@ -0,0 +1,128 @@
+package openapistackql_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+
+	"gotest.tools/assert"
+)
+
+func TestMigrateDeprecatedServiceDocs(t *testing.T) {
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry/deprecated-src/okta/v1")
+	assert.NilError(t, err)
+	docRoot := t.TempDir()
+	copyTree(t, srcRoot, filepath.Join(docRoot, "okta", "v1"))
+	appPath := filepath.Join(docRoot, "okta", "v1", "services", "Application.yaml")
+	original, err := os.ReadFile(appPath)
+	assert.NilError(t, err)
+
+	report, err := NewProviderMigrator(docRoot, true).Migrate("okta/v1/provider.yaml")
+	assert.NilError(t, err)
+	assert.Equal(t, len(report.Changes), 284)
+	assert.Equal(t, len(report.Files), 24)
+	assert.Equal(t, len(report.Findings), 0)
+	for _, c := range report.Changes {
+		assert.Equal(t, c.Kind, DocMigrationKindVerbCase)
+	}
+	unchanged, err := os.ReadFile(appPath)
+	assert.NilError(t, err)
+	assert.Equal(t, string(unchanged), string(original))
+
+	report, err = NewProviderMigrator(docRoot, false).Migrate("okta/v1/provider.yaml")
+	assert.NilError(t, err)
+	assert.Equal(t, len(report.Changes), 284)
+	migrated, err := os.ReadFile(appPath)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(migrated), "/POST'"))
+	// only the refs themselves are rewritten
+	assert.Equal(t, strings.Count(string(migrated), "\n"), strings.Count(string(original), "\n"))
+
+	report, err = NewProviderMigrator(docRoot, false).Migrate("okta/v1/provider.yaml")
+	assert.NilError(t, err)
+	assert.Equal(t, len(report.Changes), 0)
+
+	l := NewLoaderWithOptions(LoaderOptions{FileRoot: docRoot})
+	pr, err := l.LoadProviderByName("okta", "v1")
+	assert.NilError(t, err)
+	ps, err := pr.GetProviderService("application")
+	assert.NilError(t, err)
+	svc, err := ps.GetService()
+	assert.NilError(t, err)
+	apps, err := svc.GetResource("apps")
+	assert.NilError(t, err)
+	activate, err := apps.FindMethod("activate")
+	assert.NilError(t, err)
+	assert.Equal(t, activate.GetOperationRef().Ref, "#/paths/~1api~1v1~1apps~1{appId}~1lifecycle~1activate/post")
+	assert.Equal(t, activate.GetOperationRef().Value.OperationID, "activateApplication")
+
+	t.Logf("TestMigrateDeprecatedServiceDocs passed")
+}
+
+func TestMigrateDeprecatedResourcesDoc(t *testing.T) {
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry/src/mockableprovider/v0.1.0")
+	assert.NilError(t, err)
+	docRoot := t.TempDir()
+	provRoot := filepath.Join(docRoot, "mockableprovider", "v0.1.0")
+	copyTree(t, srcRoot, provRoot)
+	assert.NilError(t, os.WriteFile(filepath.Join(provRoot, "provider.yaml"), []byte(`id: mockableprovider
+name: mockableprovider
+version: v0.1.0
+providerServices:
+  mockable_service:
+    id: mockable_service:v0.1.0
+    name: mockable_service
+    preferred: true
+    resources:
+      $ref: mockableprovider/v0.1.0/resources/mockable_service.yaml
+    title: Mockable Service for Testing
+    version: v0.1.0
+`), 0644))
+	assert.NilError(t, os.MkdirAll(filepath.Join(provRoot, "resources"), 0755))
+	rrPath := filepath.Join(provRoot, "resources", "mockable_service.yaml")
+	assert.NilError(t, os.WriteFile(rrPath, []byte(`serviceDoc:
+  $ref: mockableprovider/v0.1.0/services/mockable_service.yaml
+resources:
+  widgets:
+    id: mockableprovider.mockable_service.widgets
+    name: widgets
+    title: Widgets
+    methods:
+      list:
+        operation:
+          $ref: get
+        path:
+          $ref: /projects/{project}/widgets
+        response:
+          mediaType: application/json
+          openAPIDocKey: '200'
+          objectKey: $.items
+`), 0644))
+
+	report, err := NewProviderMigrator(docRoot, false).Migrate("mockableprovider/v0.1.0/provider.yaml")
+	assert.NilError(t, err)
+	assert.Equal(t, len(report.Findings), 0)
+	assert.DeepEqual(t, report.Files, []string{"mockableprovider/v0.1.0/resources/mockable_service.yaml"})
+	assert.DeepEqual(t, report.Changes, []DocMigrationChange{
+		{
+			File:    "mockableprovider/v0.1.0/resources/mockable_service.yaml",
+			Pointer: "/resources/widgets/methods/list/path/$ref",
+			Kind:    DocMigrationKindPathRef,
+			From:    "get /projects/{project}/widgets",
+			To:      "mockableprovider/v0.1.0/services/mockable_service.yaml#/paths/~1projects~1{project}~1widgets/get",
+		},
+	})
+	b, err := os.ReadFile(rrPath)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(b), "path:"))
+
+	findings, err := NewProviderValidator(docRoot).Validate("mockableprovider/v0.1.0/provider.yaml")
+	assert.NilError(t, err)
+	assert.Equal(t, len(findings), 0)
+
+	t.Logf("TestMigrateDeprecatedResourcesDoc passed")
+}
@@ -0,0 +1,183 @@
+package openapistackql
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stackql/go-openapistackql/pkg/compression"
+	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	_ ProviderPackager = &standardProviderPackager{}
+)
+
+const (
+	defaultSignatureEncoding string = "base64"
+	defaultPrivateKeyFormat  string = "pem"
+)
+
+type PackagerConfig struct {
+	RegistryRoot     string  `json:"registryRoot" yaml:"registryRoot"` // contains src and dist trees
+	SrcPrefix        *string `json:"srcPrefix" yaml:"srcPrefix"`
+	DistPrefix       *string `json:"distPrefix" yaml:"distPrefix"`
+	PrivateKeyPath   string  `json:"privateKeyPath" yaml:"privateKeyPath"` // Ed25519 private key
+	PrivateKeyFormat string  `json:"privateKeyFormat" yaml:"privateKeyFormat"`
+}
+
+type ProviderPackager interface {
+	// Package signs every doc under 'src/<provider>/<version>', writes and signs
+	// the archive 'dist/<provider>/<version>.tgz' and records the version in 'dist/providers.yaml'.
+	Package(prov string, version string) error
+}
+
+type standardProviderPackager struct {
+	srcRoot          string
+	distRoot         string
+	privateKeyPath   string
+	privateKeyFormat string
+}
+
+func NewProviderPackager(cfg PackagerConfig) (ProviderPackager, error) {
+	if cfg.RegistryRoot == "" {
+		return nil, fmt.Errorf("packager requires a registry root")
+	}
+	if cfg.PrivateKeyPath == "" {
+		return nil, fmt.Errorf("packager requires a private key")
+	}
+	srcPrefix := defaultSrcPrefix
+	if cfg.SrcPrefix != nil {
+		srcPrefix = *cfg.SrcPrefix
+	}
+	distPrefix := defaultDistPrefix
+	if cfg.DistPrefix != nil {
+		distPrefix = *cfg.DistPrefix
+	}
+	keyFormat := cfg.PrivateKeyFormat
+	if keyFormat == "" {
+		keyFormat = defaultPrivateKeyFormat
+	}
+	return &standardProviderPackager{
+		srcRoot:          filepath.Join(cfg.RegistryRoot, srcPrefix),
+		distRoot:         filepath.Join(cfg.RegistryRoot, distPrefix),
+		privateKeyPath:   cfg.PrivateKeyPath,
+		privateKeyFormat: keyFormat,
+	}, nil
+}
+
+func (pp *standardProviderPackager) Package(prov string, version string) error {
+	versionDir := filepath.Join(pp.srcRoot, prov, version)
+	fi, err := os.Stat(versionDir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", versionDir)
+	}
+	if err := pp.signDocs(versionDir); err != nil {
+		return err
+	}
+	if err := pp.writeArchive(versionDir, prov, version); err != nil {
+		return err
+	}
+	return pp.updateProvidersList(prov, version)
+}
+
+func (pp *standardProviderPackager) signDocs(versionDir string) error {
+	return filepath.WalkDir(versionDir, func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() || strings.HasSuffix(d.Name(), ".sig") || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		return pp.signFile(fp)
+	})
+}
+
+func (pp *standardProviderPackager) signFile(fp string) error {
+	sig, err := edcrypto.SignFileWithTimestamp(pp.privateKeyPath, pp.privateKeyFormat, fp, "now")
+	if err != nil {
+		return fmt.Errorf("error signing '%s': %s", fp, err.Error())
+	}
+	return edcrypto.WriteOutFile(sig, fmt.Sprintf("%s.sig", fp), defaultSignatureEncoding)
+}
+
+func (pp *standardProviderPackager) writeArchive(versionDir string, prov string, version string) error {
+	archiveDir := filepath.Join(pp.distRoot, prov)
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(archiveDir, fmt.Sprintf(".%s-*.tgz", version))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	err = compression.CompressFromPath(tmp, versionDir, version)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	archivePath := filepath.Join(archiveDir, fmt.Sprintf("%s.tgz", version))
+	if err := os.Rename(tmp.Name(), archivePath); err != nil {
+		return err
+	}
+	return pp.signFile(archivePath)
+}
+
+func (pp *standardProviderPackager) updateProvidersList(prov string, version string) error {
+	listPath := filepath.Join(pp.distRoot, remoteProviderListPath)
+	pl := NewProvidersList()
+	b, err := os.ReadFile(listPath)
+	if err == nil {
+		if err := yaml.Unmarshal(b, &pl); err != nil {
+			return err
+		}
+		if pl.Providers == nil {
+			pl.Providers = make(map[string]ProviderDescription)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	desc := pl.Providers[prov]
+	for _, v := range desc.Versions {
+		if v == version {
+			return nil
+		}
+	}
+	desc.Versions = sortVersions(append(desc.Versions, version))
+	pl.Providers[prov] = desc
+	out, err := yaml.Marshal(pl)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(listPath, out, 0644)
+}
+
+// sortVersions sorts semantic versions ascending,
+// with any unparseable versions lexically ordered at the front.
+func sortVersions(versions []string) []string {
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, errI := semver.NewVersion(versions[i])
+		vj, errJ := semver.NewVersion(versions[j])
+		switch {
+		case errI != nil && errJ != nil:
+			return versions[i] < versions[j]
+		case errI != nil:
+			return true
+		case errJ != nil:
+			return false
+		default:
+			return vi.LessThan(vj)
+		}
+	})
+	return versions
+}
@@ -0,0 +1,131 @@
+package openapistackql_test
+
+import (
+	"crypto/x509/pkix"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
+	"gopkg.in/yaml.v3"
+
+	"gotest.tools/assert"
+)
+
+func generateTestSigningArtifacts(t *testing.T, dir string) (string, string) {
+	keyPath := filepath.Join(dir, "key.pem")
+	certPath := filepath.Join(dir, "cert.pem")
+	err := edcrypto.GenerateTLSArtifacts(edcrypto.CertificateConfig{
+		Format:            "pem",
+		Hosts:             []string{"localhost"},
+		IsCa:              true,
+		IsEd25519Key:      true,
+		ValidFor:          2 * time.Hour,
+		ValidFrom:         time.Now().UTC().Add(-time.Hour).Format("Jan 2 15:04:05 2006"),
+		CertOutFile:       certPath,
+		CsrOutFile:        filepath.Join(dir, "csr.pem"),
+		PrivateKeyOutFile: keyPath,
+		PublicKeyOutFile:  filepath.Join(dir, "pub.pem"),
+		Name:              pkix.Name{CommonName: "localhost"},
+	})
+	assert.NilError(t, err)
+	return keyPath, certPath
+}
+
+func copyTree(t *testing.T, src, dst string) {
+	err := filepath.Walk(src, func(fp string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, fp)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		b, err := os.ReadFile(fp)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, b, 0644)
+	})
+	assert.NilError(t, err)
+}
+
+func TestPackageContrivedProvider(t *testing.T) {
+	keyDir := t.TempDir()
+	registryRoot := t.TempDir()
+	localDocRoot := t.TempDir()
+	keyPath, certPath := generateTestSigningArtifacts(t, keyDir)
+
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry", "src", "contrivedprovider", "v0.1.0"))
+	assert.NilError(t, err)
+	copyTree(t, srcRoot, filepath.Join(registryRoot, "src", "contrivedprovider", "v0.1.0"))
+
+	pp, err := NewProviderPackager(PackagerConfig{RegistryRoot: registryRoot, PrivateKeyPath: keyPath})
+	assert.NilError(t, err)
+	assert.NilError(t, pp.Package("contrivedprovider", "v0.1.0"))
+
+	b, err := os.ReadFile(filepath.Join(registryRoot, "dist", "providers.yaml"))
+	assert.NilError(t, err)
+	pl := NewProvidersList()
+	assert.NilError(t, yaml.Unmarshal(b, &pl))
+	assert.DeepEqual(t, pl.Providers["contrivedprovider"].Versions, []string{"v0.1.0"})
+
+	r, err := NewRegistry(
+		RegistryConfig{
+			RegistryURL:  fmt.Sprintf("file://%s", filepath.ToSlash(registryRoot)),
+			LocalDocRoot: localDocRoot,
+			VerfifyConfig: &edcrypto.VerifierConfig{
+				LocalCAFilePath:      certPath,
+				LocalSigningCertPath: certPath,
+				LocalCertRegexStr:    ".*",
+			},
+		},
+		nil,
+	)
+	assert.NilError(t, err)
+	failures, err := r.VerifyLocalDocs("contrivedprovider")
+	assert.NilError(t, err)
+	assert.Equal(t, len(failures), 0)
+
+	pr, err := r.LoadProviderByName("contrivedprovider", "v0.1.0")
+	assert.NilError(t, err)
+	assert.Equal(t, pr.GetName(), "contrivedprovider")
+
+	assert.NilError(t, r.PullAndPersistProviderArchive("contrivedprovider", "v0.1.0"))
+	_, err = os.Stat(filepath.Join(localDocRoot, "src", "contrivedprovider", "v0.1.0", "services", "contrived_service.yaml.sig"))
+	assert.NilError(t, err)
+
+	_, err = os.Stat(filepath.Join(registryRoot, "dist", "contrivedprovider", "v0.1.0.tgz.sig"))
+	assert.NilError(t, err)
+
+	archivePath := filepath.Join(registryRoot, "dist", "contrivedprovider", "v0.1.0.tgz")
+	arcFile, err := os.OpenFile(archivePath, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NilError(t, err)
+	_, err = arcFile.Write([]byte{0})
+	assert.NilError(t, err)
+	assert.NilError(t, arcFile.Close())
+	_, err = r.PullProviderArchive("contrivedprovider", "v0.1.0")
+	assert.ErrorContains(t, err, "failed verification")
+
+	tamperedPath := filepath.Join(registryRoot, "src", "contrivedprovider", "v0.1.0", "provider.yaml")
+	f, err := os.OpenFile(tamperedPath, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NilError(t, err)
+	_, err = f.WriteString("\n# tampered\n")
+	assert.NilError(t, err)
+	assert.NilError(t, f.Close())
+	failures, err = r.VerifyLocalDocs("contrivedprovider")
+	assert.NilError(t, err)
+	assert.Equal(t, len(failures), 1)
+	assert.Equal(t, failures[0].DocPath, "contrivedprovider/v0.1.0/provider.yaml")
+
+	t.Logf("TestPackageContrivedProvider passed")
+}
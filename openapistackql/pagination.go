@@ -27,10 +27,16 @@ type standardPagination struct {
 }
 
 func (qt *standardPagination) GetRequestToken() TokenSemantic {
+	if qt.RequestToken == nil {
+		return nil
+	}
 	return qt.RequestToken
 }
 
 func (qt *standardPagination) GetResponseToken() TokenSemantic {
+	if qt.ResponseToken == nil {
+		return nil
+	}
 	return qt.ResponseToken
 }
 
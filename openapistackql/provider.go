@@ -34,8 +34,10 @@ type Provider interface {
 	MarshalJSON() ([]byte, error)
 	UnmarshalJSON(data []byte) error
 	//
+	getLoaderOptions() (*LoaderOptions, bool)
 	getResourcesShallowWithRegistry(registry RegistryAPI, serviceKey string) (ResourceRegister, error)
 	isObjectSchemaImplicitlyUnioned() bool
+	setLoaderOptions(opts *LoaderOptions)
 }
 
 type standardProvider struct {
@@ -49,6 +51,7 @@ type standardProvider struct {
 	Description      string                              `json:"description,omitempty" yaml:"desription,omitempty"`
 	ProviderServices map[string]*standardProviderService `json:"providerServices,omitempty" yaml:"providerServices,omitempty"`
 	StackQLConfig    *standardStackQLConfig              `json:"config,omitempty" yaml:"config,omitempty"`
+	loaderOptions    *LoaderOptions
 }
 
 func (pr *standardProvider) GetAuth() (AuthDTO, bool) {
@@ -92,6 +95,14 @@ func (pr *standardProvider) GetRequestTranslateAlgorithm() string {
 	return pr.StackQLConfig.RequestTranslate.Algorithm
 }
 
+func (pr *standardProvider) getLoaderOptions() (*LoaderOptions, bool) {
+	return pr.loaderOptions, pr.loaderOptions != nil
+}
+
+func (pr *standardProvider) setLoaderOptions(opts *LoaderOptions) {
+	pr.loaderOptions = opts
+}
+
 func (pr *standardProvider) isObjectSchemaImplicitlyUnioned() bool {
 	if pr.StackQLConfig != nil {
 		return pr.StackQLConfig.isObjectSchemaImplicitlyUnioned()
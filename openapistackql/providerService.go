@@ -3,6 +3,7 @@ package openapistackql
 import (
 	"fmt"
 	"reflect"
+	"sync"
 
 	"github.com/getkin/kin-openapi/jsoninfo"
 	"github.com/getkin/kin-openapi/openapi3"
@@ -34,6 +35,8 @@ type ProviderService interface {
 	GetVersion() string
 	GetDescription() string
 	//
+	getOrLoadResources(key string, load func() (ResourceRegister, error)) (ResourceRegister, error)
+	getOrLoadService(key string, load func() (Service, error)) (Service, error)
 	getResourcesShallowWithRegistry(registry RegistryAPI) (ResourceRegister, error)
 	getServiceRefRef() string
 	getResourcesRefRef() string
@@ -56,6 +59,23 @@ type standardProviderService struct {
 	Provider      Provider               `json:"-" yaml:"-"`                                     // upwards traversal
 	StackQLConfig *standardStackQLConfig `json:"config,omitempty" yaml:"config,omitempty"`
 	Service       Service                `json:"-" yaml:"-"`
+	// guards lazily loaded state, which may be resolved concurrently
+	mutex   sync.Mutex
+	flights flightGroup
+	loaded  map[string]interface{}
+}
+
+// Lazily loaded docs are cached per provider service under keys of the form '<kind>:<doc url>'.
+func serviceLoadKey(url string) string {
+	return "service:" + url
+}
+
+func fragmentLoadKey(url string) string {
+	return "fragment:" + url
+}
+
+func resourcesLoadKey(url string) string {
+	return "resources:" + url
 }
 
 func NewEmptyProviderService() ProviderService {
@@ -81,6 +101,8 @@ func (sv *standardProviderService) IsPreferred() bool {
 func (sv *standardProviderService) SetServiceRefVal(svc Service) bool {
 	switch svc := svc.(type) {
 	case *standardService:
+		sv.mutex.Lock()
+		defer sv.mutex.Unlock()
 		sv.ServiceRef.Value = svc
 		return true
 	default:
@@ -97,9 +119,94 @@ func (sv *standardProviderService) GetID() string {
 }
 
 func (sv *standardProviderService) setService(svc Service) {
+	sv.mutex.Lock()
+	defer sv.mutex.Unlock()
 	sv.Service = svc
 }
 
+func (sv *standardProviderService) peekService() (Service, bool) {
+	sv.mutex.Lock()
+	defer sv.mutex.Unlock()
+	if sv.Service != nil {
+		return sv.Service, true
+	}
+	if sv.ServiceRef != nil && sv.ServiceRef.Value != nil {
+		return sv.ServiceRef.Value, true
+	}
+	return nil, false
+}
+
+func (sv *standardProviderService) peekServiceRefVal() (Service, bool) {
+	sv.mutex.Lock()
+	defer sv.mutex.Unlock()
+	if sv.ServiceRef != nil && sv.ServiceRef.Value != nil {
+		return sv.ServiceRef.Value, true
+	}
+	return nil, false
+}
+
+func (sv *standardProviderService) peekResourcesRefVal() (ResourceRegister, bool) {
+	sv.mutex.Lock()
+	defer sv.mutex.Unlock()
+	if sv.ResourcesRef != nil && sv.ResourcesRef.Value != nil {
+		return sv.ResourcesRef.Value, true
+	}
+	return nil, false
+}
+
+// loadOnce returns the value cached under key, else loads and caches it;
+// concurrent callers for the same key share a single load.
+// Failed loads are not cached.
+func (sv *standardProviderService) loadOnce(key string, load func() (interface{}, error)) (interface{}, error) {
+	if v, ok := sv.getLoaded(key); ok {
+		return v, nil
+	}
+	return sv.flights.do(key, func() (interface{}, error) {
+		// a load may have completed since the cache was checked
+		if v, ok := sv.getLoaded(key); ok {
+			return v, nil
+		}
+		v, err := load()
+		if err != nil {
+			return nil, err
+		}
+		sv.mutex.Lock()
+		defer sv.mutex.Unlock()
+		if sv.loaded == nil {
+			sv.loaded = make(map[string]interface{})
+		}
+		sv.loaded[key] = v
+		return v, nil
+	})
+}
+
+func (sv *standardProviderService) getLoaded(key string) (interface{}, bool) {
+	sv.mutex.Lock()
+	defer sv.mutex.Unlock()
+	v, ok := sv.loaded[key]
+	return v, ok
+}
+
+func (sv *standardProviderService) getOrLoadService(key string, load func() (Service, error)) (Service, error) {
+	v, err := sv.loadOnce(key, func() (interface{}, error) {
+		return load()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(Service), nil
+}
+
+func (sv *standardProviderService) getOrLoadResources(key string, load func() (ResourceRegister, error)) (ResourceRegister, error) {
+	v, err := sv.loadOnce(key, func() (interface{}, error) {
+		return load()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(ResourceRegister), nil
+}
+
 func (sv *standardProviderService) getServiceRefRef() string {
 	if sv.ServiceRef == nil {
 		return ""
@@ -159,7 +266,7 @@ func (sv *standardProviderService) ConditionIsValid(lhs string, rhs interface{})
 }
 
 func extractService(ps ProviderService) (Service, error) {
-	b, err := getServiceDocBytes(ps.getServiceRefRef())
+	b, err := getProviderServiceDocBytes(ps, ps.getServiceRefRef())
 	if err != nil {
 		return nil, err
 	}
@@ -167,7 +274,7 @@ func extractService(ps ProviderService) (Service, error) {
 }
 
 func getResourcesShallow(ps ProviderService) (ResourceRegister, error) {
-	b, err := getServiceDocBytes(ps.getResourcesRefRef())
+	b, err := getProviderServiceDocBytes(ps, ps.getResourcesRefRef())
 	if err != nil {
 		return nil, err
 	}
@@ -214,52 +321,44 @@ func (ps *standardProviderService) GetKey(lhs string) (interface{}, error) {
 }
 
 func (ps *standardProviderService) getServiceWithRegistry(registry RegistryAPI) (Service, error) {
-	if ps.ServiceRef.Value != nil {
-		return ps.ServiceRef.Value, nil
+	if svc, ok := ps.peekServiceRefVal(); ok {
+		return svc, nil
 	}
 	if registry != nil {
 		return registry.GetService(ps)
 	}
-	svc, err := extractService(ps)
-	if err != nil {
-		return nil, err
-	}
-	ps.Service = svc
-	return ps.Service, nil
+	return ps.loadService()
 }
 
 func (ps *standardProviderService) GetService() (Service, error) {
-	if ps.Service != nil {
-		return ps.Service, nil
-	}
-	if ps.ServiceRef.Value != nil {
-		return ps.ServiceRef.Value, nil
+	if svc, ok := ps.peekService(); ok {
+		return svc, nil
 	}
-	svc, err := extractService(ps)
-	if err != nil {
-		return nil, err
-	}
-	ps.Service = svc
-	return ps.Service, nil
+	return ps.loadService()
 }
 
 func (ps *standardProviderService) extractService() (Service, error) {
-	if ps.ServiceRef.Value != nil {
-		return ps.ServiceRef.Value, nil
-	}
-	svc, err := extractService(ps)
+	return ps.getServiceWithRegistry(nil)
+}
+
+func (ps *standardProviderService) loadService() (Service, error) {
+	svc, err := ps.getOrLoadService(serviceLoadKey(ps.getServiceRefRef()), func() (Service, error) {
+		return extractService(ps)
+	})
 	if err != nil {
 		return nil, err
 	}
-	ps.Service = svc
-	return ps.Service, nil
+	ps.setService(svc)
+	return svc, nil
 }
 
 func (ps *standardProviderService) getServiceDocRef(rr ResourceRegister, rsc Resource) ServiceRef {
 	var rv ServiceRef
+	ps.mutex.Lock()
 	if ps.ServiceRef != nil && ps.ServiceRef.Ref != "" {
 		rv = *ps.ServiceRef
 	}
+	ps.mutex.Unlock()
 	if rr.GetServiceDocPath() != nil && rr.GetServiceDocPath().Ref != "" {
 		rv = *(rr.GetServiceDocPath())
 	}
@@ -289,19 +388,23 @@ func (ps *standardProviderService) GetServiceFragment(resourceKey string) (Servi
 	if sdRef.Value != nil {
 		return sdRef.Value, nil
 	}
-	sb, err := getServiceDocBytes(sdRef.Ref)
-	if err != nil {
-		return nil, err
-	}
-	svc, err := LoadServiceSubsetDocFromBytes(rr, resourceKey, sb)
+	svc, err := ps.getOrLoadService(fragmentLoadKey(sdRef.Ref), func() (Service, error) {
+		sb, err := getProviderServiceDocBytes(ps, sdRef.Ref)
+		if err != nil {
+			return nil, err
+		}
+		return newProviderServiceLoader(ps).LoadFromBytesAndResources(rr, resourceKey, sb)
+	})
 	if err != nil {
 		return nil, err
 	}
-	ps.Service = svc
-	return ps.Service, nil
+	ps.setService(svc)
+	return svc, nil
 }
 
 func (ps *standardProviderService) PeekServiceFragment(resourceKey string) (Service, bool) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
 	if ps.ServiceRef == nil || ps.ServiceRef.Value == nil || ps.ServiceRef.Value.rsc == nil {
 		return nil, false
 	}
@@ -335,13 +438,13 @@ func (ps *standardProviderService) getResourcesShallowWithRegistry(registry Regi
 		}
 		return nil, fmt.Errorf("cannot resolve shallow resources")
 	}
-	if ps.ResourcesRef.Value != nil {
-		return ps.ResourcesRef.Value, nil
+	if rr, ok := ps.peekResourcesRefVal(); ok {
+		return rr, nil
 	}
 	if registry != nil {
 		return registry.GetResourcesShallowFromURL(ps)
 	}
-	return getResourcesShallow(ps)
+	return ps.loadResourcesShallow()
 }
 
 func (ps *standardProviderService) GetResourcesShallow() (ResourceRegister, error) {
@@ -367,10 +470,16 @@ func (ps *standardProviderService) GetResourcesShallow() (ResourceRegister, erro
 		}
 		return nil, fmt.Errorf("cannot resolve shallow resources")
 	}
-	if ps.ResourcesRef.Value != nil {
-		return ps.ResourcesRef.Value, nil
+	if rr, ok := ps.peekResourcesRefVal(); ok {
+		return rr, nil
 	}
-	return getResourcesShallow(ps)
+	return ps.loadResourcesShallow()
+}
+
+func (ps *standardProviderService) loadResourcesShallow() (ResourceRegister, error) {
+	return ps.getOrLoadResources(resourcesLoadKey(ps.getResourcesRefRef()), func() (ResourceRegister, error) {
+		return getResourcesShallow(ps)
+	})
 }
 
 func (ps *standardProviderService) GetName() string {
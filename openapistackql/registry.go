@@ -4,6 +4,7 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stackql/go-openapistackql/pkg/compression"
 	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
 	"gopkg.in/yaml.v3"
@@ -39,8 +41,8 @@ type RegistryAPI interface {
 	ListAllProviderVersions(string) (map[string]ProviderDescription, error)
 	ListLocallyAvailableProviders() map[string]ProviderDescription
 	GetDocBytes(string) ([]byte, error)
-	GetLatestAvailableVersion(string) (string, error)
-	GetLatestPublishedVersion(string) (string, error)
+	GetLatestAvailableVersion(string, ...string) (string, error)
+	GetLatestPublishedVersion(string, ...string) (string, error)
 	GetResourcesShallowFromProvider(Provider, string) (ResourceRegister, error)
 	GetResourcesShallowFromProviderService(ProviderService) (ResourceRegister, error)
 	GetResourcesShallowFromURL(ps ProviderService) (ResourceRegister, error)
@@ -49,7 +51,11 @@ type RegistryAPI interface {
 	GetServiceFromProviderService(ProviderService) (Service, error)
 	GetServiceDocBytes(string) ([]byte, error)
 	GetResourcesRegisterDocBytes(string) ([]byte, error)
+	GetLockfile() (Lockfile, bool)
+	GetDocPatches() (*DocPatches, bool)
 	LoadProviderByName(string, string) (Provider, error)
+	ResolveProviderVersion(string, string) (string, error)
+	VerifyLocalDocs(string) ([]DocVerificationFailure, error)
 }
 
 type RegistryConfig struct {
@@ -59,6 +65,12 @@ type RegistryConfig struct {
 	AllowSrcDownload bool                     `json:"allowSrcDownload" yaml:"allowSrcDownload"`
 	LocalDocRoot     string                   `json:"localDocRoot" yaml:"localDocRoot"`
 	VerfifyConfig    *edcrypto.VerifierConfig `json:"verifyConfig" yaml:"verifyConfig"`
+	// LockfilePath, when set, pins loads to the versions and doc digests recorded therein.
+	LockfilePath string `json:"lockfile" yaml:"lockfile"`
+	// LockfileUpdate permits loading provider versions other than those locked, replacing their records.
+	LockfileUpdate bool `json:"lockfileUpdate" yaml:"lockfileUpdate"`
+	// PatchesPath, when set, names a local file of overlays and merge patches applied to docs as they are loaded.
+	PatchesPath string `json:"patches" yaml:"patches"`
 }
 
 type Registry struct {
@@ -72,12 +84,51 @@ type Registry struct {
 	transport        http.RoundTripper
 	verifier         *edcrypto.Verifier
 	nopVerifier      bool
+	lock             *docLock
+	patches          *DocPatches
+	srcFS            fs.FS
+	distFS           fs.FS
 }
 
 func NewRegistry(registryCfg RegistryConfig, transport http.RoundTripper) (RegistryAPI, error) {
 	return newRegistry(registryCfg, transport)
 }
 
+// NewFSRegistry serves provider docs and archives from fsys, eg: an embed.FS,
+// fstest.MapFS or zip.Reader, laid out as a registry root,
+// ie: '<srcPrefix>/<provider>/<version>/...' docs with detached '.sig' files
+// and '<distPrefix>/<provider>/<version>.tgz' archives.
+// The URL in registryCfg is ignored; prefixes, verification and lockfile settings apply as for NewRegistry.
+func NewFSRegistry(fsys fs.FS, registryCfg RegistryConfig) (RegistryAPI, error) {
+	registryCfg.RegistryURL = "fs:"
+	rv, err := newRegistry(registryCfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	r := rv.(*Registry)
+	r.srcFS, err = subFS(fsys, r.localSrcPrefix)
+	if err != nil {
+		return nil, err
+	}
+	r.distFS, err = subFS(fsys, r.localDistPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func subFS(fsys fs.FS, prefix string) (fs.FS, error) {
+	if prefix == "" {
+		return fsys, nil
+	}
+	return fs.Sub(fsys, prefix)
+}
+
+// fsPath renders a doc path valid for fs.FS access, ie: unrooted and without '..' elements.
+func fsPath(docPath string) string {
+	return strings.TrimPrefix(path.Clean("/"+docPath), "/")
+}
+
 func newRegistry(registryCfg RegistryConfig, transport http.RoundTripper) (RegistryAPI, error) {
 	registryUrl := registryCfg.RegistryURL
 	if registryUrl == "" {
@@ -126,6 +177,20 @@ func newRegistry(registryCfg RegistryConfig, transport http.RoundTripper) (Regis
 	if err != nil {
 		return nil, err
 	}
+	var lock *docLock
+	if registryCfg.LockfilePath != "" {
+		lock, err = newDocLock(registryCfg.LockfilePath, registryCfg.LockfileUpdate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var patches *DocPatches
+	if registryCfg.PatchesPath != "" {
+		patches, err = ReadDocPatches(registryCfg.PatchesPath)
+		if err != nil {
+			return nil, err
+		}
+	}
 	rv := &Registry{
 		allowSrcDownload: registryCfg.AllowSrcDownload,
 		regUrl:           regUrl,
@@ -137,6 +202,12 @@ func newRegistry(registryCfg RegistryConfig, transport http.RoundTripper) (Regis
 		transport:        transport,
 		verifier:         ver,
 		nopVerifier:      nopVerify,
+		lock:             lock,
+		patches:          patches,
+	}
+	if rv.isLocalFile() {
+		rv.srcFS = os.DirFS(srcUrl.Path)
+		rv.distFS = os.DirFS(distUrl.Path)
 	}
 	return rv, nil
 }
@@ -169,6 +240,10 @@ func (pl ProvidersList) GetLatestList() (ProvidersList, error) {
 	m := make(map[string]ProviderDescription)
 	for k, v := range pl.Providers {
 		latest, err := semver.FindLatestStable(v.Versions)
+		if err != nil {
+			// providers published only as prereleases are still listed
+			latest, err = semver.FindLatest(v.Versions)
+		}
 		if err != nil {
 			return NewProvidersList(), err
 		}
@@ -188,19 +263,7 @@ func (pl ProvidersList) GetSingleProviderList(prov string) ProvidersList {
 }
 
 func (r *Registry) ListAllAvailableProviders() (map[string]ProviderDescription, error) {
-	if r.isFile() {
-		return nil, fmt.Errorf("'registry list' is meaningless in local mode")
-	}
-	regProvs := NewProvidersList()
-	rc, err := r.getRemoteProviderList()
-	if err != nil {
-		return nil, err
-	}
-	b, err := io.ReadAll(rc)
-	if err != nil {
-		return nil, err
-	}
-	err = yaml.Unmarshal(b, regProvs)
+	regProvs, err := r.getProvidersList()
 	if err != nil {
 		return nil, err
 	}
@@ -216,24 +279,50 @@ func (r *Registry) ListAllProviderVersions(prov string) (map[string]ProviderDesc
 }
 
 func (r *Registry) listAllProviderVersions(prov string) (map[string]ProviderDescription, error) {
-	if r.isFile() {
-		return nil, fmt.Errorf("'registry list' is meaningless in local mode")
+	regProvs, err := r.getProvidersList()
+	if err != nil {
+		return nil, err
 	}
+	singleProvList := regProvs.GetSingleProviderList(prov)
+	return singleProvList.Providers, nil
+}
+
+// getProvidersList reads the published providers list or,
+// for local file registries, the provider directories present under the source root.
+func (r *Registry) getProvidersList() (ProvidersList, error) {
 	regProvs := NewProvidersList()
+	if r.isFS() {
+		entries, err := fs.ReadDir(r.srcFS, ".")
+		if err != nil {
+			return regProvs, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			versions, err := r.listAvailableVersions(e.Name())
+			if err != nil {
+				return regProvs, err
+			}
+			if len(versions) > 0 {
+				regProvs.Providers[e.Name()] = ProviderDescription{Versions: versions}
+			}
+		}
+		return regProvs, nil
+	}
+	if r.isFile() {
+		return regProvs, fmt.Errorf("'registry list' is meaningless in local mode")
+	}
 	rc, err := r.getRemoteProviderList()
 	if err != nil {
-		return nil, err
+		return regProvs, err
 	}
 	b, err := io.ReadAll(rc)
 	if err != nil {
-		return nil, err
+		return regProvs, err
 	}
-	err = yaml.Unmarshal(b, regProvs)
-	if err != nil {
-		return nil, err
-	}
-	singleProvList := regProvs.GetSingleProviderList(prov)
-	return singleProvList.Providers, nil
+	err = yaml.Unmarshal(b, &regProvs)
+	return regProvs, err
 }
 
 func (r *Registry) isHttp() bool {
@@ -244,6 +333,11 @@ func (r *Registry) isFile() bool {
 	return fileSchemeRegexp.MatchString(r.regUrl.Scheme)
 }
 
+// isFS is true for registries read through an fs.FS, which includes local file registries.
+func (r *Registry) isFS() bool {
+	return r.srcFS != nil
+}
+
 func (r *Registry) isLocalFile() bool {
 	return r.isFile() && strings.HasPrefix(r.regUrl.Path, "/")
 }
@@ -253,22 +347,23 @@ func (r *Registry) GetDocBytes(docPath string) ([]byte, error) {
 }
 
 func (r *Registry) getProviderDocBytes(prov string, version string) ([]byte, error) {
-	switch prov {
-	case "google":
-		prov = "googleapis.com"
-	}
+	prov = normaliseProviderName(prov)
 	return r.getVerifiedDocBytes(path.Join(prov, version, "provider.yaml"))
 }
 
+func normaliseProviderName(prov string) string {
+	if prov == "google" {
+		return "googleapis.com"
+	}
+	return prov
+}
+
 func (r *Registry) PullProviderArchive(prov string, version string) (io.ReadCloser, error) {
 	return r.pullProviderArchive(prov, version)
 }
 
 func (r *Registry) pullProviderArchive(prov string, version string) (io.ReadCloser, error) {
-	switch prov {
-	case "google":
-		prov = "googleapis.com"
-	}
+	prov = normaliseProviderName(prov)
 	fp := path.Join(prov, fmt.Sprintf("%s.tgz", version))
 	return r.pullArchive(fp)
 }
@@ -285,19 +380,18 @@ func (r *Registry) pullAndPersistProviderArchive(prov string, version string) er
 	if err != nil {
 		return err
 	}
-	pr := prov
-	if pr == "google" {
-		pr = "googleapis.com"
-	}
-	err = os.RemoveAll(path.Join(r.getLocalDocRoot(), pr, version))
-	if err != nil {
-		return err
-	}
-	return compression.DecompressToPath(rdr, path.Join(r.getLocalDocRoot(), pr))
+	defer rdr.Close()
+	// extraction replaces any existing version directory only once the whole archive is written
+	return compression.DecompressToPath(rdr, path.Join(r.getLocalDocRoot(), normaliseProviderName(prov)))
 }
 
+// LoadProviderByName accepts either an exact version or a constraint, eg: '^0.1'.
 func (r *Registry) LoadProviderByName(prov string, version string) (Provider, error) {
-	b, err := r.getProviderDocBytes(prov, version)
+	resolved, err := r.resolveProviderVersion(prov, version)
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.getProviderDocBytes(prov, resolved)
 	if err != nil {
 		return nil, err
 	}
@@ -314,11 +408,13 @@ func (r *Registry) GetResourcesRegisterDocBytes(url string) ([]byte, error) {
 
 func (r *Registry) GetService(ps ProviderService) (Service, error) {
 	url := ps.getServiceRefRef()
-	b, err := r.getVerifiedDocBytes(url)
-	if err != nil {
-		return nil, err
-	}
-	return LoadServiceDocFromBytes(ps, b)
+	return ps.getOrLoadService(serviceLoadKey(url), func() (Service, error) {
+		b, err := r.getVerifiedDocBytes(url)
+		if err != nil {
+			return nil, err
+		}
+		return LoadServiceDocFromBytes(ps, b)
+	})
 }
 func (r *Registry) GetResourcesShallowFromProvider(pr Provider, serviceKey string) (ResourceRegister, error) {
 	return pr.getResourcesShallowWithRegistry(r, serviceKey)
@@ -330,11 +426,13 @@ func (r *Registry) GetResourcesShallowFromProviderService(pr ProviderService) (R
 
 func (r *Registry) GetResourcesShallowFromURL(ps ProviderService) (ResourceRegister, error) {
 	url := ps.getResourcesRefRef()
-	b, err := r.getVerifiedDocBytes(url)
-	if err != nil {
-		return nil, err
-	}
-	return loadResourcesShallow(ps, b)
+	return ps.getOrLoadResources(resourcesLoadKey(url), func() (ResourceRegister, error) {
+		b, err := r.getVerifiedDocBytes(url)
+		if err != nil {
+			return nil, err
+		}
+		return loadResourcesShallow(ps, b)
+	})
 }
 
 func (r *Registry) GetServiceFromProviderService(ps ProviderService) (Service, error) {
@@ -367,16 +465,137 @@ func (r *Registry) GetServiceFragment(ps ProviderService, resourceKey string) (S
 	if sdRef.Value != nil {
 		return sdRef.Value, nil
 	}
-	sb, err := r.getVerifiedDocBytes(sdRef.Ref)
+	svc, err := ps.getOrLoadService(fragmentLoadKey(sdRef.Ref), func() (Service, error) {
+		sb, err := r.getVerifiedDocBytes(sdRef.Ref)
+		if err != nil {
+			return nil, err
+		}
+		return LoadServiceSubsetDocFromBytes(rr, resourceKey, sb)
+	})
 	if err != nil {
 		return nil, err
 	}
-	svc, err := LoadServiceSubsetDocFromBytes(rr, resourceKey, sb)
+	ps.setService(svc)
+	return svc, nil
+}
+
+type DocVerificationFailure struct {
+	DocPath string
+	Err     error
+}
+
+func (df DocVerificationFailure) Error() string {
+	return fmt.Sprintf("%s: %s", df.DocPath, df.Err.Error())
+}
+
+// VerifyLocalDocs checks every locally held doc, optionally restricted to a single provider,
+// and returns those which fail signature verification.
+func (r *Registry) VerifyLocalDocs(prov string) ([]DocVerificationFailure, error) {
+	return r.verifyLocalDocs(prov)
+}
+
+// getLocalVerifiableDocs returns the docs held by the registry itself
+// where it is read through an fs.FS, else those under the local doc root.
+func (r *Registry) getLocalVerifiableDocs() (fs.FS, error) {
+	if r.isFS() {
+		return r.srcFS, nil
+	}
+	docRoot := r.getLocalDocRoot()
+	if docRoot == "" {
+		return nil, fmt.Errorf("cannot verify docs without local doc location")
+	}
+	return os.DirFS(docRoot), nil
+}
+
+func (r *Registry) verifyLocalDocs(prov string) ([]DocVerificationFailure, error) {
+	prov = normaliseProviderName(prov)
+	docs, err := r.getLocalVerifiableDocs()
 	if err != nil {
 		return nil, err
 	}
-	ps.setService(svc)
-	return svc, nil
+	walkRoot := fsPath(prov)
+	var rv []DocVerificationFailure
+	err = fs.WalkDir(docs, walkRoot, func(docPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && docPath != walkRoot {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || strings.HasSuffix(d.Name(), ".sig") {
+			return nil
+		}
+		if verifyErr := r.verifyLocalDoc(docs, docPath); verifyErr != nil {
+			rv = append(rv, DocVerificationFailure{DocPath: docPath, Err: verifyErr})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (r *Registry) verifyLocalDoc(docs fs.FS, docPath string) error {
+	if r.nopVerifier {
+		return nil
+	}
+	lf, err := docs.Open(docPath)
+	if err != nil {
+		return err
+	}
+	sf, err := docs.Open(fmt.Sprintf("%s.sig", docPath))
+	if err != nil {
+		lf.Close()
+		return fmt.Errorf("signature file not present")
+	}
+	vr, err := r.checkSignature(docPath, lf, sf)
+	if err != nil {
+		return err
+	}
+	if vr.VerifyFile != nil {
+		vr.VerifyFile.Close()
+	}
+	if vr.SignatureFile != nil {
+		vr.SignatureFile.Close()
+	}
+	if !vr.IsVerified {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// LoadProviderServiceResources returns fully resolved resources for a provider service,
+// whether the service is described by a single doc or split across a resources register.
+func LoadProviderServiceResources(registry RegistryAPI, ps ProviderService) (map[string]Resource, error) {
+	if ps.GetResourcesRefRef() == "" {
+		svc, err := registry.GetServiceFromProviderService(ps)
+		if err != nil {
+			return nil, err
+		}
+		ms := MetadataStore{Store: map[string]Service{ps.GetName(): svc}}
+		return ms.GetResources(ps.GetName())
+	}
+	rr, err := registry.GetResourcesShallowFromProviderService(ps)
+	if err != nil {
+		return nil, err
+	}
+	rv := make(map[string]Resource)
+	for k := range rr.GetResources() {
+		svc, err := registry.GetServiceFragment(ps, k)
+		if err != nil {
+			return nil, err
+		}
+		rsc, err := svc.GetResource(k)
+		if err != nil {
+			return nil, err
+		}
+		rv[k] = rsc
+	}
+	return rv, nil
 }
 
 func (r *Registry) checkSignature(docUrl string, verFile, sigFile io.ReadCloser) (*edcrypto.VerifierResponse, error) {
@@ -388,8 +607,29 @@ func (r *Registry) checkSignature(docUrl string, verFile, sigFile io.ReadCloser)
 	return &vr, err
 }
 
+// pullArchive verifies the archive against its detached signature,
+// ie: '<version>.tgz.sig' alongside '<version>.tgz', unless verification is disabled.
 func (r *Registry) pullArchive(archivePath string) (io.ReadCloser, error) {
-	return r.getUnVerifiedArchive(archivePath)
+	arc, err := r.getUnVerifiedArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if r.nopVerifier {
+		return arc, nil
+	}
+	sig, err := r.getUnVerifiedArchive(fmt.Sprintf("%s.sig", archivePath))
+	if err != nil {
+		arc.Close()
+		return nil, fmt.Errorf("archive '%s' present but signature file not present", archivePath)
+	}
+	vr, err := r.checkSignature(archivePath, arc, sig)
+	if err != nil {
+		return nil, fmt.Errorf("archive '%s' failed verification: %s", archivePath, err.Error())
+	}
+	if !vr.IsVerified {
+		return nil, fmt.Errorf("archive '%s' failed verification", archivePath)
+	}
+	return vr.VerifyFile, nil
 }
 
 func (r *Registry) getRemoteDoc(docPath string) (io.ReadCloser, error) {
@@ -401,10 +641,7 @@ func (r *Registry) getRemoteDoc(docPath string) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
-	if response.Body == nil {
-		return nil, fmt.Errorf("no response body from remote")
-	}
-	return response.Body, nil
+	return getRemoteResponseBody(response)
 }
 
 func (r *Registry) getRemoteArchive(docPath string) (io.ReadCloser, error) {
@@ -416,10 +653,7 @@ func (r *Registry) getRemoteArchive(docPath string) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
-	if response.Body == nil {
-		return nil, fmt.Errorf("no response body from remote")
-	}
-	return response.Body, nil
+	return getRemoteResponseBody(response)
 }
 
 func (r *Registry) getRemoteProviderList() (io.ReadCloser, error) {
@@ -432,9 +666,17 @@ func (r *Registry) getRemoteProviderList() (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
+	return getRemoteResponseBody(response)
+}
+
+func getRemoteResponseBody(response *http.Response) (io.ReadCloser, error) {
 	if response.Body == nil {
 		return nil, fmt.Errorf("no response body from remote")
 	}
+	if response.StatusCode >= http.StatusMultipleChoices {
+		response.Body.Close()
+		return nil, fmt.Errorf("unexpected response status from remote: '%d'", response.StatusCode)
+	}
 	return response.Body, nil
 }
 
@@ -523,8 +765,8 @@ func (r *Registry) getLocalDoc(docPath string) (io.ReadCloser, error) {
 }
 
 func (r *Registry) getUnVerifiedArchive(docPath string) (io.ReadCloser, error) {
-	if r.isLocalFile() {
-		return os.Open(path.Join(r.distUrl.Path, docPath))
+	if r.isFS() {
+		return r.distFS.Open(fsPath(docPath))
 	}
 	if r.localDocRoot != "" {
 		localPath := r.getLocalArchivePath(docPath)
@@ -558,8 +800,8 @@ func (r *Registry) getEmbeddedVerifiedDocResponse(docPath string) (*edcrypto.Ver
 
 func (r *Registry) getVerifiedDocResponse(docPath string) (*edcrypto.VerifierResponse, error) {
 	var embeddedErr error
-	if r.isLocalFile() {
-		rb, err := os.Open(path.Join(r.srcUrl.Path, docPath))
+	if r.isFS() {
+		rb, err := r.srcFS.Open(fsPath(docPath))
 		if err != nil {
 			return nil, fmt.Errorf("cannot read local registry file: '%s'", err.Error())
 		}
@@ -567,8 +809,9 @@ func (r *Registry) getVerifiedDocResponse(docPath string) (*edcrypto.VerifierRes
 			rv := edcrypto.NewVerifierResponse(true, nil, rb, nil)
 			return &rv, nil
 		}
-		sb, err := os.Open(path.Join(r.srcUrl.Path, fmt.Sprintf("%s.sig", docPath)))
+		sb, err := r.srcFS.Open(fsPath(fmt.Sprintf("%s.sig", docPath)))
 		if err != nil {
+			rb.Close()
 			return nil, fmt.Errorf("cannot read local signature file: '%s'", err.Error())
 		}
 		return r.checkSignature(docPath, rb, sb)
@@ -634,50 +877,73 @@ func (r *Registry) getVerifiedDocBytes(docPath string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return io.ReadAll(vr.VerifyFile)
+	b, err := io.ReadAll(vr.VerifyFile)
+	if err != nil {
+		return nil, err
+	}
+	if r.lock != nil {
+		if err := r.lock.checkAndRecord(docPath, b); err != nil {
+			return nil, err
+		}
+	}
+	return r.patches.apply(docPath, b, logrus.StandardLogger())
 }
 
-func (r *Registry) GetLatestAvailableVersion(providerName string) (string, error) {
-	return r.getLatestAvailableVersion(providerName)
+// GetLockfile returns a snapshot of the lockfile, if one is configured.
+func (r *Registry) GetLockfile() (Lockfile, bool) {
+	if r.lock == nil {
+		return Lockfile{}, false
+	}
+	return r.lock.getLockfile(), true
 }
 
-func (r *Registry) getLatestAvailableVersion(providerName string) (string, error) {
-	switch providerName {
-	case "google":
-		providerName = "googleapis.com"
-	}
-	if r.isLocalFile() {
-		deSlice, err := os.ReadDir(path.Join(r.srcUrl.Path, providerName))
-		if err != nil {
-			return "", err
-		}
-		var deStrSlice []string
-		for _, e := range deSlice {
-			if e.IsDir() {
-				deStrSlice = append(deStrSlice, e.Name())
-			}
-		}
-		return semver.FindLatestStable(deStrSlice)
-	}
+// GetDocPatches returns the patches applied to docs, if any are configured.
+func (r *Registry) GetDocPatches() (*DocPatches, bool) {
+	return r.patches, r.patches != nil
+}
 
-	deSlice, err := os.ReadDir(path.Join(r.getLocalDocRoot(), providerName))
+// GetLatestAvailableVersion returns the greatest stable version held locally;
+// any constraints supplied, eg: '~0.1.2', are conjoined and restrict the candidates.
+func (r *Registry) GetLatestAvailableVersion(providerName string, constraints ...string) (string, error) {
+	return r.getLatestAvailableVersion(providerName, strings.Join(constraints, " "))
+}
+
+func (r *Registry) getLatestAvailableVersion(providerName string, constraint string) (string, error) {
+	versions, err := r.listAvailableVersions(providerName)
 	if err != nil {
 		return "", err
 	}
+	return semver.FindLatestMatching(versions, constraint)
+}
+
+func (r *Registry) listAvailableVersions(providerName string) ([]string, error) {
+	providerName = normaliseProviderName(providerName)
+	var deSlice []fs.DirEntry
+	var err error
+	if r.isFS() {
+		deSlice, err = fs.ReadDir(r.srcFS, fsPath(providerName))
+	} else {
+		deSlice, err = os.ReadDir(path.Join(r.getLocalDocRoot(), providerName))
+	}
+	if err != nil {
+		return nil, err
+	}
 	var deStrSlice []string
 	for _, e := range deSlice {
-		if e.IsDir() {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
 			deStrSlice = append(deStrSlice, e.Name())
 		}
 	}
-	return semver.FindLatestStable(deStrSlice)
+	return deStrSlice, nil
 }
 
-func (r *Registry) GetLatestPublishedVersion(providerName string) (string, error) {
-	return r.getLatestPublishedVersion(providerName)
+// GetLatestPublishedVersion returns the greatest stable version in the remote providers list;
+// any constraints supplied are conjoined and restrict the candidates.
+func (r *Registry) GetLatestPublishedVersion(providerName string, constraints ...string) (string, error) {
+	return r.getLatestPublishedVersion(providerName, strings.Join(constraints, " "))
 }
 
-func (r *Registry) getLatestPublishedVersion(providerName string) (string, error) {
+func (r *Registry) getLatestPublishedVersion(providerName string, constraint string) (string, error) {
 	p, err := r.listAllProviderVersions(providerName)
 	if err != nil {
 		return "", err
@@ -686,9 +952,44 @@ func (r *Registry) getLatestPublishedVersion(providerName string) (string, error
 	if !ok {
 		return "", fmt.Errorf("could not resolve provider '%s'", providerName)
 	}
-	latestVersion, err := semver.FindLatest(description.Versions)
+	latestVersion, err := semver.FindLatestMatching(description.Versions, constraint)
 	if err != nil {
 		return "", err
 	}
 	return latestVersion, nil
 }
+
+// ResolveProviderVersion returns exact versions unaltered.  Constraints are resolved to any
+// satisfying version recorded in the lockfile, else against locally available versions
+// and then, for remote registries, published versions.  Versions other than
+// that locked are refused, unless the lockfile is being updated.
+func (r *Registry) ResolveProviderVersion(providerName string, version string) (string, error) {
+	return r.resolveProviderVersion(providerName, version)
+}
+
+func (r *Registry) resolveProviderVersion(providerName string, version string) (string, error) {
+	resolved, err := r.resolveUnlockedProviderVersion(providerName, version)
+	if err != nil || r.lock == nil {
+		return resolved, err
+	}
+	if err := r.lock.checkVersion(providerName, resolved); err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
+func (r *Registry) resolveUnlockedProviderVersion(providerName string, version string) (string, error) {
+	if !semver.IsConstraint(version) {
+		return version, nil
+	}
+	if r.lock != nil {
+		if locked, ok := r.lock.getLockedVersion(providerName); ok && semver.Satisfies(locked, version) {
+			return locked, nil
+		}
+	}
+	resolved, err := r.getLatestAvailableVersion(providerName, version)
+	if err == nil || !r.isHttp() {
+		return resolved, err
+	}
+	return r.getLatestPublishedVersion(providerName, version)
+}
@@ -0,0 +1,382 @@
+package openapistackql
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/stackql/go-openapistackql/pkg/semver"
+)
+
+var (
+	_ RegistryAPI = &chainedRegistry{}
+)
+
+// chainedRegistry layers registries in priority order, eg: an internal mirror,
+// then a local override directory, then the public registry.
+// Lookups fall through the layers until one succeeds.  Once a provider version is loaded
+// from a layer, all further docs for that provider version are served by the same layer,
+// so that an override never mixes docs with those of lower priority layers;
+// other versions of the provider still fall through.
+type chainedRegistry struct {
+	layers         []RegistryAPI
+	mutex          sync.Mutex
+	providerLayers map[string]RegistryAPI
+}
+
+// NewChainedRegistry builds one layer per config, in priority order;
+// each layer applies its own verification policy.
+func NewChainedRegistry(registryCfgs []RegistryConfig, transport http.RoundTripper) (RegistryAPI, error) {
+	var layers []RegistryAPI
+	for i, cfg := range registryCfgs {
+		layer, err := newRegistry(cfg, transport)
+		if err != nil {
+			return nil, fmt.Errorf("registry layer %d: %s", i, err.Error())
+		}
+		layers = append(layers, layer)
+	}
+	return NewChainedRegistryFromLayers(layers...)
+}
+
+func NewChainedRegistryFromLayers(layers ...RegistryAPI) (RegistryAPI, error) {
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("chained registry requires at least one layer")
+	}
+	return &chainedRegistry{
+		layers:         layers,
+		providerLayers: make(map[string]RegistryAPI),
+	}, nil
+}
+
+func getProviderLayerKey(prov string, version string) string {
+	return path.Join(normaliseProviderName(prov), version)
+}
+
+// layersForProvider returns the layer pinned for an exact provider version, if any;
+// constraints are resolved per layer and so fall through every layer.
+func (cr *chainedRegistry) layersForProvider(prov string, version string) []RegistryAPI {
+	if version == "" || semver.IsConstraint(version) {
+		return cr.layers
+	}
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	if layer, ok := cr.providerLayers[getProviderLayerKey(prov, version)]; ok {
+		return []RegistryAPI{layer}
+	}
+	return cr.layers
+}
+
+func (cr *chainedRegistry) setProviderLayer(prov string, version string, layer RegistryAPI) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.providerLayers[getProviderLayerKey(prov, version)] = layer
+}
+
+// layersForDoc attributes a doc to a provider version by the leading elements of its path,
+// eg: 'okta/v0.1.0/provider.yaml'.
+func (cr *chainedRegistry) layersForDoc(docPath string) []RegistryAPI {
+	elems := strings.Split(strings.TrimPrefix(docPath, "/"), "/")
+	if len(elems) < 3 {
+		return cr.layers
+	}
+	return cr.layersForProvider(elems[0], elems[1])
+}
+
+func (cr *chainedRegistry) layersForProviderService(ps ProviderService) []RegistryAPI {
+	if ref := ps.getServiceRefRef(); ref != "" {
+		return cr.layersForDoc(ref)
+	}
+	return cr.layersForDoc(ps.getResourcesRefRef())
+}
+
+// fallThrough applies fn to each layer in turn, stopping at the first success;
+// should every layer fail, the errors are reported together.
+func fallThrough(layers []RegistryAPI, description string, fn func(RegistryAPI) error) error {
+	var msgs []string
+	for i, layer := range layers {
+		err := fn(layer)
+		if err == nil {
+			return nil
+		}
+		msgs = append(msgs, fmt.Sprintf("layer %d: %s", i, err.Error()))
+	}
+	return fmt.Errorf("no registry layer could %s: %s", description, strings.Join(msgs, "; "))
+}
+
+func (cr *chainedRegistry) PullAndPersistProviderArchive(prov string, version string) error {
+	return fallThrough(cr.layersForProvider(prov, version), fmt.Sprintf("pull provider '%s'", prov), func(layer RegistryAPI) error {
+		return layer.PullAndPersistProviderArchive(prov, version)
+	})
+}
+
+func (cr *chainedRegistry) PullProviderArchive(prov string, version string) (io.ReadCloser, error) {
+	var rv io.ReadCloser
+	err := fallThrough(cr.layersForProvider(prov, version), fmt.Sprintf("pull provider '%s'", prov), func(layer RegistryAPI) error {
+		var err error
+		rv, err = layer.PullProviderArchive(prov, version)
+		return err
+	})
+	return rv, err
+}
+
+// ListAllAvailableProviders merges the listings of all layers which can supply one;
+// where layers list the same provider, its versions are the union of those listed
+// and the higher priority layer's description is otherwise kept.
+func (cr *chainedRegistry) ListAllAvailableProviders() (map[string]ProviderDescription, error) {
+	return cr.mergeListings("list providers", func(layer RegistryAPI) (map[string]ProviderDescription, error) {
+		return layer.ListAllAvailableProviders()
+	})
+}
+
+func (cr *chainedRegistry) ListAllProviderVersions(prov string) (map[string]ProviderDescription, error) {
+	return cr.mergeListings(fmt.Sprintf("list versions of provider '%s'", prov), func(layer RegistryAPI) (map[string]ProviderDescription, error) {
+		return layer.ListAllProviderVersions(prov)
+	})
+}
+
+func (cr *chainedRegistry) ListLocallyAvailableProviders() map[string]ProviderDescription {
+	rv, _ := cr.mergeListings("list local providers", func(layer RegistryAPI) (map[string]ProviderDescription, error) {
+		return layer.ListLocallyAvailableProviders(), nil
+	})
+	return rv
+}
+
+func (cr *chainedRegistry) mergeListings(description string, fn func(RegistryAPI) (map[string]ProviderDescription, error)) (map[string]ProviderDescription, error) {
+	rv := make(map[string]ProviderDescription)
+	var msgs []string
+	succeeded := false
+	for i, layer := range cr.layers {
+		listing, err := fn(layer)
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("layer %d: %s", i, err.Error()))
+			continue
+		}
+		succeeded = true
+		for k, v := range listing {
+			merged, ok := rv[k]
+			if !ok {
+				merged = v
+				merged.Versions = nil
+			}
+			merged.Versions = mergeVersions(merged.Versions, v.Versions)
+			rv[k] = merged
+		}
+	}
+	if !succeeded {
+		return nil, fmt.Errorf("no registry layer could %s: %s", description, strings.Join(msgs, "; "))
+	}
+	return rv, nil
+}
+
+// mergeVersions returns the union of the versions, sorted.
+func mergeVersions(versions []string, others []string) []string {
+	seen := make(map[string]struct{}, len(versions)+len(others))
+	var rv []string
+	for _, v := range append(append([]string{}, versions...), others...) {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		rv = append(rv, v)
+	}
+	semver.Sort(rv)
+	return rv
+}
+
+func (cr *chainedRegistry) GetDocBytes(docPath string) ([]byte, error) {
+	var rv []byte
+	err := fallThrough(cr.layersForDoc(docPath), fmt.Sprintf("serve doc '%s'", docPath), func(layer RegistryAPI) error {
+		var err error
+		rv, err = layer.GetDocBytes(docPath)
+		return err
+	})
+	return rv, err
+}
+
+func (cr *chainedRegistry) GetLatestAvailableVersion(prov string, constraints ...string) (string, error) {
+	return cr.getLatestVersion(prov, fmt.Sprintf("resolve a version of provider '%s'", prov), func(layer RegistryAPI) (string, error) {
+		return layer.GetLatestAvailableVersion(prov, constraints...)
+	})
+}
+
+func (cr *chainedRegistry) GetLatestPublishedVersion(prov string, constraints ...string) (string, error) {
+	return cr.getLatestVersion(prov, fmt.Sprintf("resolve a published version of provider '%s'", prov), func(layer RegistryAPI) (string, error) {
+		return layer.GetLatestPublishedVersion(prov, constraints...)
+	})
+}
+
+// getLatestVersion returns the greatest of the versions resolved by each layer,
+// pinning the highest priority layer holding it.
+func (cr *chainedRegistry) getLatestVersion(prov string, description string, fn func(RegistryAPI) (string, error)) (string, error) {
+	var msgs []string
+	var candidates []string
+	candidateLayers := make(map[string]RegistryAPI)
+	for i, layer := range cr.layers {
+		v, err := fn(layer)
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("layer %d: %s", i, err.Error()))
+			continue
+		}
+		if _, ok := candidateLayers[v]; !ok {
+			candidateLayers[v] = layer
+			candidates = append(candidates, v)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no registry layer could %s: %s", description, strings.Join(msgs, "; "))
+	}
+	rv, err := semver.FindLatest(candidates)
+	if err != nil {
+		return "", err
+	}
+	cr.setProviderLayer(prov, rv, candidateLayers[rv])
+	return rv, nil
+}
+
+func (cr *chainedRegistry) GetResourcesShallowFromProvider(pr Provider, serviceKey string) (ResourceRegister, error) {
+	return pr.getResourcesShallowWithRegistry(cr, serviceKey)
+}
+
+func (cr *chainedRegistry) GetResourcesShallowFromProviderService(ps ProviderService) (ResourceRegister, error) {
+	return ps.getResourcesShallowWithRegistry(cr)
+}
+
+func (cr *chainedRegistry) GetResourcesShallowFromURL(ps ProviderService) (ResourceRegister, error) {
+	var rv ResourceRegister
+	err := fallThrough(cr.layersForProviderService(ps), fmt.Sprintf("serve resources for '%s'", ps.GetName()), func(layer RegistryAPI) error {
+		var err error
+		rv, err = layer.GetResourcesShallowFromURL(ps)
+		return err
+	})
+	return rv, err
+}
+
+func (cr *chainedRegistry) GetService(ps ProviderService) (Service, error) {
+	var rv Service
+	err := fallThrough(cr.layersForProviderService(ps), fmt.Sprintf("serve service '%s'", ps.GetName()), func(layer RegistryAPI) error {
+		var err error
+		rv, err = layer.GetService(ps)
+		return err
+	})
+	return rv, err
+}
+
+func (cr *chainedRegistry) GetServiceFragment(ps ProviderService, resourceKey string) (Service, error) {
+	var rv Service
+	err := fallThrough(cr.layersForProviderService(ps), fmt.Sprintf("serve service '%s'", ps.GetName()), func(layer RegistryAPI) error {
+		var err error
+		rv, err = layer.GetServiceFragment(ps, resourceKey)
+		return err
+	})
+	return rv, err
+}
+
+func (cr *chainedRegistry) GetServiceFromProviderService(ps ProviderService) (Service, error) {
+	if ps.getServiceRefRef() == "" {
+		return nil, fmt.Errorf("no service reachable for %s", ps.GetName())
+	}
+	return cr.GetService(ps)
+}
+
+func (cr *chainedRegistry) GetServiceDocBytes(url string) ([]byte, error) {
+	var rv []byte
+	err := fallThrough(cr.layersForDoc(url), fmt.Sprintf("serve doc '%s'", url), func(layer RegistryAPI) error {
+		var err error
+		rv, err = layer.GetServiceDocBytes(url)
+		return err
+	})
+	return rv, err
+}
+
+func (cr *chainedRegistry) GetResourcesRegisterDocBytes(url string) ([]byte, error) {
+	var rv []byte
+	err := fallThrough(cr.layersForDoc(url), fmt.Sprintf("serve doc '%s'", url), func(layer RegistryAPI) error {
+		var err error
+		rv, err = layer.GetResourcesRegisterDocBytes(url)
+		return err
+	})
+	return rv, err
+}
+
+// GetLockfile returns the lockfile of the highest priority layer configured with one.
+func (cr *chainedRegistry) GetLockfile() (Lockfile, bool) {
+	for _, layer := range cr.layers {
+		if lf, ok := layer.GetLockfile(); ok {
+			return lf, true
+		}
+	}
+	return Lockfile{}, false
+}
+
+// GetDocPatches returns the patches of every layer configured with them; where several are,
+// the result is a snapshot merging their patches with the record of those applied thus far,
+// as docs are patched by whichever layer serves them.
+func (cr *chainedRegistry) GetDocPatches() (*DocPatches, bool) {
+	var dps []*DocPatches
+	for _, layer := range cr.layers {
+		if dp, ok := layer.GetDocPatches(); ok {
+			dps = append(dps, dp)
+		}
+	}
+	switch len(dps) {
+	case 0:
+		return nil, false
+	case 1:
+		return dps[0], true
+	default:
+		return mergeDocPatches(dps), true
+	}
+}
+
+// LoadProviderByName loads from the first layer able to supply the provider version,
+// which then serves all of that provider version's docs.
+func (cr *chainedRegistry) LoadProviderByName(prov string, version string) (Provider, error) {
+	var rv Provider
+	err := fallThrough(cr.layersForProvider(prov, version), fmt.Sprintf("load provider '%s'", prov), func(layer RegistryAPI) error {
+		pr, err := layer.LoadProviderByName(prov, version)
+		if err != nil {
+			return err
+		}
+		resolved, err := layer.ResolveProviderVersion(prov, version)
+		if err != nil {
+			return err
+		}
+		cr.setProviderLayer(prov, resolved, layer)
+		rv = pr
+		return nil
+	})
+	return rv, err
+}
+
+func (cr *chainedRegistry) ResolveProviderVersion(prov string, version string) (string, error) {
+	var rv string
+	err := fallThrough(cr.layersForProvider(prov, version), fmt.Sprintf("resolve a version of provider '%s'", prov), func(layer RegistryAPI) error {
+		var err error
+		rv, err = layer.ResolveProviderVersion(prov, version)
+		return err
+	})
+	return rv, err
+}
+
+// VerifyLocalDocs gathers failures from every layer holding local docs for the provider.
+func (cr *chainedRegistry) VerifyLocalDocs(prov string) ([]DocVerificationFailure, error) {
+	var rv []DocVerificationFailure
+	var msgs []string
+	succeeded := false
+	for i, layer := range cr.layers {
+		failures, err := layer.VerifyLocalDocs(prov)
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("layer %d: %s", i, err.Error()))
+			continue
+		}
+		succeeded = true
+		rv = append(rv, failures...)
+	}
+	if !succeeded {
+		return nil, fmt.Errorf("no registry layer could verify local docs of provider '%s': %s", prov, strings.Join(msgs, "; "))
+	}
+	return rv, nil
+}
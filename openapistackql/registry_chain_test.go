@@ -0,0 +1,188 @@
+package openapistackql_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+
+	"gotest.tools/assert"
+)
+
+func TestChainedRegistryOverride(t *testing.T) {
+	registryRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry")
+	assert.NilError(t, err)
+	overrideRoot := t.TempDir()
+	overrideSrc := filepath.Join(overrideRoot, "src", "mockableprovider", "v0.1.0")
+	copyTree(t, filepath.Join(registryRoot, "src", "mockableprovider", "v0.1.0"), overrideSrc)
+	serviceDocPath := filepath.Join(overrideSrc, "services", "mockable_service.yaml")
+	b, err := os.ReadFile(serviceDocPath)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(serviceDocPath, []byte(strings.Replace(string(b), "Mockable Service for a Mockable Provider", "Overridden Mockable Service", 1)), 0644))
+
+	reg, err := NewChainedRegistry(
+		[]RegistryConfig{
+			{
+				RegistryURL:   fmt.Sprintf("file://%s", overrideRoot),
+				VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+			},
+			{
+				RegistryURL:   fmt.Sprintf("file://%s", registryRoot),
+				VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+			},
+		},
+		nil,
+	)
+	assert.NilError(t, err)
+
+	// the override layer serves the whole provider
+	prov, err := reg.LoadProviderByName("mockableprovider", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := prov.GetProviderService("mockable_service")
+	assert.NilError(t, err)
+	svc, err := reg.GetService(ps)
+	assert.NilError(t, err)
+	assert.Equal(t, svc.GetName(), "Overridden Mockable Service")
+
+	// other providers fall through to the base layer
+	prov, err = reg.LoadProviderByName("k8s", "^0.1")
+	assert.NilError(t, err)
+	assert.Equal(t, prov.GetName(), "k8s")
+	docBytes, err := reg.GetDocBytes("okta/v0.1.0/provider.yaml")
+	assert.NilError(t, err)
+	assert.Assert(t, len(docBytes) > 0)
+
+	providers, err := reg.ListAllAvailableProviders()
+	assert.NilError(t, err)
+	for _, p := range []string{"mockableprovider", "k8s", "okta", "googleapis.com"} {
+		_, ok := providers[p]
+		assert.Assert(t, ok, p)
+	}
+	assert.DeepEqual(t, providers["k8s"].Versions, []string{"v0.1.1"})
+
+	_, err = reg.LoadProviderByName("nonexistent", "v0.1.0")
+	assert.ErrorContains(t, err, "no registry layer could load provider 'nonexistent'")
+	assert.ErrorContains(t, err, "layer 1:")
+
+	t.Logf("TestChainedRegistryOverride passed")
+}
+
+func TestChainedRegistryVersionsFromDistinctLayers(t *testing.T) {
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry/src/mockableprovider/v0.1.0")
+	assert.NilError(t, err)
+	overrideRoot := t.TempDir()
+	overrideSrc := filepath.Join(overrideRoot, "src", "mockableprovider", "v0.1.0")
+	copyTree(t, srcRoot, overrideSrc)
+	serviceDocPath := filepath.Join(overrideSrc, "services", "mockable_service.yaml")
+	b, err := os.ReadFile(serviceDocPath)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(serviceDocPath, []byte(strings.Replace(string(b), "Mockable Service for a Mockable Provider", "Overridden Mockable Service", 1)), 0644))
+	baseRoot := t.TempDir()
+	baseSrc := filepath.Join(baseRoot, "src", "mockableprovider", "v0.2.0")
+	copyTree(t, srcRoot, baseSrc)
+	for _, p := range []string{"provider.yaml", "services/mockable_service.yaml"} {
+		fp := filepath.Join(baseSrc, p)
+		b, err := os.ReadFile(fp)
+		assert.NilError(t, err)
+		assert.NilError(t, os.WriteFile(fp, []byte(strings.ReplaceAll(string(b), "v0.1.0", "v0.2.0")), 0644))
+	}
+
+	reg, err := NewChainedRegistry(
+		[]RegistryConfig{
+			{
+				RegistryURL:   fmt.Sprintf("file://%s", overrideRoot),
+				VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+			},
+			{
+				RegistryURL:   fmt.Sprintf("file://%s", baseRoot),
+				VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+			},
+		},
+		nil,
+	)
+	assert.NilError(t, err)
+
+	// listings are merged, rather than shadowed by the higher priority layer
+	for _, list := range []func() (map[string]ProviderDescription, error){
+		reg.ListAllAvailableProviders,
+		func() (map[string]ProviderDescription, error) { return reg.ListAllProviderVersions("mockableprovider") },
+	} {
+		listing, err := list()
+		assert.NilError(t, err)
+		assert.DeepEqual(t, listing["mockableprovider"].Versions, []string{"v0.1.0", "v0.2.0"})
+	}
+	// the greatest version of any layer is resolved, and pinned to the layer holding it
+	latest, err := reg.GetLatestAvailableVersion("mockableprovider")
+	assert.NilError(t, err)
+	assert.Equal(t, latest, "v0.2.0")
+	_, err = reg.GetDocBytes("mockableprovider/v0.2.0/services/absent.yaml")
+	assert.ErrorContains(t, err, "layer 0:")
+	assert.Assert(t, !strings.Contains(err.Error(), "layer 1:"))
+	latest, err = reg.GetLatestAvailableVersion("mockableprovider", "^0.1")
+	assert.NilError(t, err)
+	assert.Equal(t, latest, "v0.1.0")
+
+	for _, tc := range []struct {
+		version string
+		title   string
+	}{
+		{version: "v0.1.0", title: "Overridden Mockable Service"},
+		{version: "v0.2.0", title: "Mockable Service for a Mockable Provider"},
+		{version: "v0.1.0", title: "Overridden Mockable Service"},
+	} {
+		prov, err := reg.LoadProviderByName("mockableprovider", tc.version)
+		assert.NilError(t, err, tc.version)
+		ps, err := prov.GetProviderService("mockable_service")
+		assert.NilError(t, err)
+		svc, err := reg.GetService(ps)
+		assert.NilError(t, err, tc.version)
+		assert.Equal(t, svc.GetName(), tc.title)
+		_, err = reg.GetDocBytes(fmt.Sprintf("mockableprovider/%s/services/mockable_service.yaml", tc.version))
+		assert.NilError(t, err, tc.version)
+	}
+
+	// a pinned version is served by its layer alone
+	_, err = reg.GetDocBytes("mockableprovider/v0.1.0/services/absent.yaml")
+	assert.ErrorContains(t, err, "layer 0:")
+	assert.Assert(t, !strings.Contains(err.Error(), "layer 1:"))
+
+	t.Logf("TestChainedRegistryVersionsFromDistinctLayers passed")
+}
+
+func TestChainedRegistryPerLayerVerification(t *testing.T) {
+	registryRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry")
+	assert.NilError(t, err)
+	unsignedPrefix := "unsigned-src"
+	verifying, err := NewRegistry(RegistryConfig{RegistryURL: fmt.Sprintf("file://%s", registryRoot), SrcPrefix: &unsignedPrefix}, nil)
+	assert.NilError(t, err)
+	unverified, err := NewRegistry(
+		RegistryConfig{
+			RegistryURL:   fmt.Sprintf("file://%s", registryRoot),
+			SrcPrefix:     &unsignedPrefix,
+			VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+		},
+		nil,
+	)
+	assert.NilError(t, err)
+
+	_, err = verifying.GetDocBytes("github/v1/provider.yaml")
+	assert.Assert(t, err != nil)
+
+	reg, err := NewChainedRegistryFromLayers(verifying)
+	assert.NilError(t, err)
+	_, err = reg.GetDocBytes("github/v1/provider.yaml")
+	assert.ErrorContains(t, err, "no registry layer could serve doc 'github/v1/provider.yaml'")
+
+	reg, err = NewChainedRegistryFromLayers(unverified, verifying)
+	assert.NilError(t, err)
+	_, err = reg.GetDocBytes("github/v1/provider.yaml")
+	assert.NilError(t, err)
+
+	t.Logf("TestChainedRegistryPerLayerVerification passed")
+}
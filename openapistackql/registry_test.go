@@ -1,15 +1,27 @@
 package openapistackql_test
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"testing/fstest"
+
+	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
 
 	. "github.com/stackql/go-openapistackql/openapistackql"
 	"github.com/stackql/go-openapistackql/pkg/fileutil"
+	"github.com/stackql/go-openapistackql/pkg/registryserver"
 
 	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
 )
 
 var (
@@ -30,6 +42,10 @@ const (
 	pullProvidersRegistryCfgStr             string = `{"srcPrefix": "test-src" }`
 	deprecatedRegistryCfgStr                string = `{"srcPrefix": "deprecated-src" }`
 	unsignedProvidersRegistryCfgStr         string = `{"srcPrefix": "unsigned-src",  "verifyConfig": { "nopVerify": true }  }`
+	unsignedDownloadableRegistryCfgStr      string = `{"srcPrefix": "unsigned-src", "allowSrcDownload": true, "verifyConfig": { "nopVerify": true }  }`
+	// archives under 'test/registry/dist' are signed against this certificate,
+	// the private key is not retained; to re-sign, generate a fresh pair and replace both.
+	archiveSigningCertPath string = "test/signing/cert.pem"
 )
 
 func init() {
@@ -57,11 +73,44 @@ func TestLocalRegistryIndirectGoogleComputeServiceSubsetAccess(t *testing.T) {
 }
 
 func TestProviderPull(t *testing.T) {
-	execLocalAndRemoteRegistryTests(t, pullProvidersRegistryCfgStr, execTestRegistrySimpleOktaPull)
+	execLocalAndRemoteRegistryTests(t, getSignedPullRegistryCfgStr(t), execTestRegistrySimpleOktaPull)
+}
+
+func TestProviderPullUnsignedArchiveRefused(t *testing.T) {
+	execLocalAndRemoteRegistryTests(t, pullProvidersRegistryCfgStr, execTestRegistryUnsignedGooglePullRefused)
 }
 
 func TestProviderPullAndPersist(t *testing.T) {
-	execLocalAndRemoteRegistryTests(t, pullProvidersRegistryCfgStr, execTestRegistrySimpleOktaPullAndPersist)
+	execLocalAndRemoteRegistryTests(t, getSignedPullRegistryCfgStr(t), execTestRegistrySimpleOktaPullAndPersist)
+}
+
+func TestProviderPullTamperedSignatureRefused(t *testing.T) {
+	distRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry/dist/okta")
+	assert.NilError(t, err)
+	registryRoot := t.TempDir()
+	copyTree(t, distRoot, filepath.Join(registryRoot, "dist", "okta"))
+	sigPath := filepath.Join(registryRoot, "dist", "okta", "v0.1.0.tgz.sig")
+	b, err := os.ReadFile(sigPath)
+	assert.NilError(t, err)
+	sig, err := base64.StdEncoding.DecodeString(string(b))
+	assert.NilError(t, err)
+	sig[len(sig)-1] ^= 0xff
+	assert.NilError(t, os.WriteFile(sigPath, []byte(base64.StdEncoding.EncodeToString(sig)), 0644))
+
+	rc, err := getRegistryCfgFromString(getSignedPullRegistryCfgStr(t))
+	assert.NilError(t, err)
+	rc.RegistryURL = fmt.Sprintf("file://%s", filepath.ToSlash(registryRoot))
+	rc.LocalDocRoot = t.TempDir()
+	r, err := NewRegistry(rc, nil)
+	assert.NilError(t, err)
+	_, err = r.PullProviderArchive("okta", "v0.1.0")
+	assert.ErrorContains(t, err, "failed verification")
+	err = r.PullAndPersistProviderArchive("okta", "v0.1.0")
+	assert.ErrorContains(t, err, "failed verification")
+	_, err = os.Stat(filepath.Join(rc.LocalDocRoot, "okta"))
+	assert.Assert(t, os.IsNotExist(err))
+
+	t.Logf("TestProviderPullTamperedSignatureRefused passed")
 }
 
 func TestRegistryIndirectGoogleComputeServiceMethodResolutionSeparateDocs(t *testing.T) {
@@ -88,6 +137,22 @@ func TestListProviderVersionsRegistry(t *testing.T) {
 	execRemoteRegistryTestOnly(t, unsignedProvidersRegistryCfgStr, execTestRegistryProviderVersionsList)
 }
 
+func TestHTTPServerListProvidersRegistry(t *testing.T) {
+	execHTTPServerRegistryTestOnly(t, unsignedProvidersRegistryCfgStr, execTestRegistryProvidersList)
+}
+
+func TestHTTPServerListProviderVersionsRegistry(t *testing.T) {
+	execHTTPServerRegistryTestOnly(t, unsignedProvidersRegistryCfgStr, execTestRegistryProviderVersionsList)
+}
+
+func TestHTTPServerProviderPull(t *testing.T) {
+	execHTTPServerRegistryTestOnly(t, getSignedPullRegistryCfgStr(t), execTestRegistrySimpleOktaPull)
+}
+
+func TestHTTPServerRegistryIndirectGoogleComputeServiceMethodResolutionSeparateDocs(t *testing.T) {
+	execHTTPServerRegistryTestOnly(t, unsignedDownloadableRegistryCfgStr, execTestRegistryIndirectGoogleComputeServiceMethodResolutionSeparateDocs)
+}
+
 func execLocalAndRemoteRegistryTests(t *testing.T, registryConfigStr string, tf func(t *testing.T, r RegistryAPI)) {
 
 	rc, err := getRegistryCfgFromString(registryConfigStr)
@@ -117,6 +182,47 @@ func execRemoteRegistryTestOnly(t *testing.T, registryConfigStr string, tf func(
 	runRemote(t, rc, tf)
 }
 
+// execHTTPServerRegistryTestOnly runs against a real HTTP server fronting the test registry,
+// with no local doc root, so that every doc traverses the wire.
+func execHTTPServerRegistryTestOnly(t *testing.T, registryConfigStr string, tf func(t *testing.T, r RegistryAPI)) {
+
+	rc, err := getRegistryCfgFromString(registryConfigStr)
+
+	assert.NilError(t, err)
+
+	registryRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry")
+	assert.NilError(t, err)
+	rs, err := registryserver.NewRegistryServer(registryRoot)
+	assert.NilError(t, err)
+	srv := httptest.NewServer(rs)
+	defer srv.Close()
+
+	rc.RegistryURL = srv.URL
+	r, err := NewRegistry(rc, nil)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	tf(t, r)
+}
+
+// getSignedPullRegistryCfgStr trusts the test signing certificate for archives only,
+// docs are verified as per the default configuration.
+func getSignedPullRegistryCfgStr(t *testing.T) string {
+	certPath, err := fileutil.GetFilePathFromRepositoryRoot(archiveSigningCertPath)
+	assert.NilError(t, err)
+	srcPrefix := "test-src"
+	b, err := json.Marshal(RegistryConfig{
+		SrcPrefix: &srcPrefix,
+		VerfifyConfig: &edcrypto.VerifierConfig{
+			LocalCAFilePath:      certPath,
+			LocalSigningCertPath: certPath,
+			LocalCertRegexStr:    `\.tgz$`,
+		},
+	})
+	assert.NilError(t, err)
+	return string(b)
+}
+
 func getRegistryCfgFromString(registryConfigStr string) (RegistryConfig, error) {
 	var rc RegistryConfig
 	if registryConfigStr != "" {
@@ -296,6 +402,18 @@ func execTestRegistrySimpleOktaPull(t *testing.T, r RegistryAPI) {
 
 }
 
+func execTestRegistryUnsignedGooglePullRefused(t *testing.T, r RegistryAPI) {
+	for _, vr := range googleTestableVersions {
+		_, err := r.PullProviderArchive("google", vr)
+		assert.ErrorContains(t, err, "signature file not present")
+
+		err = r.PullAndPersistProviderArchive("google", vr)
+		assert.ErrorContains(t, err, "signature file not present")
+	}
+
+	t.Logf("TestProviderPullUnsignedArchiveRefused passed")
+}
+
 func execTestRegistrySimpleOktaPullAndPersist(t *testing.T, r RegistryAPI) {
 	for _, vr := range oktaTestableVersions {
 		err := r.PullAndPersistProviderArchive("okta", vr)
@@ -583,3 +701,179 @@ func TestRegistryProviderLatestVersion(t *testing.T) {
 
 	t.Logf("TestRegistryProviderLatestVersion passed\n")
 }
+
+func TestRegistryProviderVersionConstraints(t *testing.T) {
+
+	rc, err := getRegistryCfgFromString(`{"verifyConfig": { "nopVerify": true } }`)
+	assert.NilError(t, err)
+	r, err := GetMockLocalRegistry(rc)
+	assert.NilError(t, err)
+	for constraint, expected := range map[string]string{
+		"^0.1":           "v0.1.2",
+		"~0.1.0":         "v0.1.2",
+		">=0.1.0 <0.1.2": "v0.1.0",
+		"v0.1.0":         "v0.1.0",
+	} {
+		v, err := r.ResolveProviderVersion("google", constraint)
+		assert.NilError(t, err)
+		assert.Equal(t, v, expected, constraint)
+	}
+	v, err := r.GetLatestAvailableVersion("k8s", "~0.1.0", "<0.1.1")
+	assert.NilError(t, err)
+	assert.Equal(t, v, "v0.1.0")
+	_, err = r.GetLatestAvailableVersion("okta", "^0.2")
+	assert.ErrorContains(t, err, "no versions available satisfying constraint '^0.2'")
+
+	pr, err := r.LoadProviderByName("k8s", "^0.1")
+	assert.NilError(t, err)
+	assert.Equal(t, pr.GetName(), "k8s")
+	for _, ps := range pr.GetProviderServices() {
+		assert.Equal(t, ps.GetVersion(), "v0.1.1")
+	}
+
+	t.Logf("TestRegistryProviderVersionConstraints passed\n")
+}
+
+func TestRegistryVerifyLocalDocs(t *testing.T) {
+
+	rc, err := getRegistryCfgFromString(`{"srcPrefix": "unsigned-src"}`)
+	assert.NilError(t, err)
+	r, err := GetMockLocalRegistry(rc)
+	assert.NilError(t, err)
+	failures, err := r.VerifyLocalDocs("github")
+	assert.NilError(t, err)
+	assert.Assert(t, len(failures) > 0)
+	var failedPaths []string
+	for _, f := range failures {
+		failedPaths = append(failedPaths, f.DocPath)
+	}
+	assert.Assert(t, is.Contains(failedPaths, "github/v1/provider.yaml"))
+
+	rc, err = getRegistryCfgFromString(unsignedProvidersRegistryCfgStr)
+	assert.NilError(t, err)
+	r, err = GetMockLocalRegistry(rc)
+	assert.NilError(t, err)
+	failures, err = r.VerifyLocalDocs("github")
+	assert.NilError(t, err)
+	assert.Equal(t, len(failures), 0)
+
+	t.Logf("TestRegistryVerifyLocalDocs passed\n")
+}
+
+func mapFSFromDir(t *testing.T, dir string) fstest.MapFS {
+	rv := fstest.MapFS{}
+	err := filepath.Walk(dir, func(fp string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, fp)
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(fp)
+		if err != nil {
+			return err
+		}
+		rv[filepath.ToSlash(rel)] = &fstest.MapFile{Data: b, Mode: 0644}
+		return nil
+	})
+	assert.NilError(t, err)
+	return rv
+}
+
+func zipFS(t *testing.T, files fstest.MapFS) fs.FS {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, f := range files {
+		w, err := zw.Create(name)
+		assert.NilError(t, err)
+		_, err = w.Write(f.Data)
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, zw.Close())
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NilError(t, err)
+	return zr
+}
+
+func TestFSRegistryUnverified(t *testing.T) {
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry/src/mockableprovider")
+	assert.NilError(t, err)
+	fsys := fstest.MapFS{}
+	for k, v := range mapFSFromDir(t, srcRoot) {
+		fsys["src/mockableprovider/"+k] = v
+	}
+	r, err := NewFSRegistry(fsys, RegistryConfig{VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true}})
+	assert.NilError(t, err)
+
+	versions, err := r.ListAllProviderVersions("mockableprovider")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, versions["mockableprovider"].Versions, []string{"v0.1.0"})
+	v, err := r.GetLatestAvailableVersion("mockableprovider", "^0.1")
+	assert.NilError(t, err)
+	assert.Equal(t, v, "v0.1.0")
+
+	pr, err := r.LoadProviderByName("mockableprovider", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := pr.GetProviderService("mockable_service")
+	assert.NilError(t, err)
+	svc, err := r.GetService(ps)
+	assert.NilError(t, err)
+	_, err = svc.GetResource("widgets")
+	assert.NilError(t, err)
+
+	_, err = r.GetDocBytes("../mockableprovider/v0.1.0/absent.yaml")
+	assert.ErrorContains(t, err, "cannot read local registry file")
+
+	t.Logf("TestFSRegistryUnverified passed\n")
+}
+
+func TestFSRegistrySignedZip(t *testing.T) {
+	keyPath, certPath := generateTestSigningArtifacts(t, t.TempDir())
+	registryRoot := t.TempDir()
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry/src/contrivedprovider/v0.1.0")
+	assert.NilError(t, err)
+	copyTree(t, srcRoot, filepath.Join(registryRoot, "src", "contrivedprovider", "v0.1.0"))
+	pp, err := NewProviderPackager(PackagerConfig{RegistryRoot: registryRoot, PrivateKeyPath: keyPath})
+	assert.NilError(t, err)
+	assert.NilError(t, pp.Package("contrivedprovider", "v0.1.0"))
+	files := mapFSFromDir(t, registryRoot)
+	verifyConfig := &edcrypto.VerifierConfig{
+		LocalCAFilePath:      certPath,
+		LocalSigningCertPath: certPath,
+		LocalCertRegexStr:    ".*",
+	}
+
+	r, err := NewFSRegistry(zipFS(t, files), RegistryConfig{VerfifyConfig: verifyConfig})
+	assert.NilError(t, err)
+	failures, err := r.VerifyLocalDocs("contrivedprovider")
+	assert.NilError(t, err)
+	assert.Equal(t, len(failures), 0)
+	pr, err := r.LoadProviderByName("contrivedprovider", "^0.1")
+	assert.NilError(t, err)
+	assert.Equal(t, pr.GetName(), "contrivedprovider")
+	providers, err := r.ListAllAvailableProviders()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, providers["contrivedprovider"].Versions, []string{"v0.1.0"})
+	rdr, err := r.PullProviderArchive("contrivedprovider", "v0.1.0")
+	assert.NilError(t, err)
+	_, err = io.ReadAll(rdr)
+	assert.NilError(t, err)
+
+	tampered := fstest.MapFS{}
+	for k, v := range files {
+		tampered[k] = v
+	}
+	providerDocPath := "src/contrivedprovider/v0.1.0/provider.yaml"
+	tampered[providerDocPath] = &fstest.MapFile{Data: append(append([]byte{}, files[providerDocPath].Data...), []byte("\n# tampered\n")...)}
+	r, err = NewFSRegistry(tampered, RegistryConfig{VerfifyConfig: verifyConfig})
+	assert.NilError(t, err)
+	_, err = r.LoadProviderByName("contrivedprovider", "v0.1.0")
+	assert.Assert(t, err != nil)
+	failures, err = r.VerifyLocalDocs("contrivedprovider")
+	assert.NilError(t, err)
+	assert.Equal(t, len(failures), 1)
+	assert.Equal(t, failures[0].DocPath, "contrivedprovider/v0.1.0/provider.yaml")
+
+	t.Logf("TestFSRegistrySignedZip passed\n")
+}
@@ -30,6 +30,7 @@ type Resource interface {
 	GetFirstMethodFromSQLVerb(sqlVerb string) (OperationStore, string, bool)
 	GetFirstMethodMatchFromSQLVerb(sqlVerb string, parameters map[string]interface{}) (OperationStore, map[string]interface{}, bool)
 	GetService() (Service, bool)
+	GetProviderService() (ProviderService, bool)
 	GetViewBodyDDLForSQLDialect(sqlDialect string) (string, bool)
 	GetMethodsMatched() Methods
 	ToMap(extended bool) map[string]interface{}
@@ -73,6 +74,13 @@ func (r *standardResource) GetService() (Service, bool) {
 	return r.Service, true
 }
 
+func (r *standardResource) GetProviderService() (ProviderService, bool) {
+	if r.ProviderService == nil {
+		return nil, false
+	}
+	return r.ProviderService, true
+}
+
 func (r *standardResource) getSQLVerbs() map[string][]OperationStoreRef {
 	return r.SQLVerbs
 }
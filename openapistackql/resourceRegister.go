@@ -13,8 +13,10 @@ type ResourceRegister interface {
 	GetResources() map[string]Resource
 	GetResource(string) (Resource, bool)
 	//
+	getDocDigest() string
 	getProvider() Provider
 	getProviderService() ProviderService
+	setDocDigest(digest string)
 	setOpStore(resourceString string, methodString string, opStore OperationStore)
 }
 
@@ -34,6 +36,15 @@ type standardResourceRegister struct {
 	Resources       map[string]*standardResource `json:"resources,omitempty" yaml:"resources,omitempty"`
 	ProviderService ProviderService              `json:"-" yaml:"-"` // upwards traversal
 	Provider        Provider                     `json:"-" yaml:"-"` // upwards traversal
+	docDigest       string                       // of the doc unmarshalled, if any
+}
+
+func (rr *standardResourceRegister) getDocDigest() string {
+	return rr.docDigest
+}
+
+func (rr *standardResourceRegister) setDocDigest(digest string) {
+	rr.docDigest = digest
 }
 
 func (rr *standardResourceRegister) GetResource(resourceKey string) (Resource, bool) {
@@ -0,0 +1,434 @@
+package openapistackql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// generatorObjectKeyProperties are the property names conventionally holding
+// the items of a listing, in order of preference.
+var generatorObjectKeyProperties = []string{"items", "data", "results", "values", "records", "value"}
+
+var generatorNameRegex = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// ResourceGeneratorConfig parameterises GenerateStackQLResources.
+type ResourceGeneratorConfig struct {
+	// ProviderName prefixes resource ids; required.
+	ProviderName string
+	// ServiceName follows the provider in resource ids; defaults to the doc's title.
+	ServiceName string
+}
+
+// GenerateStackQLResources infers 'x-stackQL-resources' for an OpenAPI doc which has none,
+// returning the doc with them in place, in yaml.  Paths are grouped into resources
+// on their collection, ie: the path less any trailing parameters, so that eg:
+// '/pets' and '/pets/{petId}' form the resource 'pets'.  Operations map onto methods thus:
+//
+//	GET collection    list      select
+//	GET item          get       select
+//	POST              create    insert
+//	PATCH             update    update
+//	PUT               replace   update
+//	DELETE            delete    delete
+//
+// Actions, ie: custom methods such as '/pets/{petId}:feed' or a POST only path
+// beneath an item such as '/pets/{petId}/feed', become methods of the item's resource
+// without a SQL verb.  The object key of a listing is guessed from the array valued
+// properties of its response.  Swagger 2.0 and OpenAPI 3.1 docs are converted first.
+func GenerateStackQLResources(b []byte, cfg ResourceGeneratorConfig) ([]byte, error) {
+	if cfg.ProviderName == "" {
+		return nil, fmt.Errorf("cannot generate resources: provider name is required")
+	}
+	converted, err := ConvertToOpenapi3(b)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := unmarshalGenericDoc(converted)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read openapi doc: %s", err.Error())
+	}
+	components, _ := raw["components"].(map[string]interface{})
+	if _, ok := components[ExtensionKeyResources]; ok {
+		return nil, fmt.Errorf("cannot generate resources: doc already has '%s'", ExtensionKeyResources)
+	}
+	doc, err := openapi3.NewLoader().LoadFromData(converted)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read openapi doc: %s", err.Error())
+	}
+	if cfg.ServiceName == "" && doc.Info != nil {
+		cfg.ServiceName = generatorName(strings.ToLower(doc.Info.Title))
+	}
+	if cfg.ServiceName == "" {
+		return nil, fmt.Errorf("cannot generate resources: service name is required where the doc has no title")
+	}
+	rscs := newResourceGenerator(cfg, doc).generate()
+	if len(rscs) == 0 {
+		return nil, fmt.Errorf("cannot generate resources: no operations could be grouped into resources")
+	}
+	inlinePathParameters(raw, doc)
+	if components == nil {
+		components = make(map[string]interface{})
+		raw["components"] = components
+	}
+	components[ExtensionKeyResources] = rscs
+	return marshalServiceDocYAML(raw)
+}
+
+type generatedMethod struct {
+	name        string
+	sqlVerb     string
+	path        string
+	verb        string
+	op          *openapi3.Operation
+	isListing   bool
+	paramsCount int
+}
+
+type generatedResource struct {
+	collection []string
+	methods    []*generatedMethod
+}
+
+type resourceGenerator struct {
+	cfg    ResourceGeneratorConfig
+	doc    *openapi3.T
+	groups map[string]*generatedResource
+}
+
+func newResourceGenerator(cfg ResourceGeneratorConfig, doc *openapi3.T) *resourceGenerator {
+	return &resourceGenerator{
+		cfg:    cfg,
+		doc:    doc,
+		groups: make(map[string]*generatedResource),
+	}
+}
+
+func isPathParamSegment(seg string) bool {
+	return strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")
+}
+
+func splitPath(p string) []string {
+	var rv []string
+	for _, seg := range strings.Split(p, "/") {
+		if seg != "" {
+			rv = append(rv, seg)
+		}
+	}
+	return rv
+}
+
+// trimPathParams strips trailing parameters, returning the collection and their count.
+func trimPathParams(segs []string) ([]string, int) {
+	n := 0
+	for len(segs) > 0 && isPathParamSegment(segs[len(segs)-1]) {
+		segs = segs[:len(segs)-1]
+		n++
+	}
+	return segs, n
+}
+
+func (g *resourceGenerator) generate() map[string]interface{} {
+	for _, p := range sortedKeys(g.doc.Paths) {
+		g.addPath(p, g.doc.Paths[p])
+	}
+	names := g.nameResources()
+	rv := make(map[string]interface{}, len(names))
+	for _, groupKey := range sortedKeys(g.groups) {
+		rv[names[groupKey]] = g.toResource(names[groupKey], g.groups[groupKey])
+	}
+	return rv
+}
+
+func (g *resourceGenerator) addPath(p string, pathItem *openapi3.PathItem) {
+	segs := splitPath(p)
+	if len(segs) == 0 {
+		return
+	}
+	last := segs[len(segs)-1]
+	// custom methods, eg: '/pets/{petId}:feed'
+	if i := strings.LastIndex(last, ":"); i > 0 {
+		base := append(append([]string{}, segs[:len(segs)-1]...), last[:i])
+		collection, _ := trimPathParams(base)
+		g.addActions(collection, p, pathItem, generatorName(last[i+1:]))
+		return
+	}
+	collection, n := trimPathParams(segs)
+	if n == 0 && len(segs) > 1 && isPathParamSegment(segs[len(segs)-2]) && pathItem.Get == nil && pathItem.Post != nil {
+		parent, _ := trimPathParams(segs[:len(segs)-1])
+		g.addActions(parent, p, pathItem, generatorName(last))
+		return
+	}
+	if len(collection) == 0 {
+		return
+	}
+	for _, verb := range sortedKeys(pathItem.Operations()) {
+		op := pathItem.Operations()[verb]
+		var name, sqlVerb string
+		switch strings.ToLower(verb) {
+		case "get":
+			name, sqlVerb = "list", "select"
+			if n > 0 {
+				name = "get"
+			}
+		case "post":
+			name, sqlVerb = "create", "insert"
+		case "patch":
+			name, sqlVerb = "update", "update"
+		case "put":
+			name, sqlVerb = "replace", "update"
+		case "delete":
+			name, sqlVerb = "delete", "delete"
+		default:
+			continue
+		}
+		g.addMethod(collection, &generatedMethod{
+			name:        name,
+			sqlVerb:     sqlVerb,
+			path:        p,
+			verb:        strings.ToLower(verb),
+			op:          op,
+			isListing:   name == "list",
+			paramsCount: countPathParams(p),
+		})
+	}
+}
+
+func (g *resourceGenerator) addActions(collection []string, p string, pathItem *openapi3.PathItem, name string) {
+	if len(collection) == 0 {
+		return
+	}
+	ops := pathItem.Operations()
+	for _, verb := range sortedKeys(ops) {
+		methodName := name
+		if len(ops) > 1 {
+			methodName = name + "_" + strings.ToLower(verb)
+		}
+		g.addMethod(collection, &generatedMethod{
+			name:        methodName,
+			path:        p,
+			verb:        strings.ToLower(verb),
+			op:          ops[verb],
+			paramsCount: countPathParams(p),
+		})
+	}
+}
+
+func (g *resourceGenerator) addMethod(collection []string, m *generatedMethod) {
+	key := "/" + strings.Join(collection, "/")
+	grp, ok := g.groups[key]
+	if !ok {
+		grp = &generatedResource{collection: collection}
+		g.groups[key] = grp
+	}
+	grp.methods = append(grp.methods, m)
+}
+
+// nameResources names each resource after the last fixed segment of its collection,
+// qualified by the preceding fixed segments where that is ambiguous, eg: 'orgs_repos'.
+func (g *resourceGenerator) nameResources() map[string]string {
+	staticSegs := make(map[string][]string, len(g.groups))
+	counts := make(map[string]int)
+	for k, grp := range g.groups {
+		var segs []string
+		for _, seg := range grp.collection {
+			if !isPathParamSegment(seg) {
+				segs = append(segs, generatorName(seg))
+			}
+		}
+		staticSegs[k] = segs
+		counts[segs[len(segs)-1]]++
+	}
+	rv := make(map[string]string, len(g.groups))
+	used := make(map[string]struct{}, len(g.groups))
+	for _, k := range sortedKeys(g.groups) {
+		segs := staticSegs[k]
+		name := segs[len(segs)-1]
+		if counts[name] > 1 {
+			name = strings.Join(segs, "_")
+		}
+		candidate := name
+		for i := 2; ; i++ {
+			if _, taken := used[candidate]; !taken {
+				break
+			}
+			candidate = fmt.Sprintf("%s_%d", name, i)
+		}
+		used[candidate] = struct{}{}
+		rv[k] = candidate
+	}
+	return rv
+}
+
+func (g *resourceGenerator) toResource(name string, grp *generatedResource) map[string]interface{} {
+	methods := make(map[string]interface{}, len(grp.methods))
+	sqlVerbs := map[string][]*generatedMethod{"select": {}, "insert": {}, "update": {}, "delete": {}}
+	for _, m := range grp.methods {
+		// several collections may share a name, eg: '/pets/{petId}' and '/pets/{kind}/{petId}'
+		methodName := m.name
+		for i := 2; ; i++ {
+			if _, taken := methods[methodName]; !taken {
+				break
+			}
+			methodName = fmt.Sprintf("%s_%d", m.name, i)
+		}
+		m.name = methodName
+		method := map[string]interface{}{
+			"operation": map[string]interface{}{
+				"$ref": fmt.Sprintf("#/paths/%s/%s", escapeJSONPointerToken(m.path), m.verb),
+			},
+		}
+		if mediaType, ok := getRequestMediaType(m.op); ok {
+			method["request"] = map[string]interface{}{"mediaType": mediaType}
+		}
+		if responseKey, mediaType, schema, ok := getSuccessResponse(m.op); ok {
+			response := map[string]interface{}{
+				"mediaType":     mediaType,
+				"openAPIDocKey": responseKey,
+			}
+			if m.isListing {
+				if objectKey, ok := guessObjectKey(schema); ok {
+					response["objectKey"] = objectKey
+				}
+			}
+			method["response"] = response
+		}
+		methods[methodName] = method
+		if m.sqlVerb != "" {
+			sqlVerbs[m.sqlVerb] = append(sqlVerbs[m.sqlVerb], m)
+		}
+	}
+	verbRefs := make(map[string]interface{}, len(sqlVerbs))
+	for sqlVerb, ms := range sqlVerbs {
+		// the most specific method is preferred where several match
+		sort.SliceStable(ms, func(i, j int) bool {
+			return ms[i].paramsCount > ms[j].paramsCount
+		})
+		refs := make([]interface{}, 0, len(ms))
+		for _, m := range ms {
+			refs = append(refs, map[string]interface{}{
+				"$ref": fmt.Sprintf("#/components/%s/%s/methods/%s", ExtensionKeyResources, escapeJSONPointerToken(name), m.name),
+			})
+		}
+		verbRefs[sqlVerb] = refs
+	}
+	return map[string]interface{}{
+		"id":       fmt.Sprintf("%s.%s.%s", g.cfg.ProviderName, g.cfg.ServiceName, name),
+		"name":     name,
+		"title":    name,
+		"methods":  methods,
+		"sqlVerbs": verbRefs,
+	}
+}
+
+// inlinePathParameters copies parameters declared on path items into their operations,
+// which they do not override, as only those of operations are matched on selecting a method.
+func inlinePathParameters(raw map[string]interface{}, doc *openapi3.T) {
+	paths, _ := raw["paths"].(map[string]interface{})
+	for p, v := range paths {
+		rawPathItem, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		rawParams, ok := rawPathItem["parameters"].([]interface{})
+		pathItem := doc.Paths[p]
+		if !ok || pathItem == nil || len(rawParams) != len(pathItem.Parameters) {
+			continue
+		}
+		for verb, op := range pathItem.Operations() {
+			rawOp, ok := rawPathItem[strings.ToLower(verb)].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			opParams, _ := rawOp["parameters"].([]interface{})
+			for i, param := range pathItem.Parameters {
+				if param.Value == nil || op.Parameters.GetByInAndName(param.Value.In, param.Value.Name) != nil {
+					continue
+				}
+				opParams = append(opParams, rawParams[i])
+			}
+			if len(opParams) > 0 {
+				rawOp["parameters"] = opParams
+			}
+		}
+		delete(rawPathItem, "parameters")
+	}
+}
+
+func countPathParams(p string) int {
+	return strings.Count(p, "{")
+}
+
+func generatorName(s string) string {
+	return strings.Trim(generatorNameRegex.ReplaceAllString(s, "_"), "_")
+}
+
+// pickMediaType prefers json.
+func pickMediaType(content openapi3.Content) (string, bool) {
+	keys := sortedKeys(content)
+	for _, k := range keys {
+		if k == "application/json" || strings.HasSuffix(k, "+json") {
+			return k, true
+		}
+	}
+	if len(keys) > 0 {
+		return keys[0], true
+	}
+	return "", false
+}
+
+func getRequestMediaType(op *openapi3.Operation) (string, bool) {
+	if op.RequestBody == nil || op.RequestBody.Value == nil {
+		return "", false
+	}
+	return pickMediaType(op.RequestBody.Value.Content)
+}
+
+// getSuccessResponse returns the first 2xx response having content.
+func getSuccessResponse(op *openapi3.Operation) (string, string, *openapi3.Schema, bool) {
+	for _, k := range sortedKeys(op.Responses) {
+		if !strings.HasPrefix(k, "2") {
+			continue
+		}
+		resp := op.Responses[k]
+		if resp == nil || resp.Value == nil {
+			continue
+		}
+		mediaType, ok := pickMediaType(resp.Value.Content)
+		if !ok {
+			continue
+		}
+		var schema *openapi3.Schema
+		if mt := resp.Value.Content[mediaType]; mt != nil && mt.Schema != nil {
+			schema = mt.Schema.Value
+		}
+		return k, mediaType, schema, true
+	}
+	return "", "", nil, false
+}
+
+// guessObjectKey finds the array holding the items of a listing;
+// a listing which is itself an array needs none.
+func guessObjectKey(s *openapi3.Schema) (string, bool) {
+	if s == nil || s.Type == "array" {
+		return "", false
+	}
+	isArray := func(k string) bool {
+		prop, ok := s.Properties[k]
+		return ok && prop != nil && prop.Value != nil && prop.Value.Type == "array"
+	}
+	for _, k := range generatorObjectKeyProperties {
+		if isArray(k) {
+			return "$." + k, true
+		}
+	}
+	for _, k := range sortedKeys(s.Properties) {
+		if isArray(k) {
+			return "$." + k, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,179 @@
+package openapistackql_test
+
+import (
+	"testing"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+
+	"gotest.tools/assert"
+)
+
+var plainPetStoreDoc = []byte(`openapi: 3.0.3
+info:
+  title: Pet Store
+  version: 1.0.0
+servers:
+  - url: https://petstore.example.com/v1
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        "200":
+          description: Pets
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/PetPage"
+    post:
+      operationId: createPet
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Pet"
+      responses:
+        "201":
+          description: Created
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Pet"
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+        schema:
+          type: string
+    get:
+      operationId: getPet
+      responses:
+        "200":
+          description: Pet
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Pet"
+    patch:
+      operationId: updatePet
+      requestBody:
+        content:
+          application/merge-patch+json:
+            schema:
+              $ref: "#/components/schemas/Pet"
+      responses:
+        "200":
+          description: Pet
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Pet"
+    delete:
+      operationId: deletePet
+      responses:
+        "204":
+          description: Deleted
+  /pets/{petId}/vaccinate:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+        schema:
+          type: string
+    post:
+      operationId: vaccinatePet
+      responses:
+        "204":
+          description: Vaccinated
+  /owners/{ownerId}/pets:
+    parameters:
+      - name: ownerId
+        in: path
+        required: true
+        schema:
+          type: string
+    get:
+      operationId: listOwnerPets
+      responses:
+        "200":
+          description: Pets
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/Pet"
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        id:
+          type: string
+        name:
+          type: string
+    PetPage:
+      type: object
+      properties:
+        tags:
+          type: array
+          items:
+            type: string
+        data:
+          type: array
+          items:
+            $ref: "#/components/schemas/Pet"
+        next:
+          type: string
+`)
+
+func TestGenerateStackQLResources(t *testing.T) {
+	b, err := GenerateStackQLResources(plainPetStoreDoc, ResourceGeneratorConfig{ProviderName: "petstore"})
+	assert.NilError(t, err)
+	svc, err := NewLoader().LoadFromBytes(b)
+	assert.NilError(t, err)
+
+	rscs, err := svc.GetResources()
+	assert.NilError(t, err)
+	assert.Equal(t, len(rscs), 2)
+
+	pets, err := svc.GetResource("pets")
+	assert.NilError(t, err)
+	assert.Equal(t, pets.GetID(), "petstore.pet_store.pets")
+	for sqlVerb, expected := range map[string]string{"select": "listPets", "insert": "createPet"} {
+		os, _, ok := pets.GetFirstMethodMatchFromSQLVerb(sqlVerb, map[string]interface{}{})
+		assert.Assert(t, ok, sqlVerb)
+		assert.Equal(t, os.GetOperationRef().Value.OperationID, expected)
+	}
+	os, _, ok := pets.GetFirstMethodMatchFromSQLVerb("select", map[string]interface{}{})
+	assert.Assert(t, ok)
+	assert.Equal(t, os.GetSelectItemsKey(), "$.data")
+	for sqlVerb, expected := range map[string]string{"select": "getPet", "update": "updatePet", "delete": "deletePet"} {
+		os, _, ok := pets.GetFirstMethodMatchFromSQLVerb(sqlVerb, map[string]interface{}{"petId": struct{}{}})
+		assert.Assert(t, ok, sqlVerb)
+		assert.Equal(t, os.GetOperationRef().Value.OperationID, expected)
+	}
+	update, err := pets.FindMethod("update")
+	assert.NilError(t, err)
+	req, ok := update.GetRequest()
+	assert.Assert(t, ok)
+	assert.Equal(t, req.GetBodyMediaType(), "application/merge-patch+json")
+	vaccinate, err := pets.FindMethod("vaccinate")
+	assert.NilError(t, err)
+	assert.Equal(t, vaccinate.GetOperationRef().Value.OperationID, "vaccinatePet")
+	_, err = pets.FindMethod("delete")
+	assert.NilError(t, err)
+
+	// a collection of the same name elsewhere is qualified
+	ownerPets, err := svc.GetResource("owners_pets")
+	assert.NilError(t, err)
+	os, _, ok = ownerPets.GetFirstMethodMatchFromSQLVerb("select", map[string]interface{}{"ownerId": struct{}{}})
+	assert.Assert(t, ok)
+	assert.Equal(t, os.GetOperationRef().Value.OperationID, "listOwnerPets")
+
+	_, err = GenerateStackQLResources(b, ResourceGeneratorConfig{ProviderName: "petstore"})
+	assert.ErrorContains(t, err, "doc already has 'x-stackQL-resources'")
+
+	t.Logf("TestGenerateStackQLResources passed")
+}
@@ -79,7 +79,7 @@ func (sv *standardService) setProvider(provider Provider) {
 	for _, rsc := range sv.rsc {
 		rsc.setProvider(provider)
 		if len(rsc.Methods) > 0 {
-			for _, m := range rsc.Methods {
+			for k, m := range rsc.Methods {
 				m.setProvider(provider)
 				if m.Inverse != nil {
 					inverseOpStore, inverseOpStoreExists := m.Inverse.getOperationStore()
@@ -87,6 +87,7 @@ func (sv *standardService) setProvider(provider Provider) {
 						inverseOpStore.setProvider(provider)
 					}
 				}
+				rsc.Methods[k] = m
 			}
 		}
 
@@ -98,7 +99,7 @@ func (sv *standardService) setProviderService(providerService ProviderService) {
 	for _, rsc := range sv.rsc {
 		rsc.setProviderService(providerService)
 		if len(rsc.Methods) > 0 {
-			for _, m := range rsc.Methods {
+			for k, m := range rsc.Methods {
 				m.setProviderService(providerService)
 				if m.Inverse != nil {
 					inverseOpStore, inverseOpStoreExists := m.Inverse.getOperationStore()
@@ -106,6 +107,7 @@ func (sv *standardService) setProviderService(providerService ProviderService) {
 						inverseOpStore.setProviderService(providerService)
 					}
 				}
+				rsc.Methods[k] = m
 			}
 		}
 
@@ -0,0 +1,199 @@
+package openapistackql
+
+import (
+	"container/list"
+	"sync"
+)
+
+type ServiceCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Capacity  int
+}
+
+// ServiceCache holds fully resolved services, keyed by the digest of the service doc,
+// the digest of any resources doc and the resource key, so that loading unchanged docs
+// skips both parsing and operation resolution.  Caching is opt in, per LoaderOptions;
+// each hit is served a copy of the cached resources and operation stores, bound to
+// the requesting provider service, while the parsed doc itself is shared and must not be mutated.
+type ServiceCache interface {
+	GetStats() ServiceCacheStats
+	Purge()
+	//
+	get(key serviceCacheKey) (Service, bool)
+	put(key serviceCacheKey, svc Service)
+}
+
+type serviceCacheKey struct {
+	docDigest       string
+	resourcesDigest string
+	resourceKey     string
+}
+
+type serviceCacheEntry struct {
+	key serviceCacheKey
+	svc Service
+}
+
+// standardServiceCache evicts the least recently used service once at capacity.
+type standardServiceCache struct {
+	mutex    sync.Mutex
+	capacity int
+	entries  map[serviceCacheKey]*list.Element
+	lru      *list.List
+	stats    ServiceCacheStats
+}
+
+func NewServiceCache(capacity int) ServiceCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &standardServiceCache{
+		capacity: capacity,
+		entries:  make(map[serviceCacheKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (sc *standardServiceCache) get(key serviceCacheKey) (Service, bool) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	elem, ok := sc.entries[key]
+	if !ok {
+		sc.stats.Misses++
+		return nil, false
+	}
+	sc.stats.Hits++
+	sc.lru.MoveToFront(elem)
+	return elem.Value.(*serviceCacheEntry).svc, true
+}
+
+func (sc *standardServiceCache) put(key serviceCacheKey, svc Service) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	if elem, ok := sc.entries[key]; ok {
+		elem.Value.(*serviceCacheEntry).svc = svc
+		sc.lru.MoveToFront(elem)
+		return
+	}
+	sc.entries[key] = sc.lru.PushFront(&serviceCacheEntry{key: key, svc: svc})
+	for sc.lru.Len() > sc.capacity {
+		oldest := sc.lru.Back()
+		sc.lru.Remove(oldest)
+		delete(sc.entries, oldest.Value.(*serviceCacheEntry).key)
+		sc.stats.Evictions++
+	}
+}
+
+func (sc *standardServiceCache) GetStats() ServiceCacheStats {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	rv := sc.stats
+	rv.Entries = sc.lru.Len()
+	rv.Capacity = sc.capacity
+	return rv
+}
+
+// Purge drops all cached services; statistics are retained.
+func (sc *standardServiceCache) Purge() {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	sc.entries = make(map[serviceCacheKey]*list.Element)
+	sc.lru.Init()
+}
+
+// serviceCloner copies the resources and operation stores of a cached service,
+// rebinding their upwards traversal; inverse operations may be cyclic, hence the memo.
+type serviceCloner struct {
+	svc       *standardService
+	resources map[*standardResource]*standardResource
+	opStores  map[*standardOperationStore]*standardOperationStore
+	inverses  map[*operationInverse]*operationInverse
+}
+
+func cloneCachedService(sv *standardService, prov Provider, ps ProviderService) *standardService {
+	rv := *sv
+	rv.Provider = prov
+	rv.ProviderService = ps
+	rv.rsc = make(map[string]*standardResource, len(sv.rsc))
+	c := &serviceCloner{
+		svc:       &rv,
+		resources: make(map[*standardResource]*standardResource, len(sv.rsc)),
+		opStores:  make(map[*standardOperationStore]*standardOperationStore),
+		inverses:  make(map[*operationInverse]*operationInverse),
+	}
+	for k, r := range sv.rsc {
+		cr := *r
+		cr.Service = &rv
+		cr.Provider = prov
+		cr.ProviderService = ps
+		c.resources[r] = &cr
+		rv.rsc[k] = &cr
+	}
+	for r, cr := range c.resources {
+		cr.Methods = make(Methods, len(r.Methods))
+		for k, m := range r.Methods {
+			c.bindOpStore(&m)
+			cr.Methods[k] = m
+		}
+		cr.SQLVerbs = make(map[string][]OperationStoreRef, len(r.SQLVerbs))
+		for verb, refs := range r.SQLVerbs {
+			cr.SQLVerbs[verb] = c.cloneOpStoreRefs(refs)
+		}
+	}
+	return &rv
+}
+
+func (c *serviceCloner) cloneOpStoreRefs(refs []OperationStoreRef) []OperationStoreRef {
+	if refs == nil {
+		return nil
+	}
+	rv := make([]OperationStoreRef, len(refs))
+	for i, ref := range refs {
+		rv[i] = OperationStoreRef{Ref: ref.Ref, Value: c.cloneOpStore(ref.Value)}
+	}
+	return rv
+}
+
+func (c *serviceCloner) cloneOpStore(op *standardOperationStore) *standardOperationStore {
+	if op == nil {
+		return nil
+	}
+	if rv, ok := c.opStores[op]; ok {
+		return rv
+	}
+	rv := *op
+	c.opStores[op] = &rv
+	c.bindOpStore(&rv)
+	return &rv
+}
+
+// bindOpStore rebinds a copied operation store in place.
+func (c *serviceCloner) bindOpStore(op *standardOperationStore) {
+	op.Service = c.svc
+	op.Provider = c.svc.Provider
+	op.ProviderService = c.svc.ProviderService
+	if r, ok := op.Resource.(*standardResource); ok {
+		if cr, ok := c.resources[r]; ok {
+			op.Resource = cr
+		}
+	}
+	op.Inverse = c.cloneInverse(op.Inverse)
+}
+
+func (c *serviceCloner) cloneInverse(oi *operationInverse) *operationInverse {
+	if oi == nil {
+		return nil
+	}
+	if rv, ok := c.inverses[oi]; ok {
+		return rv
+	}
+	rv := *oi
+	c.inverses[oi] = &rv
+	if oi.OpRef != nil {
+		rv.OpRef = &OperationStoreRef{Ref: oi.OpRef.Ref, Value: c.cloneOpStore(oi.OpRef.Value)}
+	}
+	return &rv
+}
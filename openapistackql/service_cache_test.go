@@ -0,0 +1,142 @@
+package openapistackql_test
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+
+	"gotest.tools/assert"
+)
+
+func TestServiceCache(t *testing.T) {
+	fileRoot, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry", "src"))
+	assert.NilError(t, err)
+	cache := NewServiceCache(2)
+	l := NewLoaderWithOptions(LoaderOptions{FileRoot: fileRoot, ServiceCache: cache})
+
+	getFragment := func(resourceKey string) Service {
+		// a freshly loaded provider shares nothing with earlier loads but the cache
+		pr, err := l.LoadProviderByName("googleapis.com", "v0.1.0")
+		assert.NilError(t, err)
+		ps, err := pr.GetProviderService("compute")
+		assert.NilError(t, err)
+		svc, err := ps.GetServiceFragment(resourceKey)
+		assert.NilError(t, err)
+		assertServiceBindings(t, svc, resourceKey, ps)
+		return svc
+	}
+
+	first := getFragment("acceleratorTypes")
+	assert.DeepEqual(t, cache.GetStats(), ServiceCacheStats{Misses: 1, Entries: 1, Capacity: 2})
+	second := getFragment("acceleratorTypes")
+	assert.Assert(t, first != second)
+	assert.Assert(t, first.GetT() == second.GetT())
+	assert.DeepEqual(t, cache.GetStats(), ServiceCacheStats{Hits: 1, Misses: 1, Entries: 1, Capacity: 2})
+
+	getFragment("disks")
+	getFragment("instances")
+	assert.DeepEqual(t, cache.GetStats(), ServiceCacheStats{Hits: 1, Misses: 3, Evictions: 1, Entries: 2, Capacity: 2})
+	// the least recently used entry was evicted
+	assert.Assert(t, getFragment("acceleratorTypes").GetT() != first.GetT())
+
+	cache.Purge()
+	assert.Equal(t, cache.GetStats().Entries, 0)
+
+	// whole services share the parsed doc, but not resources, between provider services
+	pr, err := l.LoadProviderByName("okta", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := pr.GetProviderService("application")
+	assert.NilError(t, err)
+	svc, err := l.LoadServiceDocFromFile(ps, "okta/v0.1.0/services/Application.yaml")
+	assert.NilError(t, err)
+	pr, err = l.LoadProviderByName("okta", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err = pr.GetProviderService("application")
+	assert.NilError(t, err)
+	cached, err := l.LoadServiceDocFromFile(ps, "okta/v0.1.0/services/Application.yaml")
+	assert.NilError(t, err)
+	assert.Assert(t, cached != svc)
+	assert.Assert(t, cached.GetT() == svc.GetT())
+	assert.Equal(t, cache.GetStats().Hits, uint64(2))
+	assertServiceBindings(t, cached, "apps", ps)
+	svcApps, err := svc.GetResource("apps")
+	assert.NilError(t, err)
+	cachedApps, err := cached.GetResource("apps")
+	assert.NilError(t, err)
+	assert.Assert(t, svcApps != cachedApps)
+	svcPs, _ := svcApps.GetProviderService()
+	assert.Assert(t, svcPs != ps)
+
+	t.Logf("TestServiceCache passed")
+}
+
+// assertServiceBindings checks that the resource, and its methods, traverse upwards to svc and ps.
+func assertServiceBindings(t *testing.T, svc Service, resourceKey string, ps ProviderService) {
+	rsc, err := svc.GetResource(resourceKey)
+	assert.NilError(t, err)
+	rscSvc, ok := rsc.GetService()
+	assert.Assert(t, ok)
+	assert.Assert(t, rscSvc == svc)
+	rscPs, ok := rsc.GetProviderService()
+	assert.Assert(t, ok)
+	assert.Assert(t, rscPs == ps)
+	for k, m := range rsc.GetMethods() {
+		assert.Assert(t, m.GetResource() == rsc, k)
+		assert.Assert(t, m.GetProviderService() == ps, k)
+	}
+}
+
+func TestServiceCacheNotSharedBetweenRegistries(t *testing.T) {
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry/src/mockableprovider/v0.1.0")
+	assert.NilError(t, err)
+	registryRoot := t.TempDir()
+	copyTree(t, srcRoot, filepath.Join(registryRoot, "src", "mockableprovider", "v0.1.0"))
+
+	getWidgets := func() (ProviderService, Service, Resource) {
+		reg, err := NewRegistry(
+			RegistryConfig{
+				RegistryURL:   fmt.Sprintf("file://%s", registryRoot),
+				VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+			},
+			nil,
+		)
+		assert.NilError(t, err)
+		prov, err := reg.LoadProviderByName("mockableprovider", "v0.1.0")
+		assert.NilError(t, err)
+		ps, err := prov.GetProviderService("mockable_service")
+		assert.NilError(t, err)
+		svc, err := reg.GetService(ps)
+		assert.NilError(t, err)
+		rsc, err := svc.GetResource("widgets")
+		assert.NilError(t, err)
+		return ps, svc, rsc
+	}
+
+	firstPs, firstSvc, firstRsc := getWidgets()
+	secondPs, secondSvc, secondRsc := getWidgets()
+	assert.Assert(t, firstSvc != secondSvc)
+	assert.Assert(t, firstRsc != secondRsc)
+	for _, c := range []struct {
+		ps  ProviderService
+		svc Service
+		rsc Resource
+	}{
+		{firstPs, firstSvc, firstRsc},
+		{secondPs, secondSvc, secondRsc},
+	} {
+		rscSvc, ok := c.rsc.GetService()
+		assert.Assert(t, ok)
+		assert.Assert(t, rscSvc == c.svc)
+		rscPs, ok := c.rsc.GetProviderService()
+		assert.Assert(t, ok)
+		assert.Assert(t, rscPs == c.ps)
+	}
+
+	t.Logf("TestServiceCacheNotSharedBetweenRegistries passed")
+}
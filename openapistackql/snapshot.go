@@ -0,0 +1,652 @@
+package openapistackql
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const (
+	ProviderSnapshotFormat  string = "stackql-provider-snapshot"
+	ProviderSnapshotVersion int    = 1
+)
+
+// A provider snapshot is gzipped JSON holding the provider doc and, for each provider service snapshotted,
+// the resolved service docs together with the coordinates within them of every resolved operation and schema.
+// Loading a snapshot decodes the docs and wires the object graph directly from those coordinates,
+// rather than parsing YAML and resolving operations afresh.
+type providerSnapshot struct {
+	Format           string                              `json:"format"`
+	Version          int                                 `json:"version"`
+	Provider         json.RawMessage                     `json:"provider"`
+	ProviderServices map[string]*providerServiceSnapshot `json:"providerServices,omitempty"`
+}
+
+type providerServiceSnapshot struct {
+	// ResourcesDoc is set where resources were read from a resources doc and then resolved as service fragments.
+	ResourcesDoc string                       `json:"resourcesDoc,omitempty"`
+	ServiceDoc   string                       `json:"serviceDoc,omitempty"`
+	Resources    map[string]*resourceSnapshot `json:"resources"`
+	Services     []*serviceSnapshot           `json:"services"`
+}
+
+type serviceSnapshot struct {
+	URL          string          `json:"url"`
+	Doc          json.RawMessage `json:"doc"`
+	ResourceKeys []string        `json:"resourceKeys"`
+}
+
+type resourceSnapshot struct {
+	ID                string                        `json:"id"`
+	Name              string                        `json:"name"`
+	Title             string                        `json:"title"`
+	Description       string                        `json:"description,omitempty"`
+	SelectorAlgorithm string                        `json:"selectorAlgorithm,omitempty"`
+	ServiceDoc        string                        `json:"serviceDoc,omitempty"`
+	BaseUrl           string                        `json:"baseUrl,omitempty"`
+	StackQLConfig     *standardStackQLConfig        `json:"config,omitempty"`
+	Methods           map[string]*operationSnapshot `json:"methods"`
+	SQLVerbs          map[string][]*sqlVerbSnapshot `json:"sqlVerbs"`
+}
+
+type sqlVerbSnapshot struct {
+	Ref       string `json:"ref"`
+	MethodKey string `json:"methodKey"`
+}
+
+type operationSnapshot struct {
+	SQLVerb       string                 `json:"sqlVerb,omitempty"`
+	APIMethod     string                 `json:"apiMethod,omitempty"`
+	Parameters    map[string]interface{} `json:"parameters,omitempty"`
+	OperationRef  string                 `json:"operationRef,omitempty"`
+	PathRef       string                 `json:"pathRef,omitempty"`
+	Path          string                 `json:"path,omitempty"`
+	Verb          string                 `json:"verb,omitempty"`
+	Request       *expectedBodySnapshot  `json:"request,omitempty"`
+	Response      *expectedBodySnapshot  `json:"response,omitempty"`
+	Inverse       *inverseSnapshot       `json:"inverse,omitempty"`
+	StackQLConfig *standardStackQLConfig `json:"config,omitempty"`
+	GraphQL       *standardGraphQL       `json:"graphQL,omitempty"`
+}
+
+type expectedBodySnapshot struct {
+	MediaType     string          `json:"mediaType,omitempty"`
+	OpenAPIDocKey string          `json:"openAPIDocKey,omitempty"`
+	ObjectKey     string          `json:"objectKey,omitempty"`
+	Required      []string        `json:"required,omitempty"`
+	Schema        *schemaSnapshot `json:"schema,omitempty"`
+}
+
+// schemaSnapshot locates a schema within an operation's request body or responses.
+type schemaSnapshot struct {
+	ResponseKey string `json:"responseKey,omitempty"`
+	MediaType   string `json:"mediaType"`
+	Key         string `json:"key,omitempty"`
+	Path        string `json:"path,omitempty"`
+}
+
+type inverseSnapshot struct {
+	SQLVerb *sqlVerbSnapshot `json:"sqlVerb,omitempty"`
+	Tokens  operationTokens  `json:"tokens,omitempty"`
+}
+
+type operationCoordinates struct {
+	path string
+	verb string
+}
+
+// NewProviderSnapshot resolves every resource of the provider services named, else of all provider services,
+// and serializes the provider together with its resolved services.
+func NewProviderSnapshot(pr Provider, serviceKeys ...string) ([]byte, error) {
+	providerDoc, err := pr.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	if len(serviceKeys) == 0 {
+		for k := range pr.GetProviderServices() {
+			serviceKeys = append(serviceKeys, k)
+		}
+	}
+	sort.Strings(serviceKeys)
+	snapshot := providerSnapshot{
+		Format:           ProviderSnapshotFormat,
+		Version:          ProviderSnapshotVersion,
+		Provider:         providerDoc,
+		ProviderServices: make(map[string]*providerServiceSnapshot, len(serviceKeys)),
+	}
+	for _, k := range serviceKeys {
+		ps, err := pr.GetProviderService(k)
+		if err != nil {
+			return nil, err
+		}
+		pss, err := newProviderServiceSnapshot(ps)
+		if err != nil {
+			return nil, fmt.Errorf("cannot snapshot provider service '%s': %s", k, err.Error())
+		}
+		snapshot.ProviderServices[k] = pss
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(snapshot); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func newProviderServiceSnapshot(ps ProviderService) (*providerServiceSnapshot, error) {
+	rv := &providerServiceSnapshot{
+		Resources: make(map[string]*resourceSnapshot),
+	}
+	services := make(map[string]Service)
+	serviceResourceKeys := make(map[string][]string)
+	var resources map[string]Resource
+	if ps.getResourcesRefRef() != "" {
+		rr, err := ps.GetResourcesShallow()
+		if err != nil {
+			return nil, err
+		}
+		rv.ResourcesDoc = ps.getResourcesRefRef()
+		if sdp := rr.GetServiceDocPath(); sdp != nil {
+			rv.ServiceDoc = sdp.Ref
+		}
+		resources = rr.GetResources()
+		for k, rsc := range resources {
+			url := ps.getServiceDocRef(rr, rsc).Ref
+			if url == "" {
+				continue
+			}
+			svc, err := ps.GetServiceFragment(k)
+			if err != nil {
+				return nil, err
+			}
+			// a fragment served from cache holds resources resolved from an earlier resources register
+			resolved, err := svc.GetResource(k)
+			if err != nil {
+				return nil, err
+			}
+			resources[k] = resolved
+			services[url] = svc
+			serviceResourceKeys[url] = append(serviceResourceKeys[url], k)
+		}
+	} else {
+		svc, err := ps.GetService()
+		if err != nil {
+			return nil, err
+		}
+		url := ps.getServiceRefRef()
+		resources, err = svc.GetResources()
+		if err != nil {
+			return nil, err
+		}
+		services[url] = svc
+		for k := range resources {
+			serviceResourceKeys[url] = append(serviceResourceKeys[url], k)
+		}
+	}
+	urls := make([]string, 0, len(services))
+	for url := range services {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	for _, url := range urls {
+		svc := services[url]
+		doc, err := json.Marshal(svc.GetT())
+		if err != nil {
+			return nil, err
+		}
+		keys := serviceResourceKeys[url]
+		sort.Strings(keys)
+		rv.Services = append(rv.Services, &serviceSnapshot{URL: url, Doc: doc, ResourceKeys: keys})
+		coordinates := indexOperations(svc.GetT())
+		for _, k := range keys {
+			rs, err := newResourceSnapshot(resources[k], coordinates)
+			if err != nil {
+				return nil, fmt.Errorf("resource '%s': %s", k, err.Error())
+			}
+			rv.Resources[k] = rs
+		}
+	}
+	// resources not reachable through any service doc are kept as declared
+	for k, rsc := range resources {
+		if _, ok := rv.Resources[k]; ok {
+			continue
+		}
+		rs, err := newResourceSnapshot(rsc, nil)
+		if err != nil {
+			return nil, fmt.Errorf("resource '%s': %s", k, err.Error())
+		}
+		rv.Resources[k] = rs
+	}
+	return rv, nil
+}
+
+func indexOperations(t *openapi3.T) map[*openapi3.Operation]operationCoordinates {
+	rv := make(map[*openapi3.Operation]operationCoordinates)
+	for path, pi := range t.Paths {
+		for verb, op := range pi.Operations() {
+			rv[op] = operationCoordinates{path: path, verb: verb}
+		}
+	}
+	return rv
+}
+
+func newResourceSnapshot(r Resource, coordinates map[*openapi3.Operation]operationCoordinates) (*resourceSnapshot, error) {
+	rsc, ok := r.(*standardResource)
+	if !ok {
+		return nil, fmt.Errorf("resource type '%T' not supported", r)
+	}
+	rv := &resourceSnapshot{
+		ID:                rsc.ID,
+		Name:              rsc.Name,
+		Title:             rsc.Title,
+		Description:       rsc.Description,
+		SelectorAlgorithm: rsc.SelectorAlgorithm,
+		BaseUrl:           rsc.BaseUrl,
+		StackQLConfig:     rsc.StackQLConfig,
+		Methods:           make(map[string]*operationSnapshot, len(rsc.Methods)),
+	}
+	if rsc.ServiceDocPath != nil {
+		rv.ServiceDoc = rsc.ServiceDocPath.Ref
+	}
+	for k, m := range rsc.Methods {
+		op := m
+		os, err := newOperationSnapshot(&op, coordinates)
+		if err != nil {
+			return nil, fmt.Errorf("method '%s': %s", k, err.Error())
+		}
+		rv.Methods[k] = os
+	}
+	if rsc.SQLVerbs != nil {
+		rv.SQLVerbs = make(map[string][]*sqlVerbSnapshot, len(rsc.SQLVerbs))
+		for verb, refs := range rsc.SQLVerbs {
+			rv.SQLVerbs[verb] = make([]*sqlVerbSnapshot, 0, len(refs))
+			for _, ref := range refs {
+				rv.SQLVerbs[verb] = append(rv.SQLVerbs[verb], newSQLVerbSnapshot(&ref))
+			}
+		}
+	}
+	return rv, nil
+}
+
+func newSQLVerbSnapshot(ref *OperationStoreRef) *sqlVerbSnapshot {
+	rv := &sqlVerbSnapshot{Ref: ref.Ref}
+	if ref.Value != nil {
+		rv.MethodKey = ref.Value.MethodKey
+	} else {
+		rv.MethodKey = ref.extractMethodItem()
+	}
+	return rv
+}
+
+func newOperationSnapshot(op *standardOperationStore, coordinates map[*openapi3.Operation]operationCoordinates) (*operationSnapshot, error) {
+	rv := &operationSnapshot{
+		SQLVerb:    op.SQLVerb,
+		APIMethod:  op.APIMethod,
+		Parameters: op.Parameters,
+	}
+	if op.OperationRef != nil {
+		rv.OperationRef = op.OperationRef.Ref
+	}
+	if op.PathRef != nil {
+		rv.PathRef = op.PathRef.Ref
+	}
+	if op.StackQLConfig != nil {
+		cfg, ok := op.StackQLConfig.(*standardStackQLConfig)
+		if !ok {
+			return nil, fmt.Errorf("config type '%T' not supported", op.StackQLConfig)
+		}
+		rv.StackQLConfig = cfg
+	}
+	if op.GraphQL != nil {
+		gql, ok := op.GraphQL.(*standardGraphQL)
+		if !ok {
+			return nil, fmt.Errorf("graphQL type '%T' not supported", op.GraphQL)
+		}
+		rv.GraphQL = gql
+	}
+	if op.Inverse != nil {
+		rv.Inverse = &inverseSnapshot{Tokens: op.Inverse.ReverseTokens}
+		if op.Inverse.OpRef != nil {
+			rv.Inverse.SQLVerb = newSQLVerbSnapshot(op.Inverse.OpRef)
+		}
+	}
+	var apiOp *openapi3.Operation
+	if op.OperationRef != nil && op.OperationRef.Value != nil && coordinates != nil {
+		apiOp = op.OperationRef.Value
+		c, ok := coordinates[apiOp]
+		if !ok {
+			return nil, fmt.Errorf("operation '%s' not found in service doc", rv.OperationRef)
+		}
+		rv.Path, rv.Verb = c.path, c.verb
+	}
+	if op.Request != nil {
+		rv.Request = &expectedBodySnapshot{
+			MediaType: op.Request.BodyMediaType,
+			Required:  op.Request.Required,
+		}
+		if op.Request.Schema != nil && apiOp != nil {
+			s, err := newSchemaSnapshot(op.Request.Schema, apiOp, true)
+			if err != nil {
+				return nil, err
+			}
+			rv.Request.Schema = s
+		}
+	}
+	if op.Response != nil {
+		rv.Response = &expectedBodySnapshot{
+			MediaType:     op.Response.BodyMediaType,
+			OpenAPIDocKey: op.Response.OpenAPIDocKey,
+			ObjectKey:     op.Response.ObjectKey,
+		}
+		if op.Response.Schema != nil && apiOp != nil {
+			s, err := newSchemaSnapshot(op.Response.Schema, apiOp, false)
+			if err != nil {
+				return nil, err
+			}
+			rv.Response.Schema = s
+		}
+	}
+	return rv, nil
+}
+
+func newSchemaSnapshot(s Schema, apiOp *openapi3.Operation, isRequest bool) (*schemaSnapshot, error) {
+	sc, ok := s.(*standardSchema)
+	if !ok {
+		return nil, fmt.Errorf("schema type '%T' not supported", s)
+	}
+	if isRequest {
+		if apiOp.RequestBody != nil && apiOp.RequestBody.Value != nil {
+			if mt, ok := findContentSchema(apiOp.RequestBody.Value.Content, sc.Schema); ok {
+				return &schemaSnapshot{MediaType: mt, Key: sc.key, Path: sc.path}, nil
+			}
+		}
+		return nil, fmt.Errorf("request schema '%s' not found in operation", sc.key)
+	}
+	for k, resp := range apiOp.Responses {
+		if resp == nil || resp.Value == nil {
+			continue
+		}
+		if mt, ok := findContentSchema(resp.Value.Content, sc.Schema); ok {
+			return &schemaSnapshot{ResponseKey: k, MediaType: mt, Key: sc.key, Path: sc.path}, nil
+		}
+	}
+	return nil, fmt.Errorf("response schema '%s' not found in operation", sc.key)
+}
+
+func findContentSchema(content openapi3.Content, sc *openapi3.Schema) (string, bool) {
+	for mt, v := range content {
+		if v != nil && v.Schema != nil && v.Schema.Value == sc {
+			return mt, true
+		}
+	}
+	return "", false
+}
+
+// LoadProviderSnapshot reconstructs a provider from a snapshot;
+// the services snapshotted are served to lazy loads thereafter without reading any doc.
+func LoadProviderSnapshot(b []byte) (Provider, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read provider snapshot: %s", err.Error())
+	}
+	defer zr.Close()
+	var snapshot providerSnapshot
+	if err := json.NewDecoder(zr).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("cannot read provider snapshot: %s", err.Error())
+	}
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		return nil, fmt.Errorf("cannot read provider snapshot: %s", err.Error())
+	}
+	if snapshot.Format != ProviderSnapshotFormat {
+		return nil, fmt.Errorf("not a provider snapshot")
+	}
+	if snapshot.Version != ProviderSnapshotVersion {
+		return nil, fmt.Errorf("unsupported provider snapshot version %d", snapshot.Version)
+	}
+	var prov standardProvider
+	if err := prov.UnmarshalJSON(snapshot.Provider); err != nil {
+		return nil, err
+	}
+	for _, v := range prov.ProviderServices {
+		v.setProvider(&prov)
+	}
+	for k, pss := range snapshot.ProviderServices {
+		ps, ok := prov.ProviderServices[k]
+		if !ok {
+			return nil, fmt.Errorf("provider snapshot has no provider service '%s'", k)
+		}
+		if err := pss.restore(&prov, ps); err != nil {
+			return nil, fmt.Errorf("cannot restore provider service '%s': %s", k, err.Error())
+		}
+	}
+	return &prov, nil
+}
+
+func (pss *providerServiceSnapshot) restore(pr Provider, ps *standardProviderService) error {
+	resources := make(map[string]*standardResource, len(pss.Resources))
+	for k, rs := range pss.Resources {
+		resources[k] = rs.restoreDeclared()
+	}
+	for _, ss := range pss.Services {
+		var doc openapi3.T
+		if err := json.Unmarshal(ss.Doc, &doc); err != nil {
+			return err
+		}
+		if err := (&openapi3.Loader{Context: context.Background()}).ResolveRefsIn(&doc, nil); err != nil {
+			return err
+		}
+		svc := NewService(&doc)
+		scoped := make(map[string]*standardResource, len(ss.ResourceKeys))
+		for _, k := range ss.ResourceKeys {
+			rsc, ok := resources[k]
+			if !ok {
+				return fmt.Errorf("service '%s' has no resource '%s'", ss.URL, k)
+			}
+			if err := pss.Resources[k].wire(rsc, svc); err != nil {
+				return fmt.Errorf("resource '%s': %s", k, err.Error())
+			}
+			scoped[k] = rsc
+		}
+		svc.setResourceMap(scoped)
+		if pss.ResourcesDoc != "" {
+			if _, err := ps.getOrLoadService(fragmentLoadKey(ss.URL), func() (Service, error) { return svc, nil }); err != nil {
+				return err
+			}
+			continue
+		}
+		// whole services carry their upwards traversal, as when loaded from a doc
+		svc.setProvider(pr)
+		svc.setProviderService(ps)
+		if err := newStandardLoader(getLoaderOptions()).extractAndMergeQueryTransposeServiceLevel(svc); err != nil {
+			return err
+		}
+		if _, err := ps.getOrLoadService(serviceLoadKey(ss.URL), func() (Service, error) { return svc, nil }); err != nil {
+			return err
+		}
+	}
+	if pss.ResourcesDoc == "" {
+		return nil
+	}
+	rr := newStandardResourceRegister()
+	if pss.ServiceDoc != "" {
+		rr.ServiceDocPath = &ServiceRef{Ref: pss.ServiceDoc}
+	}
+	rr.Resources = resources
+	rr.SetProvider(pr)
+	rr.SetProviderService(ps)
+	// as for resources docs, resources not resolved into any service keep their upwards traversal
+	for _, rsc := range resources {
+		if rsc.Service != nil {
+			continue
+		}
+		rsc.setProvider(pr)
+		rsc.setProviderService(ps)
+		for k, m := range rsc.Methods {
+			op := m
+			op.setProvider(pr)
+			op.setProviderService(ps)
+			op.setResource(rsc)
+			rsc.Methods[k] = op
+		}
+	}
+	_, err := ps.getOrLoadResources(resourcesLoadKey(pss.ResourcesDoc), func() (ResourceRegister, error) { return rr, nil })
+	return err
+}
+
+func (rs *resourceSnapshot) restoreDeclared() *standardResource {
+	rv := &standardResource{
+		ID:                rs.ID,
+		Name:              rs.Name,
+		Title:             rs.Title,
+		Description:       rs.Description,
+		SelectorAlgorithm: rs.SelectorAlgorithm,
+		BaseUrl:           rs.BaseUrl,
+		StackQLConfig:     rs.StackQLConfig,
+		Methods:           make(Methods, len(rs.Methods)),
+	}
+	if rs.SQLVerbs != nil {
+		rv.SQLVerbs = make(map[string][]OperationStoreRef, len(rs.SQLVerbs))
+	}
+	if rs.ServiceDoc != "" {
+		rv.ServiceDocPath = &ServiceRef{Ref: rs.ServiceDoc}
+	}
+	for k, os := range rs.Methods {
+		op := standardOperationStore{
+			MethodKey:  k,
+			SQLVerb:    os.SQLVerb,
+			APIMethod:  os.APIMethod,
+			Parameters: os.Parameters,
+		}
+		if os.OperationRef != "" {
+			op.OperationRef = &OperationRef{Ref: os.OperationRef}
+		}
+		if os.PathRef != "" {
+			op.PathRef = &PathItemRef{Ref: os.PathRef}
+		}
+		if os.StackQLConfig != nil {
+			op.StackQLConfig = os.StackQLConfig
+		}
+		if os.GraphQL != nil {
+			op.GraphQL = os.GraphQL
+		}
+		if os.Request != nil {
+			op.Request = &standardExpectedRequest{BodyMediaType: os.Request.MediaType, Required: os.Request.Required}
+		}
+		if os.Response != nil {
+			op.Response = &standardExpectedResponse{
+				BodyMediaType: os.Response.MediaType,
+				OpenAPIDocKey: os.Response.OpenAPIDocKey,
+				ObjectKey:     os.Response.ObjectKey,
+			}
+		}
+		if os.Inverse != nil {
+			op.Inverse = &operationInverse{ReverseTokens: os.Inverse.Tokens}
+			if os.Inverse.SQLVerb != nil {
+				op.Inverse.OpRef = &OperationStoreRef{Ref: os.Inverse.SQLVerb.Ref}
+			}
+		}
+		rv.Methods[k] = op
+	}
+	for verb, refs := range rs.SQLVerbs {
+		for _, ref := range refs {
+			rv.SQLVerbs[verb] = append(rv.SQLVerbs[verb], OperationStoreRef{Ref: ref.Ref})
+		}
+	}
+	return rv
+}
+
+// wire points the resource and its operation stores into the service,
+// leaving them as the loader would on resolution.
+func (rs *resourceSnapshot) wire(rsc *standardResource, svc Service) error {
+	rsc.setService(svc)
+	t := svc.GetT()
+	servers := openapi3.Servers(svc.GetServers())
+	for k, os := range rs.Methods {
+		op := rsc.Methods[k]
+		op.setService(svc)
+		op.setResource(rsc)
+		if os.Path != "" {
+			pi, ok := t.Paths[os.Path]
+			if !ok {
+				return fmt.Errorf("method '%s': no path '%s'", k, os.Path)
+			}
+			apiOp := pi.GetOperation(os.Verb)
+			if apiOp == nil {
+				return fmt.Errorf("method '%s': no operation '%s' for path '%s'", k, os.Verb, os.Path)
+			}
+			op.OperationRef = &OperationRef{Ref: os.OperationRef, Value: apiOp}
+			op.PathItem = pi
+			if os.Request != nil && os.Request.Schema != nil {
+				s, err := os.Request.Schema.restore(apiOp, svc, true)
+				if err != nil {
+					return fmt.Errorf("method '%s': %s", k, err.Error())
+				}
+				op.Request.Schema = s
+			}
+			if os.Response != nil && os.Response.Schema != nil {
+				s, err := os.Response.Schema.restore(apiOp, svc, false)
+				if err != nil {
+					return fmt.Errorf("method '%s': %s", k, err.Error())
+				}
+				op.Response.Schema = s
+			}
+		}
+		op.setServers(&servers)
+		rsc.Methods[k] = op
+	}
+	for verb, refs := range rs.SQLVerbs {
+		for i, ref := range refs {
+			v, err := rsc.copyMethod(ref.MethodKey, verb)
+			if err != nil {
+				return err
+			}
+			rsc.SQLVerbs[verb][i].Value = v
+		}
+	}
+	for k, os := range rs.Methods {
+		if os.Inverse == nil || os.Inverse.SQLVerb == nil {
+			continue
+		}
+		v, err := rsc.copyMethod(os.Inverse.SQLVerb.MethodKey, "")
+		if err != nil {
+			return err
+		}
+		// the inverse is shared by the method and all copies made of it
+		rsc.Methods[k].Inverse.OpRef.Value = v
+	}
+	return nil
+}
+
+func (r *standardResource) copyMethod(methodKey string, sqlVerb string) (*standardOperationStore, error) {
+	m, ok := r.Methods[methodKey]
+	if !ok {
+		return nil, fmt.Errorf("cannot resolve method '%s'", methodKey)
+	}
+	m.setSQLVerb(sqlVerb)
+	return &m, nil
+}
+
+func (ss *schemaSnapshot) restore(apiOp *openapi3.Operation, svc Service, isRequest bool) (Schema, error) {
+	var content openapi3.Content
+	if isRequest {
+		if apiOp.RequestBody != nil && apiOp.RequestBody.Value != nil {
+			content = apiOp.RequestBody.Value.Content
+		}
+	} else if resp, ok := apiOp.Responses[ss.ResponseKey]; ok && resp != nil && resp.Value != nil {
+		content = resp.Value.Content
+	}
+	mt, ok := content[ss.MediaType]
+	if !ok || mt == nil || mt.Schema == nil || mt.Schema.Value == nil {
+		return nil, fmt.Errorf("no schema for media type '%s'", ss.MediaType)
+	}
+	return NewSchema(mt.Schema.Value, svc, ss.Key, ss.Path), nil
+}
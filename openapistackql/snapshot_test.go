@@ -0,0 +1,286 @@
+package openapistackql_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+	"gopkg.in/yaml.v3"
+
+	"gotest.tools/assert"
+)
+
+func newSnapshotTestLoader(t testing.TB) Loader {
+	fileRoot, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry", "src"))
+	assert.NilError(t, err)
+	return NewLoaderWithOptions(LoaderOptions{FileRoot: fileRoot})
+}
+
+// assertResourceEquivalent compares resources as serialized,
+// which takes in their resolved operations, requests and responses.
+func assertResourceEquivalent(t *testing.T, want Resource, got Resource) {
+	wantBytes, err := json.Marshal(want)
+	assert.NilError(t, err)
+	gotBytes, err := json.Marshal(got)
+	assert.NilError(t, err)
+	assert.Equal(t, string(gotBytes), string(wantBytes))
+	for k, wm := range want.GetMethods() {
+		gm, err := got.FindMethod(k)
+		assert.NilError(t, err)
+		assert.Equal(t, gm.GetOperationRef().Value.OperationID, wm.GetOperationRef().Value.OperationID)
+		assert.Assert(t, gm.GetPathItem() != nil)
+	}
+}
+
+func TestProviderSnapshot(t *testing.T) {
+	l := newSnapshotTestLoader(t)
+	pr, err := l.LoadProviderByName("googleapis.com", "v0.1.0")
+	assert.NilError(t, err)
+	b, err := NewProviderSnapshot(pr, "compute", "container")
+	assert.NilError(t, err)
+
+	// no doc is read once the snapshot is loaded
+	originalRoot := OpenapiFileRoot
+	OpenapiFileRoot = t.TempDir()
+	defer func() { OpenapiFileRoot = originalRoot }()
+	restored, err := LoadProviderSnapshot(b)
+	assert.NilError(t, err)
+	assert.Equal(t, restored.GetName(), pr.GetName())
+
+	ps, err := pr.GetProviderService("compute")
+	assert.NilError(t, err)
+	restoredPs, err := restored.GetProviderService("compute")
+	assert.NilError(t, err)
+	for _, k := range []string{"acceleratorTypes", "disks", "instances"} {
+		want, err := ps.GetServiceFragment(k)
+		assert.NilError(t, err)
+		got, err := restoredPs.GetServiceFragment(k)
+		assert.NilError(t, err)
+		wantRsc, err := want.GetResource(k)
+		assert.NilError(t, err)
+		gotRsc, err := got.GetResource(k)
+		assert.NilError(t, err)
+		assertResourceEquivalent(t, wantRsc, gotRsc)
+	}
+	rsc, err := restoredPs.GetServiceFragment("acceleratorTypes")
+	assert.NilError(t, err)
+	atTypes, err := rsc.GetResource("acceleratorTypes")
+	assert.NilError(t, err)
+	os, remainingParams, ok := atTypes.GetFirstMethodMatchFromSQLVerb("select", map[string]interface{}{"project": struct{}{}})
+	assert.Assert(t, ok)
+	assert.Assert(t, len(remainingParams) == 0)
+	assert.Equal(t, os.GetOperationRef().Value.OperationID, "compute.acceleratorTypes.aggregatedList")
+
+	// whole services
+	ps, err = pr.GetProviderService("container")
+	assert.NilError(t, err)
+	want, err := ps.GetService()
+	assert.NilError(t, err)
+	restoredPs, err = restored.GetProviderService("container")
+	assert.NilError(t, err)
+	got, err := restoredPs.GetService()
+	assert.NilError(t, err)
+	wantRscs, err := want.GetResources()
+	assert.NilError(t, err)
+	gotRscs, err := got.GetResources()
+	assert.NilError(t, err)
+	assert.Equal(t, len(gotRscs), len(wantRscs))
+	for k, wantRsc := range wantRscs {
+		assertResourceEquivalent(t, wantRsc, gotRscs[k])
+	}
+
+	// provider services not snapshotted load from docs as usual
+	restoredPs, err = restored.GetProviderService("storage")
+	assert.NilError(t, err)
+	_, err = restoredPs.GetService()
+	assert.Assert(t, err != nil)
+
+	t.Logf("TestProviderSnapshot passed")
+}
+
+func TestProviderSnapshotVersion(t *testing.T) {
+	l := newSnapshotTestLoader(t)
+	pr, err := l.LoadProviderByName("okta", "v0.1.0")
+	assert.NilError(t, err)
+	b, err := NewProviderSnapshot(pr, "application")
+	assert.NilError(t, err)
+
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	assert.NilError(t, err)
+	raw, err := io.ReadAll(zr)
+	assert.NilError(t, err)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write([]byte(strings.Replace(string(raw), `"version":1`, `"version":99`, 1)))
+	assert.NilError(t, err)
+	assert.NilError(t, zw.Close())
+	_, err = LoadProviderSnapshot(buf.Bytes())
+	assert.ErrorContains(t, err, "unsupported provider snapshot version 99")
+
+	_, err = LoadProviderSnapshot([]byte("provider: okta"))
+	assert.ErrorContains(t, err, "cannot read provider snapshot")
+
+	t.Logf("TestProviderSnapshotVersion passed")
+}
+
+// The compute docs are split across service docs, resolved as fragments of the resources doc;
+// the container doc is resolved whole.
+var snapshotBenchmarkComputeResourceKeys = []string{"acceleratorTypes", "disks"}
+
+func readBenchmarkDoc(b *testing.B, docPath string) []byte {
+	fileRoot, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry", "src"))
+	assert.NilError(b, err)
+	rv, err := os.ReadFile(path.Join(fileRoot, docPath))
+	assert.NilError(b, err)
+	return rv
+}
+
+func BenchmarkLoadGoogleComputeDocs(b *testing.B) {
+	pr, err := newSnapshotTestLoader(b).LoadProviderByName("googleapis.com", "v0.1.0")
+	assert.NilError(b, err)
+	ps, err := pr.GetProviderService("compute")
+	assert.NilError(b, err)
+	resourcesDoc := readBenchmarkDoc(b, "googleapis.com/v0.1.0/resources/compute-v1.yaml")
+	serviceDocs := [][]byte{
+		readBenchmarkDoc(b, "googleapis.com/v0.1.0/services-split/compute/compute-v1.yaml"),
+		readBenchmarkDoc(b, "googleapis.com/v0.1.0/services-split/compute/compute-disks-v1.yaml"),
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rr, err := LoadResourcesShallow(ps, resourcesDoc)
+		assert.NilError(b, err)
+		for j, k := range snapshotBenchmarkComputeResourceKeys {
+			_, err := LoadServiceSubsetDocFromBytes(rr, k, serviceDocs[j])
+			assert.NilError(b, err)
+		}
+	}
+}
+
+func BenchmarkLoadGoogleComputeSnapshot(b *testing.B) {
+	pr, err := newSnapshotTestLoader(b).LoadProviderByName("googleapis.com", "v0.1.0")
+	assert.NilError(b, err)
+	snapshot, err := NewProviderSnapshot(pr, "compute")
+	assert.NilError(b, err)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		restored, err := LoadProviderSnapshot(snapshot)
+		assert.NilError(b, err)
+		ps, err := restored.GetProviderService("compute")
+		assert.NilError(b, err)
+		for _, k := range snapshotBenchmarkComputeResourceKeys {
+			_, err := ps.GetServiceFragment(k)
+			assert.NilError(b, err)
+		}
+	}
+}
+
+// readWholeGoogleComputeServiceDoc returns the compute service doc, with the resources it serves
+// inlined as 'x-stackQL-resources', which the test registry otherwise splits from it.
+func readWholeGoogleComputeServiceDoc(b *testing.B) ([]byte, []string) {
+	const splitDocPath = "googleapis.com/v0.1.0/services-split/compute/compute-v1.yaml"
+	var doc map[string]interface{}
+	assert.NilError(b, yaml.Unmarshal(readBenchmarkDoc(b, splitDocPath), &doc))
+	var rr struct {
+		Resources map[string]map[string]interface{} `yaml:"resources"`
+	}
+	assert.NilError(b, yaml.Unmarshal(readBenchmarkDoc(b, "googleapis.com/v0.1.0/resources/compute-v1.yaml"), &rr))
+	resources := make(map[string]interface{})
+	var keys []string
+	for k, rsc := range rr.Resources {
+		if _, ok := rsc["serviceDoc"]; ok {
+			continue
+		}
+		methods, _ := rsc["methods"].(map[string]interface{})
+		for _, m := range methods {
+			op, _ := m.(map[string]interface{})["operation"].(map[string]interface{})
+			if ref, ok := op["$ref"].(string); ok {
+				op["$ref"] = strings.TrimPrefix(ref, splitDocPath)
+			}
+		}
+		resources[k] = rsc
+		keys = append(keys, k)
+	}
+	doc["components"].(map[string]interface{})[ExtensionKeyResources] = resources
+	rv, err := yaml.Marshal(doc)
+	assert.NilError(b, err)
+	sort.Strings(keys)
+	return rv, keys
+}
+
+// The whole compute doc and the fragment of the compute snapshot served from the same
+// split doc hold the same resources.
+func BenchmarkLoadServiceDocFromBytesGoogleCompute(b *testing.B) {
+	pr, err := newSnapshotTestLoader(b).LoadProviderByName("googleapis.com", "v0.1.0")
+	assert.NilError(b, err)
+	ps, err := pr.GetProviderService("compute")
+	assert.NilError(b, err)
+	doc, keys := readWholeGoogleComputeServiceDoc(b)
+	svc, err := LoadServiceDocFromBytes(ps, doc)
+	assert.NilError(b, err)
+	resources, err := svc.GetResources()
+	assert.NilError(b, err)
+	assert.Equal(b, len(resources), len(keys))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := LoadServiceDocFromBytes(ps, doc)
+		assert.NilError(b, err)
+	}
+}
+
+func BenchmarkLoadGoogleComputeServiceDocSnapshot(b *testing.B) {
+	pr, err := newSnapshotTestLoader(b).LoadProviderByName("googleapis.com", "v0.1.0")
+	assert.NilError(b, err)
+	snapshot, err := NewProviderSnapshot(pr, "compute")
+	assert.NilError(b, err)
+	_, keys := readWholeGoogleComputeServiceDoc(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		restored, err := LoadProviderSnapshot(snapshot)
+		assert.NilError(b, err)
+		ps, err := restored.GetProviderService("compute")
+		assert.NilError(b, err)
+		svc, err := ps.GetServiceFragment(keys[0])
+		assert.NilError(b, err)
+		for _, k := range keys {
+			_, err := svc.GetResource(k)
+			assert.NilError(b, err)
+		}
+	}
+}
+
+func BenchmarkLoadServiceDocFromBytesGoogleContainer(b *testing.B) {
+	pr, err := newSnapshotTestLoader(b).LoadProviderByName("googleapis.com", "v0.1.0")
+	assert.NilError(b, err)
+	ps, err := pr.GetProviderService("container")
+	assert.NilError(b, err)
+	doc := readBenchmarkDoc(b, "googleapis.com/v0.1.0/services/container-v1.yaml")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := LoadServiceDocFromBytes(ps, doc)
+		assert.NilError(b, err)
+	}
+}
+
+func BenchmarkLoadGoogleContainerSnapshot(b *testing.B) {
+	pr, err := newSnapshotTestLoader(b).LoadProviderByName("googleapis.com", "v0.1.0")
+	assert.NilError(b, err)
+	snapshot, err := NewProviderSnapshot(pr, "container")
+	assert.NilError(b, err)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		restored, err := LoadProviderSnapshot(snapshot)
+		assert.NilError(b, err)
+		ps, err := restored.GetProviderService("container")
+		assert.NilError(b, err)
+		_, err = ps.GetService()
+		assert.NilError(b, err)
+	}
+}
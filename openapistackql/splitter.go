@@ -0,0 +1,285 @@
+package openapistackql
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+var (
+	pathItemOperationKeys map[string]struct{} = map[string]struct{}{
+		"get":     {},
+		"put":     {},
+		"post":    {},
+		"delete":  {},
+		"options": {},
+		"head":    {},
+		"patch":   {},
+		"trace":   {},
+	}
+)
+
+// ServiceSplitterConfig governs how a service doc is split
+// into per resource docs.
+type ServiceSplitterConfig struct {
+	// DocDir is the registry relative directory of the split docs,
+	// eg: 'googleapis.com/v0.1.0/services-split/compute'.
+	// Operation refs in the resources file are rooted here.
+	DocDir string
+}
+
+// ServiceSplit is the output of SplitServiceDoc.
+type ServiceSplit struct {
+	// Docs are keyed by registry relative doc path.
+	Docs map[string][]byte
+	// Resources is a ResourceRegister doc covering all split docs.
+	Resources []byte
+}
+
+// SplitServiceDoc emits one minimal service doc per resource
+// of the supplied doc, keeping only those paths and components
+// transitively referenced by the resource's methods, along with
+// the matching resources file.
+func SplitServiceDoc(b []byte, cfg ServiceSplitterConfig) (*ServiceSplit, error) {
+	if cfg.DocDir == "" {
+		return nil, fmt.Errorf("doc splitter requires a doc directory")
+	}
+	converted, err := ConvertToOpenapi3(b)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := unmarshalGenericDoc(converted)
+	if err != nil {
+		return nil, err
+	}
+	components, _ := doc["components"].(map[string]interface{})
+	rscs, _ := components[ExtensionKeyResources].(map[string]interface{})
+	if len(rscs) == 0 {
+		return nil, fmt.Errorf("doc has no '%s' to split", ExtensionKeyResources)
+	}
+	rv := &ServiceSplit{
+		Docs: make(map[string][]byte),
+	}
+	rrResources := make(map[string]interface{})
+	for _, k := range sortedKeys(rscs) {
+		rsc, ok := rscs[k].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("resource '%s' is not an object", k)
+		}
+		docPath := path.Join(cfg.DocDir, k+".yaml")
+		splitDoc, splitRsc, err := splitResource(doc, k, rsc, docPath)
+		if err != nil {
+			return nil, err
+		}
+		db, err := marshalServiceDocYAML(splitDoc)
+		if err != nil {
+			return nil, err
+		}
+		rv.Docs[docPath] = db
+		rrResources[k] = splitRsc
+	}
+	rv.Resources, err = marshalServiceDocYAML(map[string]interface{}{"resources": rrResources})
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// splitResource returns the minimal doc for a single resource and
+// the resource itself, rewritten with refs into that doc.
+func splitResource(doc map[string]interface{}, rscKey string, rsc map[string]interface{}, docPath string) (map[string]interface{}, map[string]interface{}, error) {
+	paths, _ := doc["paths"].(map[string]interface{})
+	splitPaths := make(map[string]interface{})
+	splitRsc := make(map[string]interface{}, len(rsc)+1)
+	for k, v := range rsc {
+		splitRsc[k] = v
+	}
+	methods, _ := rsc["methods"].(map[string]interface{})
+	splitMethods := make(map[string]interface{}, len(methods))
+	for _, mk := range sortedKeys(methods) {
+		m, ok := methods[mk].(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("resource '%s' method '%s' is not an object", rscKey, mk)
+		}
+		p, verb, err := getSplitMethodOperation(m)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resource '%s' method '%s': %s", rscKey, mk, err.Error())
+		}
+		pi, ok := paths[p].(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("resource '%s' method '%s': cannot find path '%s'", rscKey, mk, p)
+		}
+		op, ok := pi[verb]
+		if !ok {
+			return nil, nil, fmt.Errorf("resource '%s' method '%s': cannot find operation '%s' on path '%s'", rscKey, mk, verb, p)
+		}
+		splitPi, ok := splitPaths[p].(map[string]interface{})
+		if !ok {
+			splitPi = make(map[string]interface{})
+			for k, v := range pi {
+				if _, isOp := pathItemOperationKeys[k]; !isOp {
+					splitPi[k] = v
+				}
+			}
+			splitPaths[p] = splitPi
+		}
+		splitPi[verb] = op
+		splitMethod := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			splitMethod[k] = v
+		}
+		delete(splitMethod, "path")
+		splitMethod["operation"] = map[string]interface{}{
+			"$ref": fmt.Sprintf("%s#/paths/%s/%s", docPath, escapeJSONPointerToken(p), verb),
+		}
+		splitMethods[mk] = splitMethod
+	}
+	splitRsc["methods"] = splitMethods
+	splitRsc["serviceDoc"] = map[string]interface{}{"$ref": docPath}
+
+	rv := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		switch k {
+		case "paths", "components":
+		default:
+			rv[k] = v
+		}
+	}
+	rv["paths"] = splitPaths
+	rv["components"] = collectReferencedComponents(doc, splitPaths)
+	return rv, splitRsc, nil
+}
+
+// getSplitMethodOperation returns the path and http verb of a
+// method's operation, accepting deprecated path refs.
+func getSplitMethodOperation(m map[string]interface{}) (string, string, error) {
+	opRef := getGenericRef(m["operation"])
+	if opRef == "" {
+		return "", "", fmt.Errorf("no operation ref")
+	}
+	if pathRef := getGenericRef(m["path"]); pathRef != "" {
+		return pathRef, opRef, nil
+	}
+	s := extractFragment(opRef)
+	if !strings.HasPrefix(s, "/paths/") {
+		return "", "", fmt.Errorf("operation ref '%s' is not a path operation", opRef)
+	}
+	elems := strings.Split(strings.TrimPrefix(s, "/paths/"), "/")
+	if len(elems) != 2 {
+		return "", "", fmt.Errorf("operation ref '%s' is not a path operation", opRef)
+	}
+	return unescapeJSONPointerToken(elems[0]), elems[1], nil
+}
+
+func getGenericRef(v interface{}) string {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	s, _ := m["$ref"].(string)
+	return s
+}
+
+// collectReferencedComponents returns the components of doc
+// transitively referenced from paths, less 'x-stackQL-resources'.
+func collectReferencedComponents(doc map[string]interface{}, paths map[string]interface{}) map[string]interface{} {
+	components, _ := doc["components"].(map[string]interface{})
+	rv := make(map[string]interface{})
+	for k, v := range components {
+		if strings.HasPrefix(k, "x-") && k != ExtensionKeyResources {
+			rv[k] = v
+		}
+	}
+	var addRef func(kind, name string)
+	addRef = func(kind, name string) {
+		section, ok := components[kind].(map[string]interface{})
+		if !ok {
+			return
+		}
+		item, ok := section[name]
+		if !ok {
+			return
+		}
+		splitSection, ok := rv[kind].(map[string]interface{})
+		if !ok {
+			splitSection = make(map[string]interface{})
+			rv[kind] = splitSection
+		}
+		if _, seen := splitSection[name]; seen {
+			return
+		}
+		splitSection[name] = item
+		collectComponentRefs(item, addRef)
+	}
+	collectComponentRefs(paths, addRef)
+	collectSecuritySchemes(map[string]interface{}{"security": doc["security"]}, addRef)
+	collectSecuritySchemes(paths, addRef)
+	return rv
+}
+
+// collectComponentRefs walks a generic doc node, calling visit
+// for each local components ref and discriminator mapping.
+func collectComponentRefs(node interface{}, visit func(kind, name string)) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			switch k {
+			case "$ref":
+				if s, ok := v.(string); ok {
+					visitComponentRef(s, visit)
+				}
+			case "mapping":
+				if mapping, ok := v.(map[string]interface{}); ok {
+					for _, mv := range mapping {
+						if s, ok := mv.(string); ok {
+							visitComponentRef(s, visit)
+						}
+					}
+				}
+				collectComponentRefs(v, visit)
+			default:
+				collectComponentRefs(v, visit)
+			}
+		}
+	case []interface{}:
+		for _, v := range n {
+			collectComponentRefs(v, visit)
+		}
+	}
+}
+
+func visitComponentRef(ref string, visit func(kind, name string)) {
+	if !strings.HasPrefix(ref, "#/components/") {
+		return
+	}
+	elems := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+	if len(elems) < 2 {
+		return
+	}
+	visit(elems[0], unescapeJSONPointerToken(elems[1]))
+}
+
+// collectSecuritySchemes visits the schemes named by
+// security requirements below the supplied node.
+func collectSecuritySchemes(node interface{}, visit func(kind, name string)) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			reqs, ok := v.([]interface{})
+			if k != "security" || !ok {
+				collectSecuritySchemes(v, visit)
+				continue
+			}
+			for _, r := range reqs {
+				req, _ := r.(map[string]interface{})
+				for name := range req {
+					visit("securitySchemes", name)
+				}
+			}
+		}
+	case []interface{}:
+		for _, v := range n {
+			collectSecuritySchemes(v, visit)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package openapistackql_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+
+	"gotest.tools/assert"
+)
+
+func TestSplitServiceDoc(t *testing.T) {
+	setupFileRoot(t)
+	docPath, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry", "src", "googleapis.com", "v0.1.0", "services", "container-v1.yaml"))
+	assert.NilError(t, err)
+	b, err := os.ReadFile(docPath)
+	assert.NilError(t, err)
+	whole, err := NewLoader().LoadFromBytes(b)
+	assert.NilError(t, err)
+
+	split, err := SplitServiceDoc(b, ServiceSplitterConfig{DocDir: "googleapis.com/v0.1.0/services-split/container"})
+	assert.NilError(t, err)
+	wholeRscs, err := whole.GetResources()
+	assert.NilError(t, err)
+	assert.Equal(t, len(split.Docs), len(wholeRscs))
+
+	pr, err := LoadProviderByName("googleapis.com", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := pr.GetProviderService("container")
+	assert.NilError(t, err)
+	rr, err := LoadResourcesShallow(ps, split.Resources)
+	assert.NilError(t, err)
+
+	for k, wantRsc := range wholeRscs {
+		splitDocPath := rr.ObtainServiceDocUrl(k)
+		assert.Equal(t, splitDocPath, "googleapis.com/v0.1.0/services-split/container/"+k+".yaml")
+		sb, ok := split.Docs[splitDocPath]
+		assert.Assert(t, ok, k)
+		svc, err := LoadServiceSubsetDocFromBytes(rr, k, sb)
+		assert.NilError(t, err, k)
+		rscs, err := svc.GetResources()
+		assert.NilError(t, err)
+		assert.Equal(t, len(rscs), 1, k)
+		gotRsc, err := svc.GetResource(k)
+		assert.NilError(t, err)
+		for mk, wm := range wantRsc.GetMethods() {
+			gm, err := gotRsc.FindMethod(mk)
+			assert.NilError(t, err)
+			assert.Equal(t, gm.GetOperationRef().Value.OperationID, wm.GetOperationRef().Value.OperationID)
+			wantSchema, _, err := wm.GetResponseBodySchemaAndMediaType()
+			assert.NilError(t, err)
+			gotSchema, _, err := gm.GetResponseBodySchemaAndMediaType()
+			assert.NilError(t, err)
+			assert.Equal(t, gotSchema.GetName(), wantSchema.GetName())
+		}
+		assert.Assert(t, len(svc.GetT().Paths) < len(whole.GetT().Paths))
+		_, ok = svc.GetT().Components.Extensions[ExtensionKeyResources]
+		assert.Assert(t, !ok)
+	}
+
+	// only transitively referenced components are kept
+	var usable map[string]interface{}
+	assert.NilError(t, yaml.Unmarshal(split.Docs["googleapis.com/v0.1.0/services-split/container/projects.aggregated.usableSubnetworks.yaml"], &usable))
+	schemas := usable["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, k := range []string{"ListUsableSubnetworksResponse", "UsableSubnetwork", "UsableSubnetworkSecondaryRange"} {
+		_, ok := schemas[k]
+		assert.Assert(t, ok, k)
+	}
+	_, ok := schemas["Cluster"]
+	assert.Assert(t, !ok)
+	_, ok = usable["components"].(map[string]interface{})["securitySchemes"].(map[string]interface{})["Oauth2"]
+	assert.Assert(t, ok)
+	for p := range usable["paths"].(map[string]interface{}) {
+		assert.Assert(t, strings.HasSuffix(p, "/aggregated/usableSubnetworks"), p)
+	}
+
+	_, err = SplitServiceDoc(plainPetStoreDoc, ServiceSplitterConfig{DocDir: "petstore/v0.1.0/services-split/pets"})
+	assert.ErrorContains(t, err, "doc has no 'x-stackQL-resources' to split")
+
+	t.Logf("TestSplitServiceDoc passed")
+}
@@ -0,0 +1,413 @@
+package openapistackql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stackql/go-openapistackql/pkg/internaldto"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	_                   ProviderValidator = &standardProviderValidator{}
+	jsonPointerReplacer *strings.Replacer = strings.NewReplacer("~", "~0", "/", "~1")
+)
+
+// ValidationFinding locates a single problem in a provider doc tree.
+// File is relative to the doc root and Pointer is a JSON pointer into that file.
+type ValidationFinding struct {
+	File    string `json:"file" yaml:"file"`
+	Pointer string `json:"pointer" yaml:"pointer"`
+	Message string `json:"message" yaml:"message"`
+}
+
+func (vf ValidationFinding) String() string {
+	return fmt.Sprintf("%s#%s: %s", vf.File, vf.Pointer, vf.Message)
+}
+
+type ProviderValidator interface {
+	Validate(providerDocPath string) ([]ValidationFinding, error)
+}
+
+type standardProviderValidator struct {
+	docRoot  string
+	findings []ValidationFinding
+	docCache map[string]*openapi3.T
+	docErrs  map[string]error
+	// docs, where present, are read in place of the files under docRoot.
+	docs map[string][]byte
+}
+
+// NewProviderValidator returns a validator that resolves
+// doc references relative to docRoot, in the same manner as OpenapiFileRoot.
+func NewProviderValidator(docRoot string) ProviderValidator {
+	return newProviderValidatorWithDocs(docRoot, nil)
+}
+
+func newProviderValidatorWithDocs(docRoot string, docs map[string][]byte) *standardProviderValidator {
+	return &standardProviderValidator{
+		docRoot:  docRoot,
+		docCache: make(map[string]*openapi3.T),
+		docErrs:  make(map[string]error),
+		docs:     docs,
+	}
+}
+
+func jsonPointer(tokens ...string) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteString("/" + jsonPointerReplacer.Replace(t))
+	}
+	return sb.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	rv := make([]string, 0, len(m))
+	for k := range m {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+func (v *standardProviderValidator) addFinding(file, pointer, msgFormat string, args ...interface{}) {
+	v.findings = append(v.findings, ValidationFinding{File: file, Pointer: pointer, Message: fmt.Sprintf(msgFormat, args...)})
+}
+
+func (v *standardProviderValidator) readDoc(docPath string) ([]byte, error) {
+	if b, ok := v.docs[docPath]; ok {
+		return b, nil
+	}
+	return os.ReadFile(path.Join(v.docRoot, docPath))
+}
+
+func (v *standardProviderValidator) loadOpenapiDoc(docPath string) (*openapi3.T, error) {
+	if doc, ok := v.docCache[docPath]; ok {
+		return doc, nil
+	}
+	if err, ok := v.docErrs[docPath]; ok {
+		return nil, err
+	}
+	b, err := v.readDoc(docPath)
+	if err != nil {
+		v.docErrs[docPath] = err
+		return nil, err
+	}
+	l := &openapi3.Loader{Context: context.Background()}
+	doc, err := loadOpenapi3Doc(l, b)
+	if err != nil {
+		v.docErrs[docPath] = err
+		return nil, err
+	}
+	v.docCache[docPath] = doc
+	return doc, nil
+}
+
+// Validate walks the provider doc at providerDocPath and every service and
+// resources doc reachable from it, accumulating all findings rather than
+// stopping at the first one.  The returned error is reserved for
+// conditions that prevent validation from starting at all.
+func (v *standardProviderValidator) Validate(providerDocPath string) ([]ValidationFinding, error) {
+	v.findings = nil
+	b, err := v.readDoc(providerDocPath)
+	if err != nil {
+		return nil, err
+	}
+	pr, err := loadProviderDocFromBytes(b)
+	if err != nil {
+		v.addFinding(providerDocPath, "", "cannot parse provider doc: %s", err.Error())
+		return v.findings, nil
+	}
+	prov := pr.(*standardProvider)
+	if prov.StackQLConfig != nil {
+		v.validateStackQLConfig(providerDocPath, jsonPointer("config"), prov.StackQLConfig)
+	}
+	if len(prov.ProviderServices) == 0 {
+		v.addFinding(providerDocPath, jsonPointer("providerServices"), "no provider services declared")
+	}
+	for _, k := range sortedKeys(prov.ProviderServices) {
+		v.validateProviderService(providerDocPath, k, prov.ProviderServices[k])
+	}
+	return v.findings, nil
+}
+
+func (v *standardProviderValidator) validateProviderService(providerDocPath string, key string, ps *standardProviderService) {
+	ptr := jsonPointer("providerServices", key)
+	if ps.StackQLConfig != nil {
+		v.validateStackQLConfig(providerDocPath, ptr+jsonPointer("config"), ps.StackQLConfig)
+	}
+	if ps.getResourcesRefRef() != "" {
+		v.validateResourcesDoc(providerDocPath, ptr+jsonPointer("resources", "$ref"), ps)
+		return
+	}
+	if ps.getServiceRefRef() != "" {
+		v.validateServiceDoc(providerDocPath, ptr+jsonPointer("service", "$ref"), ps)
+		return
+	}
+	v.addFinding(providerDocPath, ptr, "neither service nor resources reference supplied")
+}
+
+func (v *standardProviderValidator) validateServiceDoc(providerDocPath string, ptr string, ps *standardProviderService) {
+	svcPath := ps.getServiceRefRef()
+	doc, err := v.loadOpenapiDoc(svcPath)
+	if err != nil {
+		v.addFinding(providerDocPath, ptr, "cannot load service doc '%s': %s", svcPath, err.Error())
+		return
+	}
+	if qt, ok := doc.Extensions[ExtensionKeyConfig]; ok {
+		cfg, err := extractStackQLConfig(qt)
+		if err != nil {
+			v.addFinding(svcPath, jsonPointer(ExtensionKeyConfig), "cannot parse config: %s", err.Error())
+		} else {
+			v.validateStackQLConfig(svcPath, jsonPointer(ExtensionKeyConfig), cfg.(*standardStackQLConfig))
+		}
+	}
+	rscs, ok := doc.Components.Extensions[ExtensionKeyResources]
+	if !ok {
+		v.addFinding(svcPath, jsonPointer("components"), "no '%s' present", ExtensionKeyResources)
+		return
+	}
+	var bt []byte
+	switch rs := rscs.(type) {
+	case json.RawMessage:
+		bt, err = rs.MarshalJSON()
+	default:
+		bt, err = yaml.Marshal(rscs)
+	}
+	rscMap := make(map[string]*standardResource)
+	if err == nil {
+		err = yaml.Unmarshal(bt, rscMap)
+	}
+	if err != nil {
+		v.addFinding(svcPath, jsonPointer("components", ExtensionKeyResources), "cannot parse resources: %s", err.Error())
+		return
+	}
+	findingCount := len(v.findings)
+	for _, k := range sortedKeys(rscMap) {
+		v.validateResource(svcPath, jsonPointer("components", ExtensionKeyResources, k), k, rscMap[k], svcPath, nil)
+	}
+	if len(v.findings) == findingCount {
+		b, _ := v.readDoc(svcPath)
+		if _, err := loadServiceDocFromBytes(ps, b); err != nil {
+			v.addFinding(svcPath, "", "service doc does not load: %s", err.Error())
+		}
+	}
+}
+
+func (v *standardProviderValidator) validateResourcesDoc(providerDocPath string, ptr string, ps *standardProviderService) {
+	rrPath := ps.getResourcesRefRef()
+	b, err := v.readDoc(rrPath)
+	if err != nil {
+		v.addFinding(providerDocPath, ptr, "cannot read resources doc '%s': %s", rrPath, err.Error())
+		return
+	}
+	rr, err := loadResourcesShallow(ps, b)
+	if err != nil {
+		v.addFinding(rrPath, "", "cannot parse resources doc: %s", err.Error())
+		return
+	}
+	srr := rr.(*standardResourceRegister)
+	if len(srr.Resources) == 0 {
+		v.addFinding(rrPath, jsonPointer("resources"), "no resources declared")
+	}
+	for _, k := range sortedKeys(srr.Resources) {
+		rsc := srr.Resources[k]
+		rscPtr := jsonPointer("resources", k)
+		svcPath := rr.ObtainServiceDocUrl(k)
+		if svcPath == "" {
+			v.addFinding(rrPath, rscPtr, "no service doc reachable for resource")
+			continue
+		}
+		findingCount := len(v.findings)
+		v.validateResource(rrPath, rscPtr, k, rsc, svcPath, rr.GetServiceDocPath())
+		if len(v.findings) == findingCount {
+			sb, err := v.readDoc(svcPath)
+			if err != nil {
+				continue
+			}
+			if _, err := LoadServiceSubsetDocFromBytes(rr, k, sb); err != nil {
+				v.addFinding(rrPath, rscPtr, "resource does not load: %s", err.Error())
+			}
+		}
+	}
+}
+
+// validateResource checks a single resource.  docPath and ptr locate the
+// resource definition, whilst svcPath is the service doc its operations
+// are resolved against.
+func (v *standardProviderValidator) validateResource(docPath string, ptr string, key string, rsc *standardResource, svcPath string, sr *ServiceRef) {
+	if rsc == nil {
+		v.addFinding(docPath, ptr, "empty resource")
+		return
+	}
+	if rsc.StackQLConfig != nil {
+		v.validateStackQLConfig(docPath, ptr+jsonPointer("config"), rsc.StackQLConfig)
+	}
+	if rsc.GetServiceDocPath() != nil && rsc.GetServiceDocPath().Ref != "" {
+		sr = rsc.GetServiceDocPath()
+	}
+	doc, docErr := v.loadOpenapiDoc(svcPath)
+	if docErr != nil {
+		v.addFinding(docPath, ptr, "cannot load service doc '%s': %s", svcPath, docErr.Error())
+	}
+	if len(rsc.Methods) == 0 {
+		v.addFinding(docPath, ptr+jsonPointer("methods"), "no methods declared")
+	}
+	for _, mk := range sortedKeys(rsc.Methods) {
+		m := rsc.Methods[mk]
+		mPtr := ptr + jsonPointer("methods", mk)
+		if docErr == nil {
+			v.validateMethod(docPath, mPtr, &m, doc, svcPath, sr)
+		}
+		if m.Inverse != nil {
+			v.validateInverse(docPath, mPtr+jsonPointer("inverse"), key, rsc, m.Inverse)
+		}
+	}
+	for _, verb := range sortedKeys(rsc.SQLVerbs) {
+		for i, osr := range rsc.SQLVerbs[verb] {
+			v.validateMethodRef(docPath, ptr+jsonPointer("sqlVerbs", verb, fmt.Sprintf("%d", i), "$ref"), key, rsc, osr.Ref)
+		}
+	}
+}
+
+func (v *standardProviderValidator) validateMethod(docPath string, ptr string, m *standardOperationStore, doc *openapi3.T, svcPath string, sr *ServiceRef) {
+	if m.OperationRef == nil || m.OperationRef.Ref == "" {
+		if m.StackQLConfig != nil && len(m.StackQLConfig.GetViews()) > 0 {
+			return
+		}
+		v.addFinding(docPath, ptr+jsonPointer("operation"), "no operation reference supplied")
+		return
+	}
+	refPtr := ptr + jsonPointer("operation", "$ref")
+	if m.PathRef != nil {
+		refPtr = ptr + jsonPointer("path", "$ref")
+		// resources docs have already been through this conversion on load
+		if !strings.Contains(m.OperationRef.Ref, "#") {
+			operationBackwardsCompatibility(m, sr)
+		}
+	}
+	pk := m.OperationRef.extractPathItem()
+	pi, ok := doc.Paths[pk]
+	if !ok || pi == nil {
+		v.addFinding(docPath, refPtr, "path '%s' not present in service doc '%s'", pk, svcPath)
+		return
+	}
+	mk := m.OperationRef.extractMethodItem()
+	op := pi.GetOperation(strings.ToUpper(mk))
+	if op == nil {
+		v.addFinding(docPath, refPtr, "operation '%s' not present for path '%s' in service doc '%s'", mk, pk, svcPath)
+		return
+	}
+	opPtr := jsonPointer("paths", pk, strings.ToLower(mk))
+	if qt, ok := op.Extensions[ExtensionKeyConfig]; ok {
+		cfg, err := extractStackQLConfig(qt)
+		if err != nil {
+			v.addFinding(svcPath, opPtr+jsonPointer(ExtensionKeyConfig), "cannot parse config: %s", err.Error())
+		} else {
+			v.validateStackQLConfig(svcPath, opPtr+jsonPointer(ExtensionKeyConfig), cfg.(*standardStackQLConfig))
+		}
+	}
+	if m.Response != nil && m.Response.OpenAPIDocKey != "" {
+		rr, ok := op.Responses[m.Response.OpenAPIDocKey]
+		if !ok || rr == nil || rr.Value == nil {
+			v.addFinding(docPath, ptr+jsonPointer("response", "openAPIDocKey"), "response '%s' not present for operation", m.Response.OpenAPIDocKey)
+		} else if m.Response.BodyMediaType != "" && rr.Value.Content != nil && rr.Value.Content.Get(m.Response.BodyMediaType) == nil {
+			v.addFinding(docPath, ptr+jsonPointer("response", "mediaType"), "media type '%s' not present for response '%s'", m.Response.BodyMediaType, m.Response.OpenAPIDocKey)
+		}
+	}
+	if m.Request != nil && m.Request.BodyMediaType != "" {
+		if op.RequestBody == nil || op.RequestBody.Value == nil || op.RequestBody.Value.Content.Get(m.Request.BodyMediaType) == nil {
+			v.addFinding(docPath, ptr+jsonPointer("request", "mediaType"), "request body media type '%s' not present for operation", m.Request.BodyMediaType)
+		}
+	}
+}
+
+// validateMethodRef checks a reference of the form used by sqlVerbs and
+// inverse sqlVerb entries, eg: '#/components/x-stackQL-resources/<resource>/methods/<method>'.
+func (v *standardProviderValidator) validateMethodRef(docPath string, ptr string, rscKey string, rsc *standardResource, ref string) {
+	if ref == "" {
+		v.addFinding(docPath, ptr, "empty method reference")
+		return
+	}
+	elems := strings.Split(extractFragment(ref), "/")
+	if len(elems) < 2 || elems[len(elems)-2] != "methods" {
+		v.addFinding(docPath, ptr, "reference '%s' does not address a method", ref)
+		return
+	}
+	if len(elems) >= 3 && elems[len(elems)-3] != rscKey {
+		v.addFinding(docPath, ptr, "reference '%s' addresses resource '%s' rather than '%s'", ref, elems[len(elems)-3], rscKey)
+	}
+	mk := elems[len(elems)-1]
+	if _, ok := rsc.Methods[mk]; !ok {
+		v.addFinding(docPath, ptr, "reference '%s' addresses non-existent method '%s'", ref, mk)
+	}
+}
+
+func (v *standardProviderValidator) validateInverse(docPath string, ptr string, rscKey string, rsc *standardResource, inv *operationInverse) {
+	if inv.OpRef == nil {
+		v.addFinding(docPath, ptr+jsonPointer("sqlVerb"), "inverse without sqlVerb reference")
+	} else {
+		v.validateMethodRef(docPath, ptr+jsonPointer("sqlVerb", "$ref"), rscKey, rsc, inv.OpRef.Ref)
+	}
+	for _, k := range sortedKeys(inv.ReverseTokens) {
+		v.validateTokenSemantic(docPath, ptr+jsonPointer("tokens", k), inv.ReverseTokens[k])
+	}
+}
+
+func (v *standardProviderValidator) validateStackQLConfig(docPath string, ptr string, cfg *standardStackQLConfig) {
+	if cfg.Pagination != nil {
+		if cfg.Pagination.RequestToken != nil {
+			v.validateTokenSemantic(docPath, ptr+jsonPointer("pagination", "requestToken"), cfg.Pagination.RequestToken)
+		}
+		if cfg.Pagination.ResponseToken != nil {
+			v.validateTokenSemantic(docPath, ptr+jsonPointer("pagination", "responseToken"), cfg.Pagination.ResponseToken)
+		}
+	}
+	for _, k := range sortedKeys(cfg.Views) {
+		v.validateView(docPath, ptr+jsonPointer("views", k), cfg.Views[k])
+	}
+}
+
+func (v *standardProviderValidator) validateTokenSemantic(docPath string, ptr string, ts *standardTokenSemantic) {
+	if ts == nil {
+		v.addFinding(docPath, ptr, "empty token semantic")
+		return
+	}
+	if ts.Key == "" {
+		v.addFinding(docPath, ptr+jsonPointer("key"), "token key not supplied")
+	}
+	if _, err := internaldto.ExtractHTTPElement(ts.Location); err != nil {
+		v.addFinding(docPath, ptr+jsonPointer("location"), "%s", err.Error())
+	}
+	if _, err := ts.GetTransformer(); err != nil {
+		v.addFinding(docPath, ptr, "cannot obtain transformer: %s", err.Error())
+	}
+	if re, ok := ts.Args.GetRegex(); ok {
+		if _, err := regexp.Compile(re); err != nil {
+			v.addFinding(docPath, ptr+jsonPointer("args", "regex"), "invalid regex: %s", err.Error())
+		}
+	}
+}
+
+func (v *standardProviderValidator) validateView(docPath string, ptr string, view *standardView) {
+	if view == nil {
+		v.addFinding(docPath, ptr, "empty view")
+		return
+	}
+	if strings.TrimSpace(view.DDL) == "" {
+		v.addFinding(docPath, ptr+jsonPointer("ddl"), "view DDL not supplied")
+	}
+	if view.Predicate != "" && view.getSqlDialectName() == "" {
+		v.addFinding(docPath, ptr+jsonPointer("predicate"), "unrecognised view predicate '%s'", view.Predicate)
+	}
+	if view.Fallback != nil {
+		v.validateView(docPath, ptr+jsonPointer("fallback"), view.Fallback)
+	}
+}
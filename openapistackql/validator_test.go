@@ -0,0 +1,50 @@
+package openapistackql_test
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+
+	"gotest.tools/assert"
+)
+
+func TestValidateContrivedProvider(t *testing.T) {
+	docRoot, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry", "src"))
+	assert.NilError(t, err)
+
+	findings, err := NewProviderValidator(docRoot).Validate("contrivedprovider/v0.1.0/provider.yaml")
+	assert.NilError(t, err)
+	assert.Equal(t, len(findings), 0)
+
+	t.Logf("TestValidateContrivedProvider passed")
+}
+
+func TestValidateBrokenSQLVerbRef(t *testing.T) {
+	srcRoot, err := fileutil.GetFilePathFromRepositoryRoot(path.Join("test", "registry", "src"))
+	assert.NilError(t, err)
+	docRoot := t.TempDir()
+
+	svcRelPath := "contrivedprovider/v0.1.0/services/contrived_service.yaml"
+	for _, p := range []string{"contrivedprovider/v0.1.0/provider.yaml", svcRelPath} {
+		b, err := os.ReadFile(filepath.Join(srcRoot, p))
+		assert.NilError(t, err)
+		if p == svcRelPath {
+			b = []byte(strings.Replace(string(b), "methods/get_pages'", "methods/no_such_method'", 1))
+		}
+		assert.NilError(t, os.MkdirAll(filepath.Dir(filepath.Join(docRoot, p)), 0755))
+		assert.NilError(t, os.WriteFile(filepath.Join(docRoot, p), b, 0644))
+	}
+
+	findings, err := NewProviderValidator(docRoot).Validate("contrivedprovider/v0.1.0/provider.yaml")
+	assert.NilError(t, err)
+	assert.Assert(t, len(findings) > 0)
+	assert.Equal(t, findings[0].File, svcRelPath)
+	assert.Equal(t, findings[0].Pointer, "/components/x-stackQL-resources/pages/sqlVerbs/select/0/$ref")
+
+	t.Logf("TestValidateBrokenSQLVerbRef passed")
+}
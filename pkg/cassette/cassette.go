@@ -0,0 +1,312 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Mode int
+
+const (
+	ModeReplay Mode = iota
+	ModeRecord
+)
+
+const (
+	cassetteVersion int    = 1
+	redactedValue   string = "REDACTED"
+)
+
+var (
+	_ Recorder = &standardRecorder{}
+	// DefaultRedactedHeaders are redacted from every cassette, in addition to any configured.
+	DefaultRedactedHeaders []string = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+)
+
+type fingerprintContextKey struct{}
+
+// WithFingerprint attaches a canonical parameter fingerprint,
+// eg: from 'HTTPArmouryParameters.Encode()', to the request for use in cassette matching.
+func WithFingerprint(req *http.Request, fingerprint string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), fingerprintContextKey{}, fingerprint))
+}
+
+func getFingerprint(req *http.Request) string {
+	fp, _ := req.Context().Value(fingerprintContextKey{}).(string)
+	return fp
+}
+
+type Config struct {
+	Path          string   `json:"path" yaml:"path"`
+	Mode          Mode     `json:"mode" yaml:"mode"`
+	RedactHeaders []string `json:"redactHeaders" yaml:"redactHeaders"`
+	// Transport is used for live requests when recording, defaults to http.DefaultTransport.
+	Transport http.RoundTripper `json:"-" yaml:"-"`
+}
+
+type Request struct {
+	Method      string      `json:"method" yaml:"method"`
+	URL         string      `json:"url" yaml:"url"`
+	Header      http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body        string      `json:"body,omitempty" yaml:"body,omitempty"`
+	Fingerprint string      `json:"fingerprint,omitempty" yaml:"fingerprint,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"statusCode" yaml:"statusCode"`
+	Header     http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body       string      `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request" yaml:"request"`
+	Response Response `json:"response" yaml:"response"`
+}
+
+type Cassette struct {
+	Version      int           `json:"version" yaml:"version"`
+	Interactions []Interaction `json:"interactions" yaml:"interactions"`
+}
+
+// Recorder is an http.RoundTripper which, in record mode, passes requests through
+// to the live transport and captures them; in replay mode it serves captured responses only.
+type Recorder interface {
+	http.RoundTripper
+	GetCassette() Cassette
+	Save() error
+}
+
+type standardRecorder struct {
+	mutex         sync.Mutex
+	cfg           Config
+	transport     http.RoundTripper
+	redactHeaders map[string]struct{}
+	cassette      Cassette
+	replayed      map[int]struct{}
+}
+
+func NewRecorder(cfg Config) (Recorder, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("cassette recorder requires a path")
+	}
+	rv := &standardRecorder{
+		cfg:           cfg,
+		transport:     cfg.Transport,
+		redactHeaders: make(map[string]struct{}),
+		cassette:      Cassette{Version: cassetteVersion},
+		replayed:      make(map[int]struct{}),
+	}
+	if rv.transport == nil {
+		rv.transport = http.DefaultTransport
+	}
+	for _, headers := range [][]string{DefaultRedactedHeaders, cfg.RedactHeaders} {
+		for _, h := range headers {
+			rv.redactHeaders[http.CanonicalHeaderKey(h)] = struct{}{}
+		}
+	}
+	switch cfg.Mode {
+	case ModeRecord:
+		return rv, nil
+	case ModeReplay:
+		b, err := os.ReadFile(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(b, &rv.cassette); err != nil {
+			return nil, fmt.Errorf("cannot read cassette '%s': %s", cfg.Path, err.Error())
+		}
+		if rv.cassette.Version != cassetteVersion {
+			return nil, fmt.Errorf("cassette '%s' has unsupported version %d", cfg.Path, rv.cassette.Version)
+		}
+		return rv, nil
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %d", cfg.Mode)
+	}
+}
+
+func (rc *standardRecorder) GetCassette() Cassette {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	return rc.cassette
+}
+
+func (rc *standardRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	cr, err := rc.newCassetteRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if rc.cfg.Mode == ModeReplay {
+		return rc.replay(req, cr)
+	}
+	return rc.record(req, cr)
+}
+
+func (rc *standardRecorder) newCassetteRequest(req *http.Request) (Request, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return Request{}, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return Request{
+		Method:      strings.ToUpper(req.Method),
+		URL:         canonicalURL(req.URL),
+		Header:      rc.redact(req.Header),
+		Body:        string(body),
+		Fingerprint: getFingerprint(req),
+	}, nil
+}
+
+// replay serves the first unused matching interaction;
+// once all matches are used, the last match is served again.
+func (rc *standardRecorder) replay(req *http.Request, cr Request) (*http.Response, error) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	found := -1
+	for i, interaction := range rc.cassette.Interactions {
+		if !requestsMatch(interaction.Request, cr) {
+			continue
+		}
+		found = i
+		if _, ok := rc.replayed[i]; !ok {
+			break
+		}
+	}
+	if found < 0 {
+		return nil, fmt.Errorf("no interaction in cassette '%s' matches %s %s", rc.cfg.Path, cr.Method, cr.URL)
+	}
+	rc.replayed[found] = struct{}{}
+	return newHTTPResponse(req, rc.cassette.Interactions[found].Response), nil
+}
+
+func (rc *standardRecorder) record(req *http.Request, cr Request) (*http.Response, error) {
+	resp, err := rc.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.cassette.Interactions = append(rc.cassette.Interactions, Interaction{
+		Request: cr,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     rc.redact(resp.Header),
+			Body:       string(body),
+		},
+	})
+	return resp, nil
+}
+
+// Save writes the cassette to the configured path, replacing any existing file atomically.
+func (rc *standardRecorder) Save() error {
+	rc.mutex.Lock()
+	b, err := yaml.Marshal(rc.cassette)
+	rc.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(rc.cfg.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, fmt.Sprintf(".%s-*", filepath.Base(rc.cfg.Path)))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), rc.cfg.Path)
+}
+
+func (rc *standardRecorder) redact(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	rv := h.Clone()
+	for k := range rv {
+		if _, ok := rc.redactHeaders[http.CanonicalHeaderKey(k)]; ok {
+			rv[k] = []string{redactedValue}
+		}
+	}
+	return rv
+}
+
+// requestsMatch compares method, URL and body; fingerprints are
+// compared only when both the recorded and live requests carry one.
+func requestsMatch(recorded Request, live Request) bool {
+	if recorded.Method != live.Method || recorded.URL != live.URL {
+		return false
+	}
+	if recorded.Fingerprint != "" && live.Fingerprint != "" && recorded.Fingerprint != live.Fingerprint {
+		return false
+	}
+	return canonicalBody(recorded.Body) == canonicalBody(live.Body)
+}
+
+// canonicalURL orders query parameters, so that encoding order does not affect matching.
+func canonicalURL(u *url.URL) string {
+	cu := *u
+	cu.RawQuery = cu.Query().Encode()
+	cu.ForceQuery = false
+	cu.Fragment = ""
+	return cu.String()
+}
+
+// canonicalBody normalises JSON bodies to sorted keys and no whitespace;
+// other bodies are compared verbatim.
+func canonicalBody(body string) string {
+	var v interface{}
+	if body == "" || json.Unmarshal([]byte(body), &v) != nil {
+		return body
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return string(b)
+}
+
+func newHTTPResponse(req *http.Request, r Response) *http.Response {
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
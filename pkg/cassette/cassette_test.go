@@ -0,0 +1,149 @@
+package cassette_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+	. "github.com/stackql/go-openapistackql/pkg/cassette"
+
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+	"github.com/stackql/go-openapistackql/pkg/internaldto"
+	"github.com/stackql/go-openapistackql/pkg/mockapi"
+
+	"gotest.tools/assert"
+)
+
+type failingTransport struct{}
+
+func (ft failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("network access attempted for %s", req.URL.String())
+}
+
+func getMockableService(t *testing.T) (openapistackql.Provider, openapistackql.Service) {
+	registryRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry")
+	assert.NilError(t, err)
+	reg, err := openapistackql.NewRegistry(
+		openapistackql.RegistryConfig{
+			RegistryURL:   fmt.Sprintf("file://%s", registryRoot),
+			VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+		},
+		nil,
+	)
+	assert.NilError(t, err)
+	prov, err := reg.LoadProviderByName("mockableprovider", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := prov.GetProviderService("mockable_service")
+	assert.NilError(t, err)
+	svc, err := reg.GetService(ps)
+	assert.NilError(t, err)
+	return prov, svc
+}
+
+func doGet(t *testing.T, client *http.Client, u string, fingerprint string) string {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	assert.NilError(t, err)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("X-Api-Key", "secret-key")
+	resp, err := client.Do(WithFingerprint(req, fingerprint))
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	b, err := io.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	return string(b)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	_, svc := getMockableService(t)
+	ms, err := mockapi.NewMockAPIServer(svc, mockapi.Config{})
+	assert.NilError(t, err)
+	srv := httptest.NewServer(ms)
+	defer srv.Close()
+
+	cassettePath := filepath.Join(t.TempDir(), "widgets.yaml")
+	recorder, err := NewRecorder(Config{Path: cassettePath, Mode: ModeRecord, RedactHeaders: []string{"X-Api-Key"}})
+	assert.NilError(t, err)
+	firstURL := srv.URL + "/v1/projects/my-project/widgets"
+	secondURL := firstURL + "?pageToken=page_2"
+	recordedFirst := doGet(t, &http.Client{Transport: recorder}, firstURL, "path.project=my-project")
+	recordedSecond := doGet(t, &http.Client{Transport: recorder}, secondURL, "path.project=my-project")
+	assert.NilError(t, recorder.Save())
+
+	b, err := os.ReadFile(cassettePath)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(b), "secret"))
+	assert.Equal(t, len(recorder.GetCassette().Interactions), 2)
+
+	replayer, err := NewRecorder(Config{Path: cassettePath, Mode: ModeReplay, Transport: failingTransport{}})
+	assert.NilError(t, err)
+	client := &http.Client{Transport: replayer}
+	assert.Equal(t, doGet(t, client, secondURL, "path.project=my-project"), recordedSecond)
+	assert.Equal(t, doGet(t, client, firstURL, "path.project=my-project"), recordedFirst)
+
+	_, err = client.Get(firstURL + "?pageToken=page_3")
+	assert.ErrorContains(t, err, "no interaction in cassette")
+	req, err := http.NewRequest(http.MethodGet, firstURL, nil)
+	assert.NilError(t, err)
+	_, err = client.Do(WithFingerprint(req, "path.project=other-project"))
+	assert.ErrorContains(t, err, "no interaction in cassette")
+
+	t.Logf("TestRecordAndReplay passed")
+}
+
+func TestReplayGoldenWidgetsPagination(t *testing.T) {
+	prov, svc := getMockableService(t)
+	rsc, err := svc.GetResource("widgets")
+	assert.NilError(t, err)
+	m, err := rsc.FindMethod("list")
+	assert.NilError(t, err)
+	ts, ok := m.GetPaginationResponseTokenSemantic()
+	assert.Assert(t, ok)
+	requestToken, ok := m.GetPaginationRequestTokenSemantic()
+	assert.Assert(t, ok)
+
+	cassettePath, err := fileutil.GetFilePathFromRepositoryRoot("test/cassettes/mockableprovider/v0.1.0/widgets_list.yaml")
+	assert.NilError(t, err)
+	replayer, err := NewRecorder(Config{Path: cassettePath, Mode: ModeReplay, Transport: failingTransport{}})
+	assert.NilError(t, err)
+	client := &http.Client{Transport: replayer}
+
+	pr := openapistackql.NewHTTPPreparator(prov, svc, m, map[int]map[string]interface{}{0: {"project": "my-project"}}, nil, nil, nil)
+	armoury, err := pr.BuildHTTPRequestCtx()
+	assert.NilError(t, err)
+	assert.Equal(t, len(armoury.GetRequestParams()), 1)
+	params := armoury.GetRequestParams()[0]
+
+	var ids []string
+	req := params.GetRequest()
+	for req != nil {
+		resp, err := client.Do(WithFingerprint(req, params.Encode()))
+		assert.NilError(t, err)
+		processed, err := m.ProcessResponse(resp)
+		assert.NilError(t, err)
+		res, ok := processed.GetResponse()
+		assert.Assert(t, ok)
+		items, ok := res.GetProcessedBody().([]interface{})
+		assert.Assert(t, ok)
+		for _, item := range items {
+			ids = append(ids, item.(map[string]interface{})["id"].(string))
+		}
+		req = nil
+		tok, err := ts.GetProcessedToken(res)
+		if err == nil && tok != nil {
+			req, err = params.SetNextPage(m, tok.(string), internaldto.NewHTTPElement(internaldto.QueryParam, requestToken.GetKey()))
+			assert.NilError(t, err)
+		}
+	}
+	assert.DeepEqual(t, ids, []string{"widget-1", "widget-2", "widget-3"})
+
+	t.Logf("TestReplayGoldenWidgetsPagination passed")
+}
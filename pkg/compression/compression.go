@@ -2,46 +2,527 @@ package compression
 
 import (
 	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatTarGzip
+	FormatTarZstd
+	FormatZip
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatTarGzip:
+		return "tar.gz"
+	case FormatTarZstd:
+		return "tar.zst"
+	case FormatZip:
+		return "zip"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	gzipMagic []byte = []byte{0x1f, 0x8b}
+	zstdMagic []byte = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	zipMagic  []byte = []byte("PK\x03\x04")
+	// an archive with no entries consists of the end of central directory record only
+	zipEmptyMagic []byte = []byte("PK\x05\x06")
+)
+
+// Limits bound the resources consumed by extraction; a zero value field means no limit.
+type Limits struct {
+	MaxFileCount  int
+	MaxFileBytes  int64
+	MaxTotalBytes int64
+}
+
+var DefaultLimits Limits = Limits{
+	MaxFileCount:  100000,
+	MaxFileBytes:  256 << 20,
+	MaxTotalBytes: 2 << 30,
+}
+
+type entryKind int
+
+const (
+	entryDir entryKind = iota
+	entryRegular
+	entrySymlink
+	entryHardLink
 )
 
+type archiveEntry struct {
+	name     string
+	kind     entryKind
+	mode     os.FileMode
+	size     int64
+	linkname string
+	open     func() (io.ReadCloser, error)
+}
+
+// DetectFormat infers the archive format from leading magic bytes.
+func DetectFormat(header []byte) Format {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return FormatTarGzip
+	case bytes.HasPrefix(header, zstdMagic):
+		return FormatTarZstd
+	case bytes.HasPrefix(header, zipMagic), bytes.HasPrefix(header, zipEmptyMagic):
+		return FormatZip
+	default:
+		return FormatUnknown
+	}
+}
+
 func DecompressToPath(rdr io.ReadCloser, target string) error {
-	gr, err := gzip.NewReader(rdr)
+	return DecompressToPathWithLimits(rdr, target, DefaultLimits)
+}
+
+// DecompressToPathWithLimits extracts a gzip or zstd compressed tarball, or a zip archive,
+// into target.  Entries are first extracted to a temporary directory inside target and each top level
+// entry is then renamed into place, replacing any existing entry of the same name,
+// so that a failed extraction leaves target untouched.  Existing entries are moved aside
+// rather than removed ahead of the rename; should any rename fail, every entry already
+// replaced is restored, in reverse order.
+// Entries with absolute paths or escaping the extraction root are rejected,
+// as are symlinks whose targets are absolute or contain '..' elements.
+func DecompressToPathWithLimits(rdr io.ReadCloser, target string, limits Limits) error {
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(target, ".extract-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	ex := &extractor{
+		root:   tmpDir,
+		limits: limits,
+	}
+	if err := ex.extract(rdr); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		return err
+	}
+	prevDir, err := os.MkdirTemp(target, ".previous-*")
 	if err != nil {
 		return err
 	}
-	tarReader := tar.NewReader(gr)
-	// os.Mkdir(target, 0666)
+	var replaced []entryReplacement
+	for _, e := range entries {
+		r := entryReplacement{
+			src:  filepath.Join(tmpDir, e.Name()),
+			dest: filepath.Join(target, e.Name()),
+			prev: filepath.Join(prevDir, e.Name()),
+		}
+		if err := r.replace(); err != nil {
+			if restoreErr := restoreEntries(replaced); restoreErr != nil {
+				// the originals are retained in prevDir
+				return fmt.Errorf("%s; and could not restore replaced entries from '%s': %s", err.Error(), prevDir, restoreErr.Error())
+			}
+			if _, statErr := os.Lstat(r.prev); os.IsNotExist(statErr) {
+				os.RemoveAll(prevDir)
+			}
+			return err
+		}
+		replaced = append(replaced, r)
+	}
+	return os.RemoveAll(prevDir)
+}
+
+type entryReplacement struct {
+	src       string
+	dest      string
+	prev      string
+	movedAway bool
+}
+
+var rename func(string, string) error = os.Rename
+
+// replace moves any existing dest aside to prev before renaming src into place,
+// restoring it should the rename fail.
+func (r *entryReplacement) replace() error {
+	if err := rename(r.dest, r.prev); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		return rename(r.src, r.dest)
+	}
+	r.movedAway = true
+	if err := rename(r.src, r.dest); err != nil {
+		if restoreErr := rename(r.prev, r.dest); restoreErr != nil {
+			return fmt.Errorf("%s; and could not restore '%s': %s", err.Error(), r.dest, restoreErr.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// restore reverses replace, returning the extracted entry to src.
+func (r *entryReplacement) restore() error {
+	if err := rename(r.dest, r.src); err != nil {
+		return err
+	}
+	if r.movedAway {
+		return rename(r.prev, r.dest)
+	}
+	return nil
+}
+
+func restoreEntries(replaced []entryReplacement) error {
+	for i := len(replaced) - 1; i >= 0; i-- {
+		if err := replaced[i].restore(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type extractor struct {
+	root       string
+	limits     Limits
+	fileCount  int
+	totalBytes int64
+}
+
+func (ex *extractor) extract(rdr io.Reader) error {
+	br := bufio.NewReader(rdr)
+	header, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch format := DetectFormat(header); format {
+	case FormatTarGzip:
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		return ex.extractTar(gr)
+	case FormatTarZstd:
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		return ex.extractTar(zr)
+	case FormatZip:
+		return ex.extractZip(br)
+	default:
+		return fmt.Errorf("unrecognised archive format")
+	}
+}
+
+func (ex *extractor) extractTar(rdr io.Reader) error {
+	tarReader := tar.NewReader(rdr)
 	for {
 		header, err := tarReader.Next()
 		if err == io.EOF {
-			break
+			return nil
 		} else if err != nil {
 			return err
 		}
+		entry := archiveEntry{
+			name:     header.Name,
+			mode:     header.FileInfo().Mode(),
+			size:     header.Size,
+			linkname: header.Linkname,
+			open: func() (io.ReadCloser, error) {
+				return io.NopCloser(tarReader), nil
+			},
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			entry.kind = entryDir
+		case tar.TypeReg, tar.TypeRegA:
+			entry.kind = entryRegular
+		case tar.TypeSymlink:
+			entry.kind = entrySymlink
+		case tar.TypeLink:
+			entry.kind = entryHardLink
+		case tar.TypeXGlobalHeader, tar.TypeXHeader:
+			// pax headers, eg: as written by 'git archive', carry no content of their own
+			continue
+		default:
+			return fmt.Errorf("archive entry '%s' has unsupported type '%c'", header.Name, header.Typeflag)
+		}
+		if err := ex.extractEntry(entry); err != nil {
+			return err
+		}
+	}
+}
 
-		path := filepath.Join(target, header.Name)
-		info := header.FileInfo()
-		if info.IsDir() {
-			if err = os.MkdirAll(path, info.Mode()); err != nil {
+// extractZip spools the archive to a temporary file,
+// as the zip central directory lives at the end of the archive.
+func (ex *extractor) extractZip(rdr io.Reader) error {
+	spool, err := os.CreateTemp("", "archive-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	src := rdr
+	if ex.limits.MaxTotalBytes > 0 {
+		src = io.LimitReader(rdr, ex.limits.MaxTotalBytes+1)
+	}
+	size, err := io.Copy(spool, src)
+	if err != nil {
+		return err
+	}
+	if ex.limits.MaxTotalBytes > 0 && size > ex.limits.MaxTotalBytes {
+		return fmt.Errorf("archive exceeds size limit of %d bytes", ex.limits.MaxTotalBytes)
+	}
+	zr, err := zip.NewReader(spool, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		f := f
+		mode := f.Mode()
+		entry := archiveEntry{
+			name: f.Name,
+			mode: mode,
+			size: int64(f.UncompressedSize64),
+			open: f.Open,
+		}
+		switch {
+		case mode.IsDir():
+			entry.kind = entryDir
+		case mode&os.ModeSymlink != 0:
+			entry.kind = entrySymlink
+			linkname, err := readZipLinkname(f)
+			if err != nil {
 				return err
 			}
-			continue
+			entry.linkname = linkname
+		case mode.IsRegular():
+			entry.kind = entryRegular
+		default:
+			return fmt.Errorf("archive entry '%s' has unsupported mode '%s'", f.Name, mode)
+		}
+		if err := ex.extractEntry(entry); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		file, err := os.Create(path)
+func readZipLinkname(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(io.LimitReader(rc, 4096))
+	return string(b), err
+}
+
+func (ex *extractor) extractEntry(entry archiveEntry) error {
+	rel, err := sanitiseEntryName(entry.name)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+	ex.fileCount++
+	if ex.limits.MaxFileCount > 0 && ex.fileCount > ex.limits.MaxFileCount {
+		return fmt.Errorf("archive exceeds limit of %d entries", ex.limits.MaxFileCount)
+	}
+	dest := filepath.Join(ex.root, filepath.FromSlash(rel))
+	if err := ex.ensureParent(rel); err != nil {
+		return err
+	}
+	switch entry.kind {
+	case entryDir:
+		if err := os.MkdirAll(dest, 0700|entry.mode.Perm()); err != nil {
+			return err
+		}
+		return os.Chmod(dest, 0700|entry.mode.Perm())
+	case entrySymlink:
+		if err := checkSymlinkTarget(entry.name, entry.linkname); err != nil {
+			return err
+		}
+		return os.Symlink(entry.linkname, dest)
+	case entryHardLink:
+		linkRel, err := sanitiseEntryName(entry.linkname)
 		if err != nil {
 			return err
 		}
-		_, err = io.Copy(file, tarReader)
+		src := filepath.Join(ex.root, filepath.FromSlash(linkRel))
+		fi, err := os.Lstat(src)
+		if err != nil || !fi.Mode().IsRegular() {
+			return fmt.Errorf("archive entry '%s' links to '%s', which is not a previously extracted regular file", entry.name, entry.linkname)
+		}
+		return os.Link(src, dest)
+	default:
+		return ex.writeFile(entry, dest)
+	}
+}
+
+func (ex *extractor) writeFile(entry archiveEntry, dest string) error {
+	if ex.limits.MaxFileBytes > 0 && entry.size > ex.limits.MaxFileBytes {
+		return fmt.Errorf("archive entry '%s' exceeds size limit of %d bytes", entry.name, ex.limits.MaxFileBytes)
+	}
+	rc, err := entry.open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	perm := entry.mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	file, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	// declared sizes are not trusted; the copy is bounded by the tighter of the limits remaining
+	limit := int64(-1)
+	if ex.limits.MaxFileBytes > 0 {
+		limit = ex.limits.MaxFileBytes
+	}
+	if ex.limits.MaxTotalBytes > 0 {
+		if remaining := ex.limits.MaxTotalBytes - ex.totalBytes; limit < 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+	var n int64
+	if limit < 0 {
+		n, err = io.Copy(file, rc)
+	} else {
+		n, err = io.Copy(file, io.LimitReader(rc, limit+1))
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	ex.totalBytes += n
+	if limit >= 0 && n > limit {
+		return fmt.Errorf("archive entry '%s' exceeds extraction size limit", entry.name)
+	}
+	return os.Chmod(dest, perm)
+}
+
+// ensureParent creates the parent directories of an entry,
+// refusing to traverse any symlink created by an earlier entry.
+func (ex *extractor) ensureParent(rel string) error {
+	dir := path.Dir(rel)
+	if dir == "." {
+		return nil
+	}
+	current := ex.root
+	for _, elem := range strings.Split(dir, "/") {
+		current = filepath.Join(current, elem)
+		fi, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			if err := os.Mkdir(current, 0755); err != nil {
+				return err
+			}
+			continue
+		}
 		if err != nil {
-			file.Close()
 			return err
 		}
-		file.Close()
+		if !fi.IsDir() {
+			return fmt.Errorf("archive entry '%s' has parent '%s' which is not a directory", rel, elem)
+		}
 	}
 	return nil
 }
+
+// sanitiseEntryName returns the cleaned, slash delimited entry name,
+// erroring on any name which is absolute or would escape the extraction root.
+func sanitiseEntryName(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	if path.IsAbs(slashed) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("archive entry '%s' has an absolute path", name)
+	}
+	clean := path.Clean(slashed)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("archive entry '%s' escapes the extraction root", name)
+	}
+	return clean, nil
+}
+
+func checkSymlinkTarget(name string, linkname string) error {
+	slashed := strings.ReplaceAll(linkname, `\`, "/")
+	if linkname == "" || path.IsAbs(slashed) || filepath.IsAbs(linkname) || filepath.VolumeName(linkname) != "" {
+		return fmt.Errorf("archive symlink '%s' has disallowed target '%s'", name, linkname)
+	}
+	for _, elem := range strings.Split(slashed, "/") {
+		if elem == ".." {
+			return fmt.Errorf("archive symlink '%s' has disallowed target '%s'", name, linkname)
+		}
+	}
+	return nil
+}
+
+// CompressFromPath writes a gzipped tarball of the directory tree rooted at srcDir to w;
+// archive entry names are prefixed with archivePrefix, eg: 'v0.1.0/provider.yaml'.
+func CompressFromPath(w io.Writer, srcDir string, archivePrefix string) error {
+	gw := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gw)
+	err := filepath.Walk(srcDir, func(fp string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, fp)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(filepath.Join(archivePrefix, rel))
+		if info.IsDir() {
+			name += "/"
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(fp)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tarWriter, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
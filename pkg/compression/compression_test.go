@@ -0,0 +1,203 @@
+package compression_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	. "github.com/stackql/go-openapistackql/pkg/compression"
+
+	"gotest.tools/assert"
+)
+
+type tarEntry struct {
+	header *tar.Header
+	body   string
+}
+
+func regularEntry(name string, body string) tarEntry {
+	return tarEntry{
+		header: &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(body))},
+		body:   body,
+	}
+}
+
+func writeTar(t *testing.T, w io.Writer, entries []tarEntry) {
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		assert.NilError(t, tw.WriteHeader(e.header))
+		_, err := tw.Write([]byte(e.body))
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, tw.Close())
+}
+
+func tarGz(t *testing.T, entries ...tarEntry) io.ReadCloser {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	writeTar(t, gw, entries)
+	assert.NilError(t, gw.Close())
+	return io.NopCloser(&buf)
+}
+
+func tarZst(t *testing.T, entries ...tarEntry) io.ReadCloser {
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	assert.NilError(t, err)
+	writeTar(t, zw, entries)
+	assert.NilError(t, zw.Close())
+	return io.NopCloser(&buf)
+}
+
+func zipArchive(t *testing.T, files map[string]string) io.ReadCloser {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		assert.NilError(t, err)
+		_, err = w.Write([]byte(body))
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, zw.Close())
+	return io.NopCloser(&buf)
+}
+
+func readFile(t *testing.T, p string) string {
+	b, err := os.ReadFile(p)
+	assert.NilError(t, err)
+	return string(b)
+}
+
+func assertOnlyEntries(t *testing.T, dir string, names ...string) {
+	entries, err := os.ReadDir(dir)
+	assert.NilError(t, err)
+	var found []string
+	for _, e := range entries {
+		found = append(found, e.Name())
+	}
+	assert.DeepEqual(t, found, names)
+}
+
+func TestDecompressFormats(t *testing.T) {
+	assert.Equal(t, DetectFormat([]byte{0x1f, 0x8b, 0x08}), FormatTarGzip)
+	assert.Equal(t, DetectFormat([]byte{0x28, 0xb5, 0x2f, 0xfd}), FormatTarZstd)
+	assert.Equal(t, DetectFormat([]byte("PK\x03\x04")), FormatZip)
+	assert.Equal(t, DetectFormat([]byte("plain")), FormatUnknown)
+
+	for name, rdr := range map[string]io.ReadCloser{
+		"tar.gz": tarGz(
+			t,
+			tarEntry{header: &tar.Header{Name: "pax_global_header", Typeflag: tar.TypeXGlobalHeader, PAXRecords: map[string]string{"comment": "0123abcd"}}},
+			regularEntry("v0.1.0/provider.yaml", "id: okta"),
+			tarEntry{header: &tar.Header{Name: "v0.1.0/run.sh", Typeflag: tar.TypeReg, Mode: 04755, Size: 2}, body: "ls"},
+		),
+		"tar.zst": tarZst(t, regularEntry("v0.1.0/provider.yaml", "id: okta")),
+		"zip":     zipArchive(t, map[string]string{"v0.1.0/provider.yaml": "id: okta"}),
+	} {
+		target := t.TempDir()
+		assert.NilError(t, DecompressToPath(rdr, target))
+		assert.Equal(t, readFile(t, filepath.Join(target, "v0.1.0", "provider.yaml")), "id: okta", name)
+		assertOnlyEntries(t, target, "v0.1.0")
+		if name == "tar.gz" {
+			fi, err := os.Stat(filepath.Join(target, "v0.1.0", "run.sh"))
+			assert.NilError(t, err)
+			assert.Equal(t, fi.Mode(), os.FileMode(0755))
+		}
+	}
+
+	err := DecompressToPath(io.NopCloser(bytes.NewReader([]byte("not an archive"))), t.TempDir())
+	assert.ErrorContains(t, err, "unrecognised archive format")
+
+	t.Logf("TestDecompressFormats passed")
+}
+
+func TestDecompressRejectsUnsafeEntries(t *testing.T) {
+	for expected, rdr := range map[string]io.ReadCloser{
+		"escapes the extraction root":  tarGz(t, regularEntry("v0.1.0/ok.yaml", "ok"), regularEntry("v0.1.0/../../evil.yaml", "evil")),
+		"has an absolute path":         tarGz(t, regularEntry("/tmp/evil.yaml", "evil")),
+		"disallowed target":            tarGz(t, tarEntry{header: &tar.Header{Name: "v0.1.0/link", Typeflag: tar.TypeSymlink, Linkname: "../../etc"}}),
+		"has disallowed target '/etc'": tarGz(t, tarEntry{header: &tar.Header{Name: "v0.1.0/link", Typeflag: tar.TypeSymlink, Linkname: "/etc"}}),
+		"is not a directory": tarGz(
+			t,
+			tarEntry{header: &tar.Header{Name: "v0.1.0/link", Typeflag: tar.TypeSymlink, Linkname: "docs"}},
+			regularEntry("v0.1.0/link/evil.yaml", "evil"),
+		),
+		"unsupported type": tarGz(t, tarEntry{header: &tar.Header{Name: "v0.1.0/fifo", Typeflag: tar.TypeFifo}}),
+		"escapes":          zipArchive(t, map[string]string{"../evil.yaml": "evil"}),
+	} {
+		parent := t.TempDir()
+		target := filepath.Join(parent, "provider")
+		err := DecompressToPath(rdr, target)
+		assert.ErrorContains(t, err, expected)
+		assertOnlyEntries(t, target)
+		assertOnlyEntries(t, parent, "provider")
+	}
+
+	t.Logf("TestDecompressRejectsUnsafeEntries passed")
+}
+
+func TestDecompressLimits(t *testing.T) {
+	limits := Limits{MaxFileCount: 2, MaxFileBytes: 8, MaxTotalBytes: 12}
+	for expected, rdr := range map[string]io.ReadCloser{
+		"exceeds limit of 2 entries": tarGz(t, regularEntry("a", "1"), regularEntry("b", "2"), regularEntry("c", "3")),
+		"exceeds size limit of 8":    tarGz(t, regularEntry("a", "0123456789")),
+		"exceeds extraction size":    tarGz(t, regularEntry("a", "01234567"), regularEntry("b", "01234567")),
+	} {
+		target := t.TempDir()
+		err := DecompressToPathWithLimits(rdr, target, limits)
+		assert.ErrorContains(t, err, expected)
+		assertOnlyEntries(t, target)
+	}
+
+	t.Logf("TestDecompressLimits passed")
+}
+
+func TestDecompressAtomicReplace(t *testing.T) {
+	target := t.TempDir()
+	assert.NilError(t, DecompressToPath(tarGz(t, regularEntry("v0.1.0/provider.yaml", "old"), regularEntry("v0.1.0/stale.yaml", "stale")), target))
+	assert.NilError(t, DecompressToPath(tarGz(t, regularEntry("v0.2.0/provider.yaml", "other")), target))
+
+	err := DecompressToPath(tarGz(t, regularEntry("v0.1.0/provider.yaml", "new"), regularEntry("../evil.yaml", "evil")), target)
+	assert.ErrorContains(t, err, "escapes the extraction root")
+	assert.Equal(t, readFile(t, filepath.Join(target, "v0.1.0", "provider.yaml")), "old")
+
+	assert.NilError(t, DecompressToPath(tarGz(t, regularEntry("v0.1.0/provider.yaml", "new")), target))
+	assert.Equal(t, readFile(t, filepath.Join(target, "v0.1.0", "provider.yaml")), "new")
+	_, err = os.Stat(filepath.Join(target, "v0.1.0", "stale.yaml"))
+	assert.Assert(t, os.IsNotExist(err))
+	assert.Equal(t, readFile(t, filepath.Join(target, "v0.2.0", "provider.yaml")), "other")
+	assertOnlyEntries(t, target, "v0.1.0", "v0.2.0")
+
+	t.Logf("TestDecompressAtomicReplace passed")
+}
+
+func TestDecompressRestoresReplacedEntriesOnFailedRename(t *testing.T) {
+	target := t.TempDir()
+	assert.NilError(t, DecompressToPath(tarGz(t, regularEntry("v0.1.0/provider.yaml", "old"), regularEntry("v0.2.0/provider.yaml", "other")), target))
+
+	// the rename of the second entry into place fails
+	renames := 0
+	defer SetRename(func(src string, dest string) error {
+		if dest == filepath.Join(target, "v0.2.0") && strings.Contains(src, ".extract-") {
+			renames++
+			return &os.LinkError{Op: "rename", Old: src, New: dest, Err: os.ErrPermission}
+		}
+		return os.Rename(src, dest)
+	})()
+	err := DecompressToPath(tarGz(t, regularEntry("v0.1.0/provider.yaml", "new"), regularEntry("v0.2.0/provider.yaml", "new")), target)
+	assert.ErrorContains(t, err, "permission denied")
+	assert.Equal(t, renames, 1)
+	assert.Equal(t, readFile(t, filepath.Join(target, "v0.1.0", "provider.yaml")), "old")
+	assert.Equal(t, readFile(t, filepath.Join(target, "v0.2.0", "provider.yaml")), "other")
+	assertOnlyEntries(t, target, "v0.1.0", "v0.2.0")
+
+	t.Logf("TestDecompressRestoresReplacedEntriesOnFailedRename passed")
+}
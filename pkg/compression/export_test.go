@@ -0,0 +1,10 @@
+package compression
+
+import "os"
+
+// SetRename substitutes the rename used to move extracted entries into place,
+// returning a func restoring os.Rename.
+func SetRename(f func(string, string) error) func() {
+	rename = f
+	return func() { rename = os.Rename }
+}
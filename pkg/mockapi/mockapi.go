@@ -0,0 +1,723 @@
+package mockapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+	"github.com/stackql/go-openapistackql/pkg/httpelement"
+	"github.com/stackql/go-openapistackql/pkg/media"
+)
+
+const (
+	defaultPageCount int    = 2
+	defaultPageKey   string = "page"
+	pageTokenPrefix  string = "page_"
+	maxSchemaDepth   int    = 8
+	linkHeaderKey    string = "Link"
+	hasNextPageKey   string = "hasNextPage"
+)
+
+var (
+	_                    MockAPIServer  = &standardMockAPIServer{}
+	pathParamRegex       *regexp.Regexp = regexp.MustCompile(`\{[^{}]*\}`)
+	templateActionRegex  *regexp.Regexp = regexp.MustCompile(`\{\{[^}]*\}\}`)
+	graphQLCursorRegex   *regexp.Regexp = regexp.MustCompile(`after:\s*"?([^"\s,)]+)"?`)
+	jsonPathBracketRegex *regexp.Regexp = regexp.MustCompile(`\[[^\]]*\]`)
+)
+
+type Config struct {
+	// PageCount is the number of pages served by paginated operations,
+	// defaults to 2 so that consumers always see at least one continuation token.
+	PageCount int `json:"pageCount" yaml:"pageCount"`
+}
+
+// MockAPIServer is an http.Handler, suitable for use with httptest.NewServer,
+// answering every path in a service doc with responses synthesized from the response schema.
+type MockAPIServer interface {
+	http.Handler
+	GetService() openapistackql.Service
+}
+
+type mockOperation struct {
+	pathTemplate  string
+	pathRegex     *regexp.Regexp
+	httpMethod    string
+	operation     *openapi3.Operation
+	requestToken  openapistackql.TokenSemantic
+	responseToken openapistackql.TokenSemantic
+}
+
+type mockGraphQLOperation struct {
+	path       string
+	httpMethod string
+	queryRegex *regexp.Regexp
+	graphQL    openapistackql.GraphQL
+	operation  *openapi3.Operation
+}
+
+type standardMockAPIServer struct {
+	svc        openapistackql.Service
+	pageCount  int
+	basePaths  []string
+	operations []*mockOperation
+	graphQLOps []*mockGraphQLOperation
+}
+
+func NewMockAPIServer(svc openapistackql.Service, cfg Config) (MockAPIServer, error) {
+	if svc == nil || svc.GetT() == nil {
+		return nil, fmt.Errorf("mock API server requires a service doc")
+	}
+	pageCount := cfg.PageCount
+	if pageCount < 1 {
+		pageCount = defaultPageCount
+	}
+	rv := &standardMockAPIServer{
+		svc:       svc,
+		pageCount: pageCount,
+	}
+	stores, err := getOperationStores(svc)
+	if err != nil {
+		return nil, err
+	}
+	rv.basePaths = getServerBasePaths(svc.GetT())
+	for _, p := range orderedPaths(svc.GetT().Paths) {
+		pathItem := svc.GetT().Paths[p]
+		pathRegex, err := getPathTemplateRegex(p)
+		if err != nil {
+			return nil, err
+		}
+		ops := pathItem.Operations()
+		for _, httpMethod := range sortedKeys(ops) {
+			op := ops[httpMethod]
+			mo := &mockOperation{
+				pathTemplate: p,
+				pathRegex:    pathRegex,
+				httpMethod:   strings.ToUpper(httpMethod),
+				operation:    op,
+			}
+			if opStore, ok := stores[getOperationKey(p, httpMethod)]; ok {
+				mo.requestToken, _ = opStore.GetPaginationRequestTokenSemantic()
+				mo.responseToken, _ = opStore.GetPaginationResponseTokenSemantic()
+				if gql := opStore.GetGraphQL(); gql != nil {
+					gqo, err := newMockGraphQLOperation(gql, op)
+					if err != nil {
+						return nil, err
+					}
+					rv.graphQLOps = append(rv.graphQLOps, gqo)
+				}
+			}
+			rv.operations = append(rv.operations, mo)
+		}
+	}
+	return rv, nil
+}
+
+func newMockGraphQLOperation(gql openapistackql.GraphQL, op *openapi3.Operation) (*mockGraphQLOperation, error) {
+	u, err := url.Parse(gql.GetURL())
+	if err != nil {
+		return nil, err
+	}
+	httpMethod := strings.ToUpper(gql.GetHTTPVerb())
+	if httpMethod == "" {
+		httpMethod = http.MethodPost
+	}
+	// the GraphQL reader strips newlines from the rendered query
+	tmpl := strings.ReplaceAll(gql.GetQuery(), "\n", "")
+	var literals []string
+	for _, s := range templateActionRegex.Split(tmpl, -1) {
+		literals = append(literals, regexp.QuoteMeta(s))
+	}
+	queryRegex, err := regexp.Compile(fmt.Sprintf("(?s)^%s$", strings.Join(literals, ".*")))
+	if err != nil {
+		return nil, err
+	}
+	return &mockGraphQLOperation{
+		path:       u.Path,
+		httpMethod: httpMethod,
+		queryRegex: queryRegex,
+		graphQL:    gql,
+		operation:  op,
+	}, nil
+}
+
+func (ms *standardMockAPIServer) GetService() openapistackql.Service {
+	return ms.svc
+}
+
+func (ms *standardMockAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if gqo, ok := ms.findGraphQLOperation(r); ok {
+		ms.serveGraphQL(w, r, gqo)
+		return
+	}
+	mo, status := ms.findOperation(r)
+	if mo == nil {
+		writeError(w, status, fmt.Sprintf("no operation for %s %s", r.Method, r.URL.Path))
+		return
+	}
+	page, err := ms.getRequestedPage(r, mo.requestToken)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status, body, hasBody := getSuccessResponse(mo.operation)
+	if mo.responseToken != nil {
+		if page < ms.pageCount {
+			body, err = ms.setResponseToken(w, r, mo, body, page+1)
+		} else {
+			body = clearResponseToken(w, mo, body)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if !hasBody {
+		w.WriteHeader(status)
+		return
+	}
+	writeJSON(w, status, body)
+}
+
+func (ms *standardMockAPIServer) findOperation(r *http.Request) (*mockOperation, int) {
+	status := http.StatusNotFound
+	for _, p := range ms.getCandidatePaths(r.URL.Path) {
+		for _, mo := range ms.operations {
+			if !mo.pathRegex.MatchString(p) {
+				continue
+			}
+			if mo.httpMethod == strings.ToUpper(r.Method) {
+				return mo, http.StatusOK
+			}
+			status = http.StatusMethodNotAllowed
+		}
+	}
+	return nil, status
+}
+
+func (ms *standardMockAPIServer) findGraphQLOperation(r *http.Request) (*mockGraphQLOperation, bool) {
+	for _, gqo := range ms.graphQLOps {
+		if gqo.httpMethod == strings.ToUpper(r.Method) && gqo.path == r.URL.Path {
+			return gqo, true
+		}
+	}
+	return nil, false
+}
+
+// getCandidatePaths returns the request path along with the
+// request path stripped of any server base path declared in the service doc.
+func (ms *standardMockAPIServer) getCandidatePaths(requestPath string) []string {
+	rv := []string{requestPath}
+	for _, bp := range ms.basePaths {
+		if strings.HasPrefix(requestPath, bp+"/") {
+			rv = append(rv, strings.TrimPrefix(requestPath, bp))
+		}
+	}
+	return rv
+}
+
+func (ms *standardMockAPIServer) getRequestedPage(r *http.Request, requestToken openapistackql.TokenSemantic) (int, error) {
+	token := r.URL.Query().Get(defaultPageKey)
+	if requestToken != nil {
+		switch strings.ToLower(requestToken.GetLocation()) {
+		case httpelement.QueryStr:
+			if v := r.URL.Query().Get(requestToken.GetKey()); v != "" {
+				token = v
+			}
+		case httpelement.HeaderStr:
+			if v := r.Header.Get(requestToken.GetKey()); v != "" {
+				token = v
+			}
+		case httpelement.BodyAttributeStr:
+			if r.Body != nil {
+				var body interface{}
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					return 0, err
+				}
+				if len(b) > 0 {
+					if err := json.Unmarshal(b, &body); err != nil {
+						return 0, err
+					}
+				}
+				if v, ok := lookupPath(body, jsonPathSegments(requestToken.GetKey())); ok {
+					token = fmt.Sprintf("%v", v)
+				}
+			}
+		}
+	}
+	return ms.parsePageToken(token)
+}
+
+func (ms *standardMockAPIServer) parsePageToken(token string) (int, error) {
+	if token == "" {
+		return 1, nil
+	}
+	page, err := strconv.Atoi(strings.TrimPrefix(token, pageTokenPrefix))
+	if err != nil || !strings.HasPrefix(token, pageTokenPrefix) || page < 1 || page > ms.pageCount {
+		return 0, fmt.Errorf("invalid page token '%s'", token)
+	}
+	return page, nil
+}
+
+func (ms *standardMockAPIServer) setResponseToken(w http.ResponseWriter, r *http.Request, mo *mockOperation, body interface{}, nextPage int) (interface{}, error) {
+	token := getPageToken(nextPage)
+	key := mo.responseToken.GetKey()
+	switch strings.ToLower(mo.responseToken.GetLocation()) {
+	case httpelement.HeaderStr:
+		if !strings.EqualFold(key, linkHeaderKey) {
+			w.Header().Set(key, token)
+			return body, nil
+		}
+		queryKey := defaultPageKey
+		if mo.requestToken != nil && strings.ToLower(mo.requestToken.GetLocation()) == httpelement.QueryStr {
+			queryKey = mo.requestToken.GetKey()
+		}
+		next := getRequestURL(r)
+		q := next.Query()
+		q.Set(queryKey, token)
+		next.RawQuery = q.Encode()
+		w.Header().Set(linkHeaderKey, fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+		return body, nil
+	case httpelement.BodyAttributeStr:
+		if body == nil {
+			body = make(map[string]interface{})
+		}
+		m, ok := body.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot set response token '%s' on body of type '%T'", key, body)
+		}
+		setPath(m, jsonPathSegments(key), token)
+		return m, nil
+	default:
+		return nil, fmt.Errorf("cannot accomodate response token location '%s'", mo.responseToken.GetLocation())
+	}
+}
+
+// clearResponseToken removes any token carried over from an example
+// or synthesized from the schema, so that the final page terminates pagination.
+func clearResponseToken(w http.ResponseWriter, mo *mockOperation, body interface{}) interface{} {
+	key := mo.responseToken.GetKey()
+	switch strings.ToLower(mo.responseToken.GetLocation()) {
+	case httpelement.HeaderStr:
+		w.Header().Del(key)
+	case httpelement.BodyAttributeStr:
+		if m, ok := body.(map[string]interface{}); ok {
+			deletePath(m, jsonPathSegments(key))
+		}
+	}
+	return body
+}
+
+func (ms *standardMockAPIServer) serveGraphQL(w http.ResponseWriter, r *http.Request, gqo *mockGraphQLOperation) {
+	var payload struct {
+		Query string `json:"query"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("cannot decode GraphQL request: %s", err.Error()))
+		return
+	}
+	for _, candidate := range ms.graphQLOps {
+		if candidate.path == gqo.path && candidate.httpMethod == gqo.httpMethod && candidate.queryRegex.MatchString(payload.Query) {
+			gqo = candidate
+			break
+		}
+	}
+	token := ""
+	if matches := graphQLCursorRegex.FindStringSubmatch(payload.Query); len(matches) == 2 {
+		token = matches[1]
+	}
+	page, err := ms.parsePageToken(token)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	_, body, _ := getSuccessResponse(gqo.operation)
+	var nodes []interface{}
+	switch b := body.(type) {
+	case []interface{}:
+		nodes = b
+	case nil:
+		nodes = []interface{}{}
+	default:
+		nodes = []interface{}{b}
+	}
+	rv := make(map[string]interface{})
+	if p, ok := gqo.graphQL.GetResponseJSONPath(); ok {
+		setPath(rv, jsonPathSegments(p), nodes)
+	}
+	if p, ok := gqo.graphQL.GetCursorJSONPath(); ok {
+		// page info is laid out per the relay connection convention,
+		// the cursor is omitted on the final page
+		segments := jsonPathSegments(p)
+		hasNextPage := page < ms.pageCount
+		if hasNextPage {
+			setPath(rv, segments, getPageToken(page+1))
+		}
+		if len(segments) > 1 {
+			setPath(rv, append(segments[:len(segments)-1:len(segments)-1], hasNextPageKey), hasNextPage)
+		}
+	}
+	writeJSON(w, http.StatusOK, rv)
+}
+
+func getPageToken(page int) string {
+	return fmt.Sprintf("%s%d", pageTokenPrefix, page)
+}
+
+func getRequestURL(r *http.Request) *url.URL {
+	u := *r.URL
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	return &u
+}
+
+func getOperationStores(svc openapistackql.Service) (map[string]openapistackql.OperationStore, error) {
+	rv := make(map[string]openapistackql.OperationStore)
+	resources, err := svc.GetResources()
+	if err != nil {
+		return nil, err
+	}
+	for _, rsc := range resources {
+		for _, m := range rsc.GetMethods() {
+			m := m
+			opRef := m.GetOperationRef()
+			if opRef == nil {
+				continue
+			}
+			rv[getOperationKey(opRef.ExtractPathItem(), opRef.ExtractMethodItem())] = &m
+		}
+	}
+	return rv, nil
+}
+
+func getOperationKey(pathTemplate string, httpMethod string) string {
+	return fmt.Sprintf("%s %s", strings.ToUpper(httpMethod), pathTemplate)
+}
+
+func getPathTemplateRegex(pathTemplate string) (*regexp.Regexp, error) {
+	if i := strings.Index(pathTemplate, "?"); i > -1 {
+		pathTemplate = pathTemplate[:i]
+	}
+	var literals []string
+	for _, s := range pathParamRegex.Split(pathTemplate, -1) {
+		literals = append(literals, regexp.QuoteMeta(s))
+	}
+	return regexp.Compile(fmt.Sprintf("^%s$", strings.Join(literals, "[^/]+")))
+}
+
+func getServerBasePaths(doc *openapi3.T) []string {
+	var servers openapi3.Servers
+	servers = append(servers, doc.Servers...)
+	for _, pathItem := range doc.Paths {
+		servers = append(servers, pathItem.Servers...)
+		for _, op := range pathItem.Operations() {
+			if op.Servers != nil {
+				servers = append(servers, *op.Servers...)
+			}
+		}
+	}
+	found := make(map[string]struct{})
+	var rv []string
+	for _, s := range servers {
+		if s == nil {
+			continue
+		}
+		u, err := url.Parse(substituteServerVariables(s))
+		if err != nil {
+			continue
+		}
+		bp := strings.TrimSuffix(u.Path, "/")
+		if _, ok := found[bp]; ok || bp == "" {
+			continue
+		}
+		found[bp] = struct{}{}
+		rv = append(rv, bp)
+	}
+	// longest first, so that the most specific base path is stripped
+	sort.Slice(rv, func(i, j int) bool {
+		return len(rv[i]) > len(rv[j])
+	})
+	return rv
+}
+
+func substituteServerVariables(s *openapi3.Server) string {
+	return pathParamRegex.ReplaceAllStringFunc(s.URL, func(v string) string {
+		name := strings.SplitN(strings.Trim(v, "{}"), ":", 2)[0]
+		if sv, ok := s.Variables[name]; ok && sv != nil {
+			return sv.Default
+		}
+		return name
+	})
+}
+
+// orderedPaths matches concrete paths ahead of their templated counterparts,
+// as per the approach in pkg/queryrouter.
+func orderedPaths(paths openapi3.Paths) []string {
+	rv := make([]string, 0, len(paths))
+	for p := range paths {
+		rv = append(rv, p)
+	}
+	sort.Slice(rv, func(i, j int) bool {
+		ci, cj := strings.Count(rv[i], "}"), strings.Count(rv[j], "}")
+		if ci != cj {
+			return ci < cj
+		}
+		return rv[i] > rv[j]
+	})
+	return rv
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	rv := make([]string, 0, len(m))
+	for k := range m {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+// getSuccessResponse returns the lowest 2xx status declared for the operation
+// and a synthesized body, preferring any examples present in the doc.
+// The body is a copy, so callers are free to mutate it.
+func getSuccessResponse(op *openapi3.Operation) (int, interface{}, bool) {
+	status, body, hasBody := getDocSuccessResponse(op)
+	return status, copyValue(body), hasBody
+}
+
+func getDocSuccessResponse(op *openapi3.Operation) (int, interface{}, bool) {
+	var codes []string
+	for k := range op.Responses {
+		if strings.HasPrefix(k, "2") {
+			codes = append(codes, k)
+		}
+	}
+	sort.Strings(codes)
+	status := http.StatusOK
+	var responseRef *openapi3.ResponseRef
+	if len(codes) > 0 {
+		responseRef = op.Responses[codes[0]]
+		if s, err := strconv.Atoi(codes[0]); err == nil {
+			status = s
+		}
+	} else {
+		responseRef = op.Responses.Default()
+	}
+	if status == http.StatusNoContent || responseRef == nil || responseRef.Value == nil {
+		return status, nil, false
+	}
+	mt := getResponseMediaType(responseRef.Value)
+	if mt == nil {
+		return status, nil, false
+	}
+	if mt.Example != nil {
+		return status, mt.Example, true
+	}
+	var exampleKeys []string
+	for k := range mt.Examples {
+		exampleKeys = append(exampleKeys, k)
+	}
+	sort.Strings(exampleKeys)
+	for _, k := range exampleKeys {
+		if ex := mt.Examples[k]; ex != nil && ex.Value != nil && ex.Value.Value != nil {
+			return status, ex.Value.Value, true
+		}
+	}
+	if mt.Schema == nil {
+		return status, nil, false
+	}
+	return status, synthesizeFromSchema(mt.Schema.Value, 0), true
+}
+
+func getResponseMediaType(resp *openapi3.Response) *openapi3.MediaType {
+	if mt := resp.Content.Get(media.MediaTypeJson); mt != nil {
+		return mt
+	}
+	var keys []string
+	for k := range resp.Content {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if strings.Contains(k, "json") {
+			return resp.Content[k]
+		}
+	}
+	return nil
+}
+
+func synthesizeFromSchema(s *openapi3.Schema, depth int) interface{} {
+	if s == nil || depth > maxSchemaDepth {
+		return nil
+	}
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	}
+	if len(s.AllOf) > 0 {
+		rv := make(map[string]interface{})
+		for _, sr := range s.AllOf {
+			if m, ok := synthesizeFromSchema(sr.Value, depth+1).(map[string]interface{}); ok {
+				for k, v := range m {
+					rv[k] = v
+				}
+			}
+		}
+		for k, v := range synthesizeProperties(s, depth) {
+			rv[k] = v
+		}
+		return rv
+	}
+	for _, srs := range []openapi3.SchemaRefs{s.OneOf, s.AnyOf} {
+		if len(srs) > 0 && srs[0] != nil {
+			return synthesizeFromSchema(srs[0].Value, depth+1)
+		}
+	}
+	switch s.Type {
+	case "array":
+		if s.Items == nil {
+			return []interface{}{}
+		}
+		return []interface{}{synthesizeFromSchema(s.Items.Value, depth+1)}
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return "1970-01-01T00:00:00Z"
+		case "date":
+			return "1970-01-01"
+		case "uri", "url":
+			return "https://example.com"
+		default:
+			return "string"
+		}
+	case "integer":
+		return 0
+	case "number":
+		return 0.0
+	case "boolean":
+		return false
+	default:
+		return synthesizeProperties(s, depth)
+	}
+}
+
+func synthesizeProperties(s *openapi3.Schema, depth int) map[string]interface{} {
+	rv := make(map[string]interface{})
+	for k, sr := range s.Properties {
+		if sr == nil {
+			continue
+		}
+		rv[k] = synthesizeFromSchema(sr.Value, depth+1)
+	}
+	return rv
+}
+
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		rv := make(map[string]interface{}, len(v))
+		for k, el := range v {
+			rv[k] = copyValue(el)
+		}
+		return rv
+	case []interface{}:
+		rv := make([]interface{}, len(v))
+		for i, el := range v {
+			rv[i] = copyValue(el)
+		}
+		return rv
+	default:
+		return v
+	}
+}
+
+// jsonPathSegments reduces a simple JSON path, eg: '$.data.things.pageInfo..endCursor',
+// to its member names, dropping any subscripts and filters.
+func jsonPathSegments(p string) []string {
+	p = jsonPathBracketRegex.ReplaceAllString(strings.TrimPrefix(p, "$"), "")
+	var rv []string
+	for _, s := range strings.Split(p, ".") {
+		if s != "" {
+			rv = append(rv, s)
+		}
+	}
+	return rv
+}
+
+func setPath(m map[string]interface{}, segments []string, val interface{}) {
+	if len(segments) == 0 {
+		return
+	}
+	for _, s := range segments[:len(segments)-1] {
+		child, ok := m[s].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			m[s] = child
+		}
+		m = child
+	}
+	m[segments[len(segments)-1]] = val
+}
+
+func deletePath(m map[string]interface{}, segments []string) {
+	if len(segments) == 0 {
+		return
+	}
+	for _, s := range segments[:len(segments)-1] {
+		child, ok := m[s].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = child
+	}
+	delete(m, segments[len(segments)-1])
+}
+
+func lookupPath(v interface{}, segments []string) (interface{}, bool) {
+	for _, s := range segments {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		v, ok = m[s]
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", media.MediaTypeJson)
+	w.WriteHeader(status)
+	w.Write(b) //nolint:errcheck // nothing to be done with write errors
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]interface{}{"message": msg})
+}
@@ -0,0 +1,213 @@
+package mockapi_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stackql/stackql-provider-registry/signing/Ed25519/app/edcrypto"
+
+	"github.com/stackql/go-openapistackql/openapistackql"
+	. "github.com/stackql/go-openapistackql/pkg/mockapi"
+
+	"github.com/stackql/go-openapistackql/pkg/fileutil"
+	"github.com/stackql/go-openapistackql/pkg/graphql"
+	"github.com/stackql/go-openapistackql/pkg/response"
+
+	"gotest.tools/assert"
+)
+
+func setupServer(t *testing.T, cfg Config) (openapistackql.Service, *httptest.Server) {
+	registryRoot, err := fileutil.GetFilePathFromRepositoryRoot("test/registry")
+	assert.NilError(t, err)
+	reg, err := openapistackql.NewRegistry(
+		openapistackql.RegistryConfig{
+			RegistryURL:   fmt.Sprintf("file://%s", registryRoot),
+			VerfifyConfig: &edcrypto.VerifierConfig{NopVerify: true},
+		},
+		nil,
+	)
+	assert.NilError(t, err)
+	prov, err := reg.LoadProviderByName("mockableprovider", "v0.1.0")
+	assert.NilError(t, err)
+	ps, err := prov.GetProviderService("mockable_service")
+	assert.NilError(t, err)
+	svc, err := reg.GetService(ps)
+	assert.NilError(t, err)
+	ms, err := NewMockAPIServer(svc, cfg)
+	assert.NilError(t, err)
+	srv := httptest.NewServer(ms)
+	t.Cleanup(srv.Close)
+	return svc, srv
+}
+
+func getResponseTokenSemantic(t *testing.T, svc openapistackql.Service, resourceKey string) openapistackql.TokenSemantic {
+	rsc, err := svc.GetResource(resourceKey)
+	assert.NilError(t, err)
+	m, ok := rsc.GetMethods()["list"]
+	assert.Assert(t, ok)
+	ts, ok := m.GetPaginationResponseTokenSemantic()
+	assert.Assert(t, ok)
+	return ts
+}
+
+func TestBodyTokenPagination(t *testing.T) {
+	svc, srv := setupServer(t, Config{PageCount: 3})
+	ts := getResponseTokenSemantic(t, svc, "widgets")
+
+	var pages []map[string]interface{}
+	pageToken := ""
+	for {
+		u := srv.URL + "/v1/projects/my-project/widgets"
+		if pageToken != "" {
+			u = fmt.Sprintf("%s?pageToken=%s", u, url.QueryEscape(pageToken))
+		}
+		resp, err := http.Get(u)
+		assert.NilError(t, err)
+		assert.Equal(t, resp.StatusCode, http.StatusOK)
+		var body map[string]interface{}
+		assert.NilError(t, json.NewDecoder(resp.Body).Decode(&body))
+		resp.Body.Close()
+		pages = append(pages, body)
+		tok, err := ts.GetProcessedToken(response.NewResponse(body, body, resp))
+		if err != nil || tok == nil {
+			break
+		}
+		pageToken = fmt.Sprintf("%v", tok)
+	}
+	assert.Equal(t, len(pages), 3)
+	// the media type example is preferred over synthesis
+	items, ok := pages[0]["items"].([]interface{})
+	assert.Assert(t, ok)
+	assert.Equal(t, items[0].(map[string]interface{})["name"], "First Widget")
+
+	resp, err := http.Get(srv.URL + "/v1/projects/my-project/widgets?pageToken=bogus")
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusBadRequest)
+
+	t.Logf("TestBodyTokenPagination passed")
+}
+
+func TestBodyTokenPaginationWithSynthesizedToken(t *testing.T) {
+	svc, srv := setupServer(t, Config{PageCount: 3})
+	ts := getResponseTokenSemantic(t, svc, "widgets")
+	// absent the example, the schema synthesizes a 'nextPageToken' on every page
+	mt := svc.GetT().Paths["/projects/{project}/widgets"].Get.Responses["200"].Value.Content.Get("application/json")
+	mt.Example = nil
+
+	pageCount := 0
+	pageToken := ""
+	for {
+		u := srv.URL + "/v1/projects/my-project/widgets"
+		if pageToken != "" {
+			u = fmt.Sprintf("%s?pageToken=%s", u, url.QueryEscape(pageToken))
+		}
+		resp, err := http.Get(u)
+		assert.NilError(t, err)
+		assert.Equal(t, resp.StatusCode, http.StatusOK)
+		var body map[string]interface{}
+		assert.NilError(t, json.NewDecoder(resp.Body).Decode(&body))
+		resp.Body.Close()
+		pageCount++
+		assert.Assert(t, pageCount <= 3)
+		tok, err := ts.GetProcessedToken(response.NewResponse(body, body, resp))
+		if err != nil || tok == nil {
+			_, ok := body["nextPageToken"]
+			assert.Assert(t, !ok)
+			break
+		}
+		pageToken = fmt.Sprintf("%v", tok)
+	}
+	assert.Equal(t, pageCount, 3)
+
+	t.Logf("TestBodyTokenPaginationWithSynthesizedToken passed")
+}
+
+func TestLinkHeaderPaginationAndSchemaSynthesis(t *testing.T) {
+	svc, srv := setupServer(t, Config{})
+	ts := getResponseTokenSemantic(t, svc, "gizmos")
+	transformer, err := ts.GetTransformer()
+	assert.NilError(t, err)
+
+	pageCount := 0
+	next := srv.URL + "/v1/gizmos"
+	for next != "" {
+		resp, err := http.Get(next)
+		assert.NilError(t, err)
+		assert.Equal(t, resp.StatusCode, http.StatusOK)
+		var body []map[string]interface{}
+		assert.NilError(t, json.NewDecoder(resp.Body).Decode(&body))
+		resp.Body.Close()
+		pageCount++
+		assert.Equal(t, len(body), 1)
+		assert.Equal(t, body[0]["id"], "gizmo-1")
+		assert.Equal(t, body[0]["kind"], "sprocket")
+		assert.Equal(t, body[0]["active"], true)
+		assert.Equal(t, body[0]["created"], "1970-01-01T00:00:00Z")
+		tok, err := transformer(resp.Header)
+		assert.NilError(t, err)
+		next = tok.(string)
+	}
+	assert.Equal(t, pageCount, 2)
+
+	resp, err := http.Post(srv.URL+"/v1/gizmos", "application/json", nil)
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusMethodNotAllowed)
+
+	resp, err = http.Get(srv.URL + "/v1/no/such/path")
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusNotFound)
+
+	t.Logf("TestLinkHeaderPaginationAndSchemaSynthesis passed")
+}
+
+func TestGraphQLCursorPagination(t *testing.T) {
+	svc, srv := setupServer(t, Config{PageCount: 3})
+	rsc, err := svc.GetResource("things")
+	assert.NilError(t, err)
+	m, ok := rsc.GetMethods()["list"]
+	assert.Assert(t, ok)
+	gql := m.GetGraphQL()
+	assert.Assert(t, gql != nil)
+	responsePath, ok := gql.GetResponseJSONPath()
+	assert.Assert(t, ok)
+	cursorPath, ok := gql.GetCursorJSONPath()
+	assert.Assert(t, ok)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/graphql", nil)
+	assert.NilError(t, err)
+	reader, err := graphql.NewStandardGQLReader(
+		srv.Client(),
+		req,
+		0,
+		gql.GetQuery(),
+		map[string]interface{}{"org": "my-org"},
+		"",
+		responsePath,
+		cursorPath,
+	)
+	assert.NilError(t, err)
+
+	pageCount := 0
+	for {
+		nodes, err := reader.Read()
+		if len(nodes) > 0 {
+			pageCount++
+			assert.Equal(t, nodes[0]["id"], "thing-1")
+		}
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+	}
+	assert.Equal(t, pageCount, 3)
+
+	t.Logf("TestGraphQLCursorPagination passed")
+}
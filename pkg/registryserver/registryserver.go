@@ -0,0 +1,135 @@
+package registryserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RegistryServer serves a directory laid out as a provider registry,
+// ie: 'src/' docs with detached '.sig' files, 'dist/' archives and 'dist/providers.yaml'.
+type RegistryServer interface {
+	http.Handler
+	GetRoot() string
+}
+
+type etagEntry struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+type standardRegistryServer struct {
+	root      string
+	etagMutex sync.Mutex
+	etags     map[string]etagEntry
+}
+
+func NewRegistryServer(root string) (RegistryServer, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	// served paths are checked against the root with symlinks resolved
+	absRoot, err = filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(absRoot)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("registry root '%s' is not a directory", root)
+	}
+	return &standardRegistryServer{
+		root:  absRoot,
+		etags: make(map[string]etagEntry),
+	}, nil
+}
+
+func (rs *standardRegistryServer) GetRoot() string {
+	return rs.root
+}
+
+func (rs *standardRegistryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	cleaned := path.Clean("/" + r.URL.Path)
+	for _, elem := range strings.Split(cleaned, "/") {
+		if strings.HasPrefix(elem, ".") {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	fp, ok := rs.resolve(cleaned)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(fp)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	etag, err := rs.getETag(fp, fi, f)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	// ServeContent honours If-None-Match, If-Match and Range given the ETag above
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+}
+
+// resolve returns the file path for a cleaned request path, with symlinks resolved;
+// paths resolving outside the root are refused.
+func (rs *standardRegistryServer) resolve(cleaned string) (string, bool) {
+	fp, err := filepath.EvalSymlinks(filepath.Join(rs.root, filepath.FromSlash(cleaned)))
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(rs.root, fp)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return fp, true
+}
+
+func (rs *standardRegistryServer) getETag(fp string, fi os.FileInfo, f *os.File) (string, error) {
+	rs.etagMutex.Lock()
+	entry, ok := rs.etags[fp]
+	rs.etagMutex.Unlock()
+	if ok && entry.modTime.Equal(fi.ModTime()) && entry.size == fi.Size() {
+		return entry.etag, nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := fmt.Sprintf(`"%s"`, hex.EncodeToString(h.Sum(nil)))
+	rs.etagMutex.Lock()
+	rs.etags[fp] = etagEntry{modTime: fi.ModTime(), size: fi.Size(), etag: etag}
+	rs.etagMutex.Unlock()
+	return etag, nil
+}
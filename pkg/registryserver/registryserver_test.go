@@ -0,0 +1,97 @@
+package registryserver_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/stackql/go-openapistackql/pkg/registryserver"
+
+	"gotest.tools/assert"
+)
+
+func setupServer(t *testing.T) *httptest.Server {
+	root := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(root, "dist"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(root, "dist", "providers.yaml"), []byte("providers: {}\n"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(root, ".hidden"), []byte("secret"), 0644))
+	rs, err := NewRegistryServer(root)
+	assert.NilError(t, err)
+	srv := httptest.NewServer(rs)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestETagConditionalGet(t *testing.T) {
+	srv := setupServer(t)
+
+	resp, err := http.Get(srv.URL + "/dist/providers.yaml")
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	etag := resp.Header.Get("ETag")
+	assert.Assert(t, etag != "")
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/dist/providers.yaml", nil)
+	assert.NilError(t, err)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusNotModified)
+}
+
+func TestRangeRequest(t *testing.T) {
+	srv := setupServer(t)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/dist/providers.yaml", nil)
+	assert.NilError(t, err)
+	req.Header.Set("Range", "bytes=0-8")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusPartialContent)
+	b, err := io.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), "providers")
+}
+
+func TestHiddenAndMissingFilesNotServed(t *testing.T) {
+	srv := setupServer(t)
+
+	for _, p := range []string{"/.hidden", "/dist", "/dist/absent.yaml", "/../etc/passwd"} {
+		resp, err := http.Get(srv.URL + p)
+		assert.NilError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestSymlinksOutsideRootNotServed(t *testing.T) {
+	outside := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(outside, "secret.yaml"), []byte("secret"), 0644))
+	root := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(root, "dist"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(root, "dist", "providers.yaml"), []byte("providers: {}\n"), 0644))
+	assert.NilError(t, os.Symlink(filepath.Join(outside, "secret.yaml"), filepath.Join(root, "dist", "secret.yaml")))
+	assert.NilError(t, os.Symlink(outside, filepath.Join(root, "src")))
+	assert.NilError(t, os.Symlink("providers.yaml", filepath.Join(root, "dist", "linked.yaml")))
+	rs, err := NewRegistryServer(root)
+	assert.NilError(t, err)
+	srv := httptest.NewServer(rs)
+	t.Cleanup(srv.Close)
+
+	for p, expected := range map[string]int{
+		"/dist/secret.yaml": http.StatusNotFound,
+		"/src/secret.yaml":  http.StatusNotFound,
+		"/dist/linked.yaml": http.StatusOK,
+	} {
+		resp, err := http.Get(srv.URL + p)
+		assert.NilError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, resp.StatusCode, expected, p)
+	}
+}
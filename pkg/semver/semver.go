@@ -5,7 +5,7 @@ import (
 	"regexp"
 	"sort"
 
-	"github.com/Masterminds/semver"
+	"github.com/Masterminds/semver/v3"
 )
 
 const (
@@ -13,10 +13,38 @@ const (
 )
 
 var (
-	_ *regexp.Regexp = regexp.MustCompile(stableSemVerRegexStr)
+	stableSemVerRegex *regexp.Regexp = regexp.MustCompile(stableSemVerRegexStr)
 )
 
+// IsStable reports whether the version is a release version, eg: 'v0.1.2' rather than 'v0.1.3-alpha'.
+func IsStable(sv string) bool {
+	return stableSemVerRegex.MatchString(sv)
+}
+
+// IsConstraint reports whether the string is a version constraint,
+// eg: '^0.1', '~0.1.2' or '>=0.1.0 <0.2.0', rather than an exact version.
+func IsConstraint(s string) bool {
+	if _, err := semver.NewVersion(s); err == nil {
+		return false
+	}
+	_, err := semver.NewConstraint(s)
+	return err == nil
+}
+
 func FindLatestStable(svSlice []string) (string, error) {
+	var stable []string
+	for _, e := range svSlice {
+		if IsStable(e) {
+			stable = append(stable, e)
+		}
+	}
+	if len(stable) == 0 {
+		return "", fmt.Errorf("no stable versions available")
+	}
+	return FindLatest(stable)
+}
+
+func FindLatest(svSlice []string) (string, error) {
 	var versionsAvailable []*semver.Version
 	for _, e := range svSlice {
 		nv, err := semver.NewVersion(e)
@@ -32,18 +60,65 @@ func FindLatestStable(svSlice []string) (string, error) {
 	return versionsAvailable[len(versionsAvailable)-1].Original(), nil
 }
 
-func FindLatest(svSlice []string) (string, error) {
+// FindLatestMatching returns the greatest version satisfying the constraint.
+// Prerelease versions are only candidates where the constraint itself names a prerelease,
+// eg: '>=0.2.0-alpha'.  Entries which are not semantic versions are ignored.
+func FindLatestMatching(svSlice []string, constraintStr string) (string, error) {
+	if constraintStr == "" {
+		return FindLatestStable(svSlice)
+	}
+	constraint, err := semver.NewConstraint(constraintStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid version constraint '%s': %s", constraintStr, err.Error())
+	}
 	var versionsAvailable []*semver.Version
 	for _, e := range svSlice {
 		nv, err := semver.NewVersion(e)
 		if err != nil {
-			return "", err
+			continue
+		}
+		if constraint.Check(nv) {
+			versionsAvailable = append(versionsAvailable, nv)
 		}
-		versionsAvailable = append(versionsAvailable, nv)
 	}
-	sort.Sort(semver.Collection(versionsAvailable))
 	if len(versionsAvailable) == 0 {
-		return "", fmt.Errorf("no versions available")
+		return "", fmt.Errorf("no versions available satisfying constraint '%s'", constraintStr)
 	}
+	sort.Sort(semver.Collection(versionsAvailable))
 	return versionsAvailable[len(versionsAvailable)-1].Original(), nil
 }
+
+// Satisfies reports whether the version meets the constraint; unparseable input never does.
+func Satisfies(sv string, constraintStr string) bool {
+	constraint, err := semver.NewConstraint(constraintStr)
+	if err != nil {
+		return false
+	}
+	nv, err := semver.NewVersion(sv)
+	if err != nil {
+		return false
+	}
+	return constraint.Check(nv)
+}
+
+// Sort orders versions ascending, in place; entries which are not semantic versions follow, lexically ordered.
+func Sort(svSlice []string) {
+	parsed := make(map[string]*semver.Version, len(svSlice))
+	for _, e := range svSlice {
+		if nv, err := semver.NewVersion(e); err == nil {
+			parsed[e] = nv
+		}
+	}
+	sort.SliceStable(svSlice, func(i, j int) bool {
+		vi, iok := parsed[svSlice[i]]
+		vj, jok := parsed[svSlice[j]]
+		switch {
+		case iok && jok:
+			return vi.LessThan(vj)
+		case iok != jok:
+			return iok
+		default:
+			return svSlice[i] < svSlice[j]
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package semver_test
+
+import (
+	"testing"
+
+	. "github.com/stackql/go-openapistackql/pkg/semver"
+
+	"gotest.tools/assert"
+)
+
+var versions []string = []string{"v0.1.0", "v0.1.2", "v0.1.3-alpha", "v0.2.0", "v0.3.0-beta1"}
+
+func TestFindLatestStable(t *testing.T) {
+	latest, err := FindLatest(versions)
+	assert.NilError(t, err)
+	assert.Equal(t, latest, "v0.3.0-beta1")
+
+	stable, err := FindLatestStable(versions)
+	assert.NilError(t, err)
+	assert.Equal(t, stable, "v0.2.0")
+
+	_, err = FindLatestStable([]string{"v0.1.0-alpha"})
+	assert.ErrorContains(t, err, "no stable versions available")
+
+	t.Logf("TestFindLatestStable passed")
+}
+
+func TestFindLatestMatching(t *testing.T) {
+	for constraint, expected := range map[string]string{
+		"":                "v0.2.0",
+		"^0.1":            "v0.1.2",
+		"~0.1.2":          "v0.1.2",
+		"~0.1.0":          "v0.1.2",
+		">=0.1.0 <0.2.0":  "v0.1.2",
+		">=0.1.0, <0.2.0": "v0.1.2",
+		"0.1.x":           "v0.1.2",
+		">=0.1.3-alpha":   "v0.3.0-beta1",
+	} {
+		latest, err := FindLatestMatching(append(versions, ".extract-123"), constraint)
+		assert.NilError(t, err)
+		assert.Equal(t, latest, expected, constraint)
+	}
+
+	_, err := FindLatestMatching(versions, "^1.0")
+	assert.ErrorContains(t, err, "no versions available satisfying constraint '^1.0'")
+	_, err = FindLatestMatching(versions, "not a constraint")
+	assert.ErrorContains(t, err, "invalid version constraint")
+
+	assert.Assert(t, IsConstraint("^0.1"))
+	assert.Assert(t, IsConstraint(">=0.1.0 <0.2.0"))
+	assert.Assert(t, !IsConstraint("v0.1.0"))
+	assert.Assert(t, !IsConstraint("v1"))
+
+	t.Logf("TestFindLatestMatching passed")
+}
+
+func TestSort(t *testing.T) {
+	sorted := []string{"v0.2.0", ".extract-123", "v0.1.10", "v0.3.0-beta1", "v0.1.2"}
+	Sort(sorted)
+	assert.DeepEqual(t, sorted, []string{"v0.1.2", "v0.1.10", "v0.2.0", "v0.3.0-beta1", ".extract-123"})
+
+	t.Logf("TestSort passed")
+}